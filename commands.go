@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wishes/config"
+	"wishes/middleware"
+	"wishes/services"
+)
+
+// runCommand 执行服务端子命令
+func runCommand(name string, args []string, timeZone *time.Location) error {
+	switch name {
+	case "create-admin":
+		return createAdminCommand(args, timeZone)
+	case "gen-jwt-key":
+		return genJWTKeyCommand(args)
+	case "retire-jwt-key":
+		return retireJWTKeyCommand(args)
+	default:
+		return fmt.Errorf("未知的子命令，可用子命令: create-admin, gen-jwt-key, retire-jwt-key")
+	}
+}
+
+// createAdminCommand 创建管理员账号，用于初始化第一个管理员。
+// 密码优先读取 -password 参数，其次是 ADMIN_PASSWORD 环境变量，都未提供时从标准输入读取。
+func createAdminCommand(args []string, timeZone *time.Location) error {
+	fs := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := fs.String("username", "", "管理员用户名")
+	password := fs.String("password", "", "管理员密码（建议通过 ADMIN_PASSWORD 环境变量或标准输入提供）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *username == "" {
+		return errors.New("必须通过 -username 指定用户名")
+	}
+
+	if *password == "" {
+		*password = os.Getenv("ADMIN_PASSWORD")
+	}
+	if *password == "" {
+		fmt.Fprint(os.Stderr, "请输入密码: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("读取密码失败: %w", err)
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+	if *password == "" {
+		return errors.New("密码不能为空")
+	}
+
+	cfg := config.LoadConfig()
+	db, err := config.InitDB(cfg, timeZone)
+	if err != nil {
+		return err
+	}
+
+	adminService := services.NewAdminService(db, nil, cfg)
+	count, err := adminService.CountAdmins()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		fmt.Fprintf(os.Stderr, "提示: 已存在 %d 个管理员，后续管理员建议通过邀请注册\n", count)
+	}
+
+	admin, err := adminService.CreateAdmin(*username, *password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("已创建管理员 %s (ID: %d)\n", admin.Username, admin.ID)
+	return nil
+}
+
+// genJWTKeyCommand 在密钥目录中生成新的签名密钥，文件名即 kid。
+// 轮换步骤见 middleware/jwt_keys.go。
+func genJWTKeyCommand(args []string) error {
+	fs := flag.NewFlagSet("gen-jwt-key", flag.ContinueOnError)
+	dir := fs.String("dir", os.Getenv("JWT_KEYS_DIR"), "密钥目录，默认读取 JWT_KEYS_DIR")
+	alg := fs.String("alg", "EdDSA", "签名算法：EdDSA 或 RS256")
+	kid := fs.String("kid", "", "密钥ID，默认使用当前日期加随机后缀")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return errors.New("必须通过 -dir 或 JWT_KEYS_DIR 指定密钥目录")
+	}
+
+	if *kid == "" {
+		suffix := make([]byte, 2)
+		if _, err := rand.Read(suffix); err != nil {
+			return err
+		}
+		*kid = time.Now().Format("20060102") + "-" + hex.EncodeToString(suffix)
+	}
+
+	var key crypto.Signer
+	var err error
+	switch *alg {
+	case "EdDSA":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return fmt.Errorf("不支持的算法 %s", *alg)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(*dir, *kid+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return err
+	}
+
+	fmt.Printf("已生成 %s 密钥 %s\n", *alg, path)
+	fmt.Printf("分发到所有实例后，设置 JWT_SIGNING_KID=%s 并重启即可启用\n", *kid)
+	return nil
+}
+
+// retireJWTKeyCommand 将已停用的私钥替换为公钥，之后该密钥只能用于校验，
+// 待它签发的令牌全部过期后即可删除
+func retireJWTKeyCommand(args []string) error {
+	fs := flag.NewFlagSet("retire-jwt-key", flag.ContinueOnError)
+	dir := fs.String("dir", os.Getenv("JWT_KEYS_DIR"), "密钥目录，默认读取 JWT_KEYS_DIR")
+	kid := fs.String("kid", "", "要停用的密钥ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" || *kid == "" {
+		return errors.New("必须指定 -dir 和 -kid")
+	}
+	// 与服务启动时的规则一致：未设置 JWT_SIGNING_KID 时使用 kid 最大的私钥签名
+	keys, err := middleware.LoadKeySet(*dir, os.Getenv("JWT_SIGNING_KID"), nil)
+	if err != nil {
+		return fmt.Errorf("无法确定当前的签名密钥: %w", err)
+	}
+	if *kid == keys.SigningKID() {
+		return fmt.Errorf("不能停用当前的签名密钥 %s，请先生成新密钥并切换签名密钥", *kid)
+	}
+
+	path := filepath.Join(*dir, *kid+".pem")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	key, err := middleware.ParseJWTKey(*kid, data)
+	if err != nil {
+		return err
+	}
+	if key.Private == nil {
+		return fmt.Errorf("密钥 %s 已经停用", *kid)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(key.PublicKey)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	fmt.Printf("已停用密钥 %s，%s 后可删除该文件\n", *kid, time.Now().Add(7*24*time.Hour).Format("2006-01-02 15:04"))
+	return nil
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -10,15 +14,82 @@ type Config struct {
 	DBPath          string
 	ServerAddress   string
 	JWTSecret       []byte
+	JWTKeysDir      string // 非对称签名密钥目录，设置后 JWTSecret 只用于校验旧令牌
+	JWTSigningKID   string // 指定签名密钥，为空时使用 kid 最大的私钥
 	WechatAppID     string
 	WechatAppSecret string
 
+	// HTTP 服务配置
+	ServerReadTimeout       time.Duration // 读取整个请求（包括上传的文件）的超时
+	ServerReadHeaderTimeout time.Duration // 读取请求头的超时
+	ServerWriteTimeout      time.Duration // 从读完请求头到写完响应的超时
+	ServerIdleTimeout       time.Duration // 长连接空闲超时
+	ServerShutdownDelay     time.Duration // 收到退出信号后继续接受请求、只让就绪检查失败的时间，留给负载均衡摘除实例
+	ServerShutdownTimeout   time.Duration // 收到退出信号后等待处理中请求的最长时间
+
+	// 抓取 /metrics 需要携带的令牌，为空时不校验
+	MetricsToken string
+
+	// 日志配置
+	LogLevel             string        // debug、info、warn 或 error
+	LogFormat            string        // json 或 text
+	DBSlowQueryThreshold time.Duration // 超过该耗时的数据库查询记录为慢查询，0 表示不记录
+
+	// 微信开放接口配置
+	WechatAPIBaseURL    string        // 默认 https://api.weixin.qq.com，可指向内网代理
+	WechatAPITimeout    time.Duration // 单次请求超时
+	WechatAPIMaxRetries int           // 临时错误的重试次数
+
+	// 订阅消息配置，模板ID为空的状态不发送通知
+	WechatMessageTemplates   map[string]string // 记录状态 -> 订阅消息模板ID
+	WechatMessagePage        string            // 点击消息后打开的小程序页面
+	WechatMiniprogramState   string            // 跳转的小程序版本：formal、trial 或 developer
+	NotificationMaxAttempts  int               // 订阅消息最多发送次数
+	NotificationPollInterval time.Duration     // 检查待发送消息的间隔
+
+	// 注销冷静期，期间用户可以撤销注销申请
+	AccountDeletionCoolingOff time.Duration
+
 	// 腾讯云对象存储配置
 	COSSecretID   string
 	COSSecretKey  string
 	COSRegion     string
 	COSBucketName string
 	COSBaseURL    string
+
+	// 文件存储配置
+	StorageBackend        string        // cos、local 或 memory
+	StorageLocalDir       string        // 本地存储保存文件的目录
+	StorageLocalURLPrefix string        // 本地存储文件的访问地址前缀，可以是路径或完整地址
+	StorageSigningKey     []byte        // 本地存储签发私有文件访问地址的密钥，为空时由 JWT_SECRET 派生
+	StorageSignedURLTTL   time.Duration // 私有文件访问地址的有效期
+
+	// 上传配置
+	UploadMaxImageSize int64         // 图片大小上限，单位字节
+	UploadTicketTTL    time.Duration // 直传地址的有效期
+
+	// 图片处理配置
+	ImageDisplayMaxSize int // 展示图最长边，单位像素
+	ImageThumbnailSize  int // 缩略图边长，单位像素
+	ImageJPEGQuality    int // JPEG 压缩质量，1-100
+	ImageMaxDimension   int // 上传图片最长边，单位像素
+	ImageMaxPixels      int // 上传图片总像素数，防止解压炸弹
+
+	// 上传文件清理配置
+	UploadGCGracePeriod time.Duration // 没有上传用途的旧文件不再被引用后保留的时间，其他文件按用途的保留期
+	UploadGCInterval    time.Duration // 清理任务的执行间隔
+
+	// 按上传用途覆盖的限制，未配置的用途使用用途自身的限制或 UploadMaxImageSize 和 ImageMaxDimension
+	UploadLimits map[string]UploadLimit
+
+	// 管理员登录锁定配置
+	AdminLockoutMaxFailures   int           // 单个账号在窗口期内允许的失败次数
+	AdminLockoutIPMaxFailures int           // 单个IP在窗口期内允许的失败次数
+	AdminLockoutWindow        time.Duration // 统计窗口，同时也是锁定时长
+
+	// 管理员两步验证配置
+	AdminTOTPRequired bool   // 是否强制所有管理员启用两步验证
+	AdminTOTPIssuer   string // 验证器应用中显示的发行方名称
 }
 
 func LoadConfig() *Config {
@@ -27,9 +98,53 @@ func LoadConfig() *Config {
 	dbPath := os.Getenv("SQLITE_DB_PATH")
 	serverAddress := os.Getenv("SERVER_ADDRESS")
 	jwtSecret := os.Getenv("JWT_SECRET")
+	jwtKeysDir := os.Getenv("JWT_KEYS_DIR")
+	jwtSigningKID := os.Getenv("JWT_SIGNING_KID")
 	wechatAppId := os.Getenv("WECHAT_APPID")
 	wechatAppSecret := os.Getenv("WECHAT_SECRET")
 
+	// 加载 HTTP 服务配置
+	serverReadTimeout := time.Duration(getEnvInt("SERVER_READ_TIMEOUT_SECONDS", 30)) * time.Second
+	serverReadHeaderTimeout := time.Duration(getEnvInt("SERVER_READ_HEADER_TIMEOUT_SECONDS", 5)) * time.Second
+	serverWriteTimeout := time.Duration(getEnvInt("SERVER_WRITE_TIMEOUT_SECONDS", 60)) * time.Second
+	serverIdleTimeout := time.Duration(getEnvInt("SERVER_IDLE_TIMEOUT_SECONDS", 120)) * time.Second
+	serverShutdownDelay := time.Duration(getEnvInt("SERVER_SHUTDOWN_DELAY_SECONDS", 5)) * time.Second
+	serverShutdownTimeout := time.Duration(getEnvInt("SERVER_SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second
+
+	// 加载监控配置
+	metricsToken := os.Getenv("METRICS_TOKEN")
+
+	// 加载日志配置
+	logLevel := os.Getenv("LOG_LEVEL")
+	logFormat := os.Getenv("LOG_FORMAT")
+	dbSlowQueryThreshold := time.Duration(getEnvInt("DB_SLOW_QUERY_MS", 200)) * time.Millisecond
+
+	// 加载微信开放接口配置
+	wechatAPIBaseURL := os.Getenv("WECHAT_API_BASE_URL")
+	wechatAPITimeout := time.Duration(getEnvInt("WECHAT_API_TIMEOUT_SECONDS", 5)) * time.Second
+	wechatAPIMaxRetries := getEnvInt("WECHAT_API_MAX_RETRIES", 2)
+
+	// 加载订阅消息配置
+	wechatMessageTemplates := map[string]string{
+		"confirmed":        os.Getenv("WECHAT_TEMPLATE_RECORD_CONFIRMED"),
+		"awaiting_receipt": os.Getenv("WECHAT_TEMPLATE_RECORD_AWAITING_RECEIPT"),
+		"completed":        os.Getenv("WECHAT_TEMPLATE_RECORD_COMPLETED"),
+		"gift_returned":    os.Getenv("WECHAT_TEMPLATE_RECORD_GIFT_RETURNED"),
+	}
+	wechatMessagePage := os.Getenv("WECHAT_MESSAGE_PAGE")
+	if wechatMessagePage == "" {
+		wechatMessagePage = "pages/record/detail"
+	}
+	wechatMiniprogramState := os.Getenv("WECHAT_MINIPROGRAM_STATE")
+	if wechatMiniprogramState == "" {
+		wechatMiniprogramState = "formal"
+	}
+	notificationMaxAttempts := getEnvInt("NOTIFICATION_MAX_ATTEMPTS", 5)
+	notificationPollInterval := time.Duration(getEnvInt("NOTIFICATION_POLL_INTERVAL_SECONDS", 30)) * time.Second
+
+	// 加载注销配置
+	accountDeletionCoolingOff := time.Duration(getEnvInt("ACCOUNT_DELETION_COOLING_OFF_DAYS", 15)) * 24 * time.Hour
+
 	// 加载腾讯云对象存储配置
 	cosSecretID := os.Getenv("COS_SECRET_ID")
 	cosSecretKey := os.Getenv("COS_SECRET_KEY")
@@ -37,17 +152,161 @@ func LoadConfig() *Config {
 	cosBucketName := os.Getenv("COS_BUCKET_NAME")
 	cosBaseURL := os.Getenv("COS_BASE_URL")
 
+	// 加载文件存储配置，未指定后端时配置了 COS 存储桶就使用 COS，否则使用本地存储
+	storageBackend := os.Getenv("STORAGE_BACKEND")
+	if storageBackend == "" {
+		if cosBucketName != "" {
+			storageBackend = "cos"
+		} else {
+			storageBackend = "local"
+		}
+	}
+	storageLocalDir := os.Getenv("STORAGE_LOCAL_DIR")
+	if storageLocalDir == "" {
+		storageLocalDir = "data/uploads"
+	}
+	storageLocalURLPrefix := os.Getenv("STORAGE_LOCAL_URL_PREFIX")
+	if storageLocalURLPrefix == "" {
+		storageLocalURLPrefix = "/uploads"
+	}
+
+	storageSigningKey := os.Getenv("STORAGE_SIGNING_KEY")
+	storageSignedURLTTL := time.Duration(getEnvInt("STORAGE_SIGNED_URL_TTL_MINUTES", 15)) * time.Minute
+
+	// 加载上传配置
+	uploadMaxImageSize := int64(getEnvInt("UPLOAD_MAX_IMAGE_SIZE_MB", 5)) * 1024 * 1024
+	uploadTicketTTL := time.Duration(getEnvInt("UPLOAD_TICKET_TTL_MINUTES", 10)) * time.Minute
+
+	// 加载图片处理配置
+	imageDisplayMaxSize := getEnvInt("IMAGE_DISPLAY_MAX_SIZE", 1280)
+	imageThumbnailSize := getEnvInt("IMAGE_THUMBNAIL_SIZE", 320)
+	imageJPEGQuality := getEnvInt("IMAGE_JPEG_QUALITY", 85)
+	imageMaxDimension := getEnvInt("IMAGE_MAX_DIMENSION", 8192)
+	imageMaxPixels := getEnvInt("IMAGE_MAX_MEGAPIXELS", 40) * 1_000_000
+	uploadLimits := parseUploadLimits(os.Getenv("UPLOAD_LIMITS"))
+
+	// 加载上传文件清理配置
+	uploadGCGracePeriod := time.Duration(getEnvInt("UPLOAD_GC_GRACE_HOURS", 24)) * time.Hour
+	uploadGCInterval := time.Duration(getEnvInt("UPLOAD_GC_INTERVAL_MINUTES", 60)) * time.Minute
+
+	// 加载管理员登录锁定配置
+	adminLockoutMaxFailures := getEnvInt("ADMIN_LOCKOUT_MAX_FAILURES", 5)
+	adminLockoutIPMaxFailures := getEnvInt("ADMIN_LOCKOUT_IP_MAX_FAILURES", 20)
+	adminLockoutWindow := time.Duration(getEnvInt("ADMIN_LOCKOUT_WINDOW_MINUTES", 15)) * time.Minute
+
+	// 加载管理员两步验证配置
+	adminTOTPRequired, _ := strconv.ParseBool(os.Getenv("ADMIN_TOTP_REQUIRED"))
+	adminTOTPIssuer := os.Getenv("ADMIN_TOTP_ISSUER")
+	if adminTOTPIssuer == "" {
+		adminTOTPIssuer = "心愿墙"
+	}
+
 	return &Config{
 		DBPath:          dbPath,
 		ServerAddress:   serverAddress,
 		JWTSecret:       []byte(jwtSecret),
+		JWTKeysDir:      jwtKeysDir,
+		JWTSigningKID:   jwtSigningKID,
 		WechatAppID:     wechatAppId,
 		WechatAppSecret: wechatAppSecret,
 
+		ServerReadTimeout:       serverReadTimeout,
+		ServerReadHeaderTimeout: serverReadHeaderTimeout,
+		ServerWriteTimeout:      serverWriteTimeout,
+		ServerIdleTimeout:       serverIdleTimeout,
+		ServerShutdownDelay:     serverShutdownDelay,
+		ServerShutdownTimeout:   serverShutdownTimeout,
+
+		MetricsToken: metricsToken,
+
+		LogLevel:             logLevel,
+		LogFormat:            logFormat,
+		DBSlowQueryThreshold: dbSlowQueryThreshold,
+
+		WechatAPIBaseURL:    wechatAPIBaseURL,
+		WechatAPITimeout:    wechatAPITimeout,
+		WechatAPIMaxRetries: wechatAPIMaxRetries,
+
+		WechatMessageTemplates:   wechatMessageTemplates,
+		WechatMessagePage:        wechatMessagePage,
+		WechatMiniprogramState:   wechatMiniprogramState,
+		NotificationMaxAttempts:  notificationMaxAttempts,
+		NotificationPollInterval: notificationPollInterval,
+
+		AccountDeletionCoolingOff: accountDeletionCoolingOff,
+
 		COSSecretID:   cosSecretID,
 		COSSecretKey:  cosSecretKey,
 		COSRegion:     cosRegion,
 		COSBucketName: cosBucketName,
 		COSBaseURL:    cosBaseURL,
+
+		StorageBackend:        storageBackend,
+		StorageLocalDir:       storageLocalDir,
+		StorageLocalURLPrefix: storageLocalURLPrefix,
+		StorageSigningKey:     []byte(storageSigningKey),
+		StorageSignedURLTTL:   storageSignedURLTTL,
+
+		UploadMaxImageSize: uploadMaxImageSize,
+		UploadTicketTTL:    uploadTicketTTL,
+
+		ImageDisplayMaxSize: imageDisplayMaxSize,
+		ImageThumbnailSize:  imageThumbnailSize,
+		ImageJPEGQuality:    imageJPEGQuality,
+		ImageMaxDimension:   imageMaxDimension,
+		ImageMaxPixels:      imageMaxPixels,
+		UploadLimits:        uploadLimits,
+
+		UploadGCGracePeriod: uploadGCGracePeriod,
+		UploadGCInterval:    uploadGCInterval,
+
+		AdminLockoutMaxFailures:   adminLockoutMaxFailures,
+		AdminLockoutIPMaxFailures: adminLockoutIPMaxFailures,
+		AdminLockoutWindow:        adminLockoutWindow,
+
+		AdminTOTPRequired: adminTOTPRequired,
+		AdminTOTPIssuer:   adminTOTPIssuer,
+	}
+}
+
+// getEnvInt 读取整数环境变量，未设置或格式错误时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// UploadLimit 某个上传用途的限制，零值表示使用默认值
+type UploadLimit struct {
+	MaxBytes     int64
+	MaxDimension int
+}
+
+// parseUploadLimits 解析 UPLOAD_LIMITS，格式为 用途=大小MB:最长边像素，多个用途用逗号分隔，
+// 例如 avatar=2:1024,receipt_photo=10:8192。格式错误的项会被忽略
+func parseUploadLimits(value string) map[string]UploadLimit {
+	limits := make(map[string]UploadLimit)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		purpose, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			slog.Warn("忽略格式错误的上传限制", slog.String("item", item))
+			continue
+		}
+		sizeMB, dimension, _ := strings.Cut(spec, ":")
+		var limit UploadLimit
+		if mb, err := strconv.Atoi(sizeMB); err == nil && mb > 0 {
+			limit.MaxBytes = int64(mb) * 1024 * 1024
+		}
+		if px, err := strconv.Atoi(dimension); err == nil && px > 0 {
+			limit.MaxDimension = px
+		}
+		limits[strings.TrimSpace(purpose)] = limit
 	}
+	return limits
 }
@@ -2,17 +2,24 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 
+	"wishes/logging"
 	"wishes/models"
 )
 
-func InitDB(config *Config, timeZone *time.Location) *gorm.DB {
+// migratedModels 启动时自动迁移的数据表，新增模型时需加入这里
+func migratedModels() []any {
+	return []any{&models.Wish{}, &models.User{}, &models.Admin{}, &models.AdminInvitation{}, &models.AuditLog{}, &models.LoginAttempt{}, &models.AdminRecoveryCode{}, &models.WechatAccessToken{}, &models.SubscriptionConsent{}, &models.NotificationDelivery{}, &models.AccountDeletionRequest{}, &models.UserRestriction{}, &models.UploadTicket{}, &models.Upload{}, &models.UploadReference{}}
+}
+
+// InitDB 连接数据库并自动迁移数据表，查询出错和慢查询通过 slog 输出
+func InitDB(config *Config, timeZone *time.Location) (*gorm.DB, error) {
 	dir := "./data"
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		os.MkdirAll(dir, 0755)
@@ -22,13 +29,37 @@ func InitDB(config *Config, timeZone *time.Location) *gorm.DB {
 		NowFunc: func() time.Time {
 			return time.Now().In(timeZone)
 		},
+		Logger: logging.NewGormLogger(config.DBSlowQueryThreshold),
 	})
 	if err != nil {
-		log.Fatalf("无法连接到数据库: %v", err)
+		return nil, fmt.Errorf("无法连接到数据库: %w", err)
+	}
+
+	if err := db.AutoMigrate(migratedModels()...); err != nil {
+		slog.Warn("数据库迁移失败", slog.String("error", err.Error()))
 	}
 
-	db.AutoMigrate(&models.Wish{}, &models.User{}, &models.Admin{})
+	slog.Info("成功连接到SQLite数据库", slog.String("path", config.DBPath), slog.String("time_zone", timeZone.String()))
+	return db, nil
+}
 
-	fmt.Printf("成功连接到SQLite数据库: %s (时区: %s)\n", config.DBPath, timeZone.String())
-	return db
+// CheckMigrations 确认所有模型的数据表和字段都已存在，迁移失败或数据库被替换为旧版本时返回错误
+func CheckMigrations(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, model := range migratedModels() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		table := stmt.Schema.Table
+		if !migrator.HasTable(table) {
+			return fmt.Errorf("缺少数据表 %s", table)
+		}
+		for _, column := range stmt.Schema.DBNames {
+			if !migrator.HasColumn(model, column) {
+				return fmt.Errorf("数据表 %s 缺少字段 %s", table, column)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wishes/middleware"
+	"wishes/models"
+	"wishes/services"
+	"wishes/utils"
+)
+
+type AccountController struct {
+	accountService *services.AccountService
+}
+
+func NewAccountController(accountService *services.AccountService) *AccountController {
+	return &AccountController{
+		accountService: accountService,
+	}
+}
+
+// ExportMyData godoc
+// @Summary      [小程序]导出个人数据
+// @Description  下载包含个人资料、认领记录、订阅授权和注销申请的 zip 压缩包，文件均为 JSON 格式
+// @Tags         用户
+// @Produce      application/zip
+// @Security     ApiKeyAuth
+// @Success      200  {file}    file  "个人数据压缩包"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/user/me/export [get]
+func (c *AccountController) ExportMyData(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	// 先写入缓冲区，出错时还能返回 JSON 错误
+	var buf bytes.Buffer
+	if err := c.accountService.ExportUserData(userID.(uint), &buf); err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "导出用户数据失败", slog.String("error", err.Error()))
+		ctx.JSON(500, utils.CreateResponse(nil, "导出数据失败"))
+		return
+	}
+
+	filename := fmt.Sprintf("wishes-data-%d-%s.zip", userID, time.Now().Format("20060102"))
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(200, "application/zip", buf.Bytes())
+}
+
+// RequestDeletionRequest 注销申请
+type RequestDeletionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// RequestDeletion godoc
+// @Summary      [小程序]申请注销账号
+// @Description  提交注销申请，冷静期结束后将匿名化姓名、手机号、地址等个人信息，冷静期内可以撤销。有进行中的认领记录时不能申请
+// @Tags         用户
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      RequestDeletionRequest  false  "注销原因"
+// @Success      200  {object}  models.AccountDeletionRequest  "返回注销申请"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      409  {object}  map[string]interface{}  "已有待处理的申请或有进行中的认领记录"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/user/me/deletion [post]
+func (c *AccountController) RequestDeletion(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "user.request_deletion")
+	middleware.SetAuditTarget(ctx, "user", userID)
+
+	var req RequestDeletionRequest
+	// 注销原因是可选的，请求体为空时忽略
+	_ = ctx.ShouldBindJSON(&req)
+
+	request, err := c.accountService.RequestDeletion(userID.(uint), req.Reason)
+	if err != nil {
+		if errors.Is(err, services.ErrDeletionPending) || errors.Is(err, services.ErrDeletionActiveRecords) {
+			ctx.JSON(409, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		ctx.JSON(500, utils.CreateResponse(nil, "提交注销申请失败"))
+		return
+	}
+
+	middleware.SetAuditAfter(ctx, request)
+	ctx.JSON(200, utils.CreateResponse(request))
+}
+
+// GetMyDeletion godoc
+// @Summary      [小程序]查询注销申请
+// @Description  获取最近一次注销申请，没有申请时返回 null
+// @Tags         用户
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  models.AccountDeletionRequest  "返回注销申请"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/user/me/deletion [get]
+func (c *AccountController) GetMyDeletion(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	request, err := c.accountService.GetLatestDeletion(userID.(uint))
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "获取注销申请失败"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(request))
+}
+
+// CancelDeletion godoc
+// @Summary      [小程序]撤销注销申请
+// @Description  在冷静期内撤销注销申请
+// @Tags         用户
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  models.AccountDeletionRequest  "返回已撤销的注销申请"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      404  {object}  map[string]interface{}  "没有可撤销的注销申请"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/user/me/deletion [delete]
+func (c *AccountController) CancelDeletion(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "user.cancel_deletion")
+	middleware.SetAuditTarget(ctx, "user", userID)
+
+	request, err := c.accountService.CancelDeletion(userID.(uint))
+	if err != nil {
+		if errors.Is(err, services.ErrDeletionNotFound) {
+			ctx.JSON(404, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		ctx.JSON(500, utils.CreateResponse(nil, "撤销注销申请失败"))
+		return
+	}
+
+	middleware.SetAuditAfter(ctx, request)
+	ctx.JSON(200, utils.CreateResponse(request))
+}
+
+type GetDeletionRequestsResponse struct {
+	Items      []models.AccountDeletionRequest `json:"items"`
+	Pagination utils.Pagination                `json:"pagination"`
+}
+
+// GetDeletionRequests godoc
+// @Summary      [后台]查询注销申请
+// @Description  按用户和状态查询小程序用户的注销申请
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        userId       query    int     false  "用户ID"
+// @Param        status       query    string  false  "状态：pending、cancelled、completed"
+// @Param        pageIndex    query    int     false  "页码，默认1"
+// @Param        pageSize     query    int     false  "每页数量，默认10"
+// @Success      200  {object}  controllers.GetDeletionRequestsResponse  "返回注销申请列表"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/deletion-requests [get]
+func (c *AccountController) GetDeletionRequests(ctx *gin.Context) {
+	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
+	pageIndex, err := strconv.Atoi(pageIndexStr)
+	if err != nil || pageIndex < 1 {
+		pageIndex = 1
+	}
+
+	pageSizeStr := ctx.DefaultQuery("pageSize", "10")
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	filter := services.DeletionRequestFilter{
+		Status: models.AccountDeletionStatus(ctx.Query("status")),
+	}
+	if userID, err := strconv.ParseUint(ctx.Query("userId"), 10, 32); err == nil {
+		filter.UserID = uint(userID)
+	}
+
+	requests, total, err := c.accountService.GetDeletionRequests(filter, pageIndex, pageSize)
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "获取注销申请失败"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(GetDeletionRequestsResponse{
+		Items:      requests,
+		Pagination: utils.NewPagination(total, pageIndex, pageSize),
+	}))
+}
@@ -0,0 +1,697 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wishes/middleware"
+	"wishes/models"
+	"wishes/services"
+	"wishes/utils"
+)
+
+type AdminController struct {
+	adminService *services.AdminService
+}
+
+func NewAdminController(adminService *services.AdminService) *AdminController {
+	return &AdminController{
+		adminService: adminService,
+	}
+}
+
+// InvitationResponse 邀请信息，附带计算出的当前状态
+type InvitationResponse struct {
+	models.AdminInvitation
+	Status models.AdminInvitationStatus `json:"status"`
+}
+
+func newInvitationResponse(invitation models.AdminInvitation) InvitationResponse {
+	return InvitationResponse{
+		AdminInvitation: invitation,
+		Status:          invitation.Status(time.Now().Unix()),
+	}
+}
+
+type CreateInvitationRequest struct {
+	Note       string `json:"note"`
+	ValidHours int    `json:"validHours"` // 有效小时数，默认72，最长720
+}
+
+// CreateInvitationResponse 创建邀请响应，token 只会在此返回一次
+type CreateInvitationResponse struct {
+	InvitationResponse
+	Token string `json:"token"`
+}
+
+// CreateInvitation godoc
+// @Summary      [后台]创建管理员邀请
+// @Description  生成一次性的管理员注册邀请令牌，令牌明文只返回一次
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      CreateInvitationRequest  true  "邀请信息"
+// @Success      201  {object}  controllers.CreateInvitationResponse  "返回邀请及令牌"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/invitations [post]
+func (c *AdminController) CreateInvitation(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	var req CreateInvitationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求参数"))
+		return
+	}
+	if req.ValidHours < 0 || req.ValidHours > 720 {
+		ctx.JSON(400, utils.CreateResponse(nil, "邀请有效期需在1到720小时之间"))
+		return
+	}
+
+	middleware.SetAuditAction(ctx, "admin.invitation_create")
+
+	token, invitation, err := c.adminService.CreateInvitation(userID.(uint), time.Duration(req.ValidHours)*time.Hour, req.Note)
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "创建邀请失败"))
+		return
+	}
+
+	middleware.SetAuditTarget(ctx, "admin_invitation", invitation.ID)
+	middleware.SetAuditAfter(ctx, invitation)
+
+	ctx.JSON(201, utils.CreateResponse(CreateInvitationResponse{
+		InvitationResponse: newInvitationResponse(*invitation),
+		Token:              token,
+	}))
+}
+
+type GetInvitationsResponse struct {
+	Items      []InvitationResponse `json:"items"`
+	Pagination utils.Pagination     `json:"pagination"`
+}
+
+// GetInvitations godoc
+// @Summary      [后台]获取管理员邀请列表
+// @Description  获取所有邀请及其创建、使用和撤销记录
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        pageIndex    query    int     false  "页码，默认1"
+// @Param        pageSize     query    int     false  "每页数量，默认10"
+// @Success      200  {object}  controllers.GetInvitationsResponse  "返回邀请列表"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/invitations [get]
+func (c *AdminController) GetInvitations(ctx *gin.Context) {
+	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
+	pageIndex, err := strconv.Atoi(pageIndexStr)
+	if err != nil || pageIndex < 1 {
+		pageIndex = 1
+	}
+
+	pageSizeStr := ctx.DefaultQuery("pageSize", "10")
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	invitations, total, err := c.adminService.GetInvitations(pageIndex, pageSize)
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "获取邀请列表失败"))
+		return
+	}
+
+	items := make([]InvitationResponse, len(invitations))
+	for i, invitation := range invitations {
+		items[i] = newInvitationResponse(invitation)
+	}
+
+	ctx.JSON(200, utils.CreateResponse(GetInvitationsResponse{
+		Items:      items,
+		Pagination: utils.NewPagination(total, pageIndex, pageSize),
+	}))
+}
+
+// RevokeInvitation godoc
+// @Summary      [后台]撤销管理员邀请
+// @Description  撤销尚未使用的邀请，撤销后令牌无法再用于注册
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path    int     true  "邀请ID"
+// @Success      200  {object}  controllers.InvitationResponse  "返回撤销后的邀请"
+// @Failure      400  {object}  map[string]interface{}  "邀请已失效"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      404  {object}  map[string]interface{}  "邀请不存在"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/invitations/{id} [delete]
+func (c *AdminController) RevokeInvitation(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的邀请ID"))
+		return
+	}
+
+	middleware.SetAuditAction(ctx, "admin.invitation_revoke")
+	middleware.SetAuditTarget(ctx, "admin_invitation", id)
+
+	invitation, err := c.adminService.RevokeInvitation(uint(id), userID.(uint))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvitationNotFound):
+			ctx.JSON(404, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrInvitationInvalid):
+			ctx.JSON(400, utils.CreateResponse(nil, "邀请已使用、已撤销或已过期"))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "撤销邀请失败"))
+		}
+		return
+	}
+
+	middleware.SetAuditAfter(ctx, invitation)
+
+	ctx.JSON(200, utils.CreateResponse(newInvitationResponse(*invitation)))
+}
+
+type GetAdminsResponse struct {
+	Items      []models.Admin   `json:"items"`
+	Pagination utils.Pagination `json:"pagination"`
+}
+
+// GetAdmins godoc
+// @Summary      [后台]获取后台管理员列表
+// @Description  获取所有后台管理员账号
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        pageIndex    query    int     false  "页码，默认1"
+// @Param        pageSize     query    int     false  "每页数量，默认10"
+// @Success      200  {object}  controllers.GetAdminsResponse  "返回管理员列表"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/admins [get]
+func (c *AdminController) GetAdmins(ctx *gin.Context) {
+	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
+	pageIndex, err := strconv.Atoi(pageIndexStr)
+	if err != nil || pageIndex < 1 {
+		pageIndex = 1
+	}
+
+	pageSizeStr := ctx.DefaultQuery("pageSize", "10")
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	admins, total, err := c.adminService.GetAdmins(pageIndex, pageSize)
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "获取管理员列表失败"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(GetAdminsResponse{
+		Items:      admins,
+		Pagination: utils.NewPagination(total, pageIndex, pageSize),
+	}))
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// ChangePassword godoc
+// @Summary      [后台]修改当前管理员密码
+// @Description  验证旧密码后设置新密码，新密码需至少10个字符且同时包含字母和数字。修改后之前签发的令牌全部失效，需要重新登录
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      ChangePasswordRequest  true  "旧密码和新密码"
+// @Success      200  {object}  map[string]interface{}  "修改成功"
+// @Failure      400  {object}  map[string]interface{}  "旧密码错误或新密码不符合要求"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/me/password [put]
+func (c *AdminController) ChangePassword(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "admin.change_password")
+	middleware.SetAuditTarget(ctx, "admin", userID)
+
+	var req ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求参数"))
+		return
+	}
+
+	if err := c.adminService.ChangePassword(userID.(uint), req.OldPassword, req.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			ctx.JSON(400, utils.CreateResponse(nil, "旧密码错误"))
+		case errors.Is(err, services.ErrWeakPassword):
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrAdminNotFound):
+			ctx.JSON(404, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "修改密码失败"))
+		}
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(nil))
+}
+
+type ResetPasswordRequest struct {
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// ResetPassword godoc
+// @Summary      [后台]重设其他管理员的密码
+// @Description  为指定管理员设置新密码，并解除该账号的登录锁定，该管理员之前签发的令牌全部失效。不能重设自己的密码，请使用 /admin/me/password
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      int                   true  "管理员ID"
+// @Param        request  body      ResetPasswordRequest  true  "新密码"
+// @Success      200  {object}  map[string]interface{}  "重设成功"
+// @Failure      400  {object}  map[string]interface{}  "新密码不符合要求"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      403  {object}  map[string]interface{}  "不能重设自己的密码"
+// @Failure      404  {object}  map[string]interface{}  "管理员不存在"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/admins/{id}/password [put]
+func (c *AdminController) ResetPassword(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的管理员ID"))
+		return
+	}
+
+	middleware.SetAuditAction(ctx, "admin.reset_password")
+	middleware.SetAuditTarget(ctx, "admin", id)
+
+	var req ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求参数"))
+		return
+	}
+
+	if err := c.adminService.ResetPassword(ctx.GetUint("userID"), uint(id), req.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, services.ErrSelfReset):
+			ctx.JSON(403, utils.CreateResponse(nil, "不能重设自己的密码，请通过 /admin/me/password 修改"))
+		case errors.Is(err, services.ErrWeakPassword):
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrAdminNotFound):
+			ctx.JSON(404, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "重设密码失败"))
+		}
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(nil))
+}
+
+type GetLoginAttemptsResponse struct {
+	Items      []models.LoginAttempt `json:"items"`
+	Pagination utils.Pagination      `json:"pagination"`
+}
+
+// GetLoginAttempts godoc
+// @Summary      [后台]获取管理员登录记录
+// @Description  查看管理员登录尝试，包括失败和被锁定的尝试
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        username     query    string  false  "按用户名过滤"
+// @Param        ip           query    string  false  "按IP过滤"
+// @Param        success      query    bool    false  "按是否成功过滤，不传为全部"
+// @Param        pageIndex    query    int     false  "页码，默认1"
+// @Param        pageSize     query    int     false  "每页数量，默认10"
+// @Success      200  {object}  controllers.GetLoginAttemptsResponse  "返回登录记录"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/login-attempts [get]
+func (c *AdminController) GetLoginAttempts(ctx *gin.Context) {
+	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
+	pageIndex, err := strconv.Atoi(pageIndexStr)
+	if err != nil || pageIndex < 1 {
+		pageIndex = 1
+	}
+
+	pageSizeStr := ctx.DefaultQuery("pageSize", "10")
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	filter := services.LoginAttemptFilter{
+		Username: ctx.Query("username"),
+		IP:       ctx.Query("ip"),
+	}
+	if success, err := strconv.ParseBool(ctx.Query("success")); err == nil {
+		filter.Success = &success
+	}
+
+	attempts, total, err := c.adminService.GetLoginAttempts(filter, pageIndex, pageSize)
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "获取登录记录失败"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(GetLoginAttemptsResponse{
+		Items:      attempts,
+		Pagination: utils.NewPagination(total, pageIndex, pageSize),
+	}))
+}
+
+// SetupTOTP godoc
+// @Summary      [后台]获取两步验证绑定密钥
+// @Description  为当前管理员生成待确认的TOTP密钥和二维码链接，需调用启用接口确认后才会生效
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  services.TOTPEnrollment  "返回密钥和二维码链接"
+// @Failure      400  {object}  map[string]interface{}  "已启用两步验证"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/me/totp/setup [post]
+func (c *AdminController) SetupTOTP(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "admin.totp_setup")
+	middleware.SetAuditTarget(ctx, "admin", userID)
+
+	enrollment, err := c.adminService.BeginTOTPEnrollment(userID.(uint))
+	if err != nil {
+		if errors.Is(err, services.ErrTOTPAlreadyEnabled) {
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		ctx.JSON(500, utils.CreateResponse(nil, "生成两步验证密钥失败"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(enrollment))
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse 恢复码，只返回一次，请妥善保存
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// EnableTOTP godoc
+// @Summary      [后台]启用两步验证
+// @Description  提交验证器生成的验证码确认绑定，成功后返回恢复码
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      TOTPCodeRequest  true  "验证码"
+// @Success      200  {object}  controllers.RecoveryCodesResponse  "返回恢复码"
+// @Failure      400  {object}  map[string]interface{}  "验证码错误或未获取密钥"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/me/totp/enable [post]
+func (c *AdminController) EnableTOTP(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "admin.totp_enable")
+	middleware.SetAuditTarget(ctx, "admin", userID)
+
+	var req TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求参数"))
+		return
+	}
+
+	codes, err := c.adminService.ConfirmTOTPEnrollment(userID.(uint), req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTOTPCode),
+			errors.Is(err, services.ErrTOTPNotPending),
+			errors.Is(err, services.ErrTOTPAlreadyEnabled):
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "启用两步验证失败"))
+		}
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(RecoveryCodesResponse{RecoveryCodes: codes}))
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// DisableTOTP godoc
+// @Summary      [后台]关闭两步验证
+// @Description  验证密码后关闭当前管理员的两步验证，系统强制启用时不可关闭
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      DisableTOTPRequest  true  "当前密码"
+// @Success      200  {object}  map[string]interface{}  "关闭成功"
+// @Failure      400  {object}  map[string]interface{}  "密码错误或未启用"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      403  {object}  map[string]interface{}  "系统强制启用两步验证"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/me/totp/disable [post]
+func (c *AdminController) DisableTOTP(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "admin.totp_disable")
+	middleware.SetAuditTarget(ctx, "admin", userID)
+
+	var req DisableTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求参数"))
+		return
+	}
+
+	if err := c.adminService.DisableTOTP(userID.(uint), req.Password); err != nil {
+		switch {
+		case errors.Is(err, services.ErrTOTPRequired):
+			ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrInvalidCredentials):
+			ctx.JSON(400, utils.CreateResponse(nil, "密码错误"))
+		case errors.Is(err, services.ErrTOTPNotEnabled):
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "关闭两步验证失败"))
+		}
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(nil))
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      [后台]重新生成恢复码
+// @Description  提交当前验证码后生成一组新的恢复码，旧恢复码全部失效
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      TOTPCodeRequest  true  "验证码"
+// @Success      200  {object}  controllers.RecoveryCodesResponse  "返回新的恢复码"
+// @Failure      400  {object}  map[string]interface{}  "验证码错误或未启用"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/me/totp/recovery-codes [post]
+func (c *AdminController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "admin.totp_recovery_codes")
+	middleware.SetAuditTarget(ctx, "admin", userID)
+
+	var req TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求参数"))
+		return
+	}
+
+	codes, err := c.adminService.RegenerateRecoveryCodes(userID.(uint), req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTOTPCode), errors.Is(err, services.ErrTOTPNotEnabled):
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "生成恢复码失败"))
+		}
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(RecoveryCodesResponse{RecoveryCodes: codes}))
+}
+
+// ResetTOTP godoc
+// @Summary      [后台]清除其他管理员的两步验证
+// @Description  用于管理员丢失验证设备的情况，清除后该账号可重新绑定。系统强制两步验证时，该账号之前签发的令牌全部失效，下次登录时必须重新绑定。不能清除自己的两步验证，请使用 /admin/me/totp/disable
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path    int     true  "管理员ID"
+// @Success      200  {object}  map[string]interface{}  "清除成功"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      403  {object}  map[string]interface{}  "不能清除自己的两步验证"
+// @Failure      404  {object}  map[string]interface{}  "管理员不存在"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/admins/{id}/totp [delete]
+func (c *AdminController) ResetTOTP(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的管理员ID"))
+		return
+	}
+
+	middleware.SetAuditAction(ctx, "admin.totp_reset")
+	middleware.SetAuditTarget(ctx, "admin", id)
+
+	if err := c.adminService.ResetTOTP(ctx.GetUint("userID"), uint(id)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrSelfReset):
+			ctx.JSON(403, utils.CreateResponse(nil, "不能清除自己的两步验证，请通过 /admin/me/totp/disable 关闭"))
+		case errors.Is(err, services.ErrAdminNotFound):
+			ctx.JSON(404, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "清除两步验证失败"))
+		}
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(nil))
+}
+
+// AdminProfileResponse 后台管理员个人资料
+type AdminProfileResponse struct {
+	ID                uint   `json:"id"`
+	Username          string `json:"username"`
+	Nickname          string `json:"nickname"`
+	AvatarURL         string `json:"avatarUrl"`
+	Department        string `json:"department"`
+	ContactPhone      string `json:"contactPhone"`
+	TOTPEnabled       bool   `json:"totpEnabled"`
+	PasswordChangedAt int64  `json:"passwordChangedAt"`
+	CreatedAt         int64  `json:"createdAt"`
+}
+
+func newAdminProfileResponse(admin *models.Admin) AdminProfileResponse {
+	return AdminProfileResponse{
+		ID:                admin.ID,
+		Username:          admin.Username,
+		Nickname:          admin.Nickname,
+		AvatarURL:         admin.AvatarURL,
+		Department:        admin.Department,
+		ContactPhone:      admin.ContactPhone,
+		TOTPEnabled:       admin.TOTPEnabled,
+		PasswordChangedAt: admin.PasswordChangedAt,
+		CreatedAt:         admin.CreatedAt,
+	}
+}
+
+// GetMe godoc
+// @Summary      [后台]获取当前管理员资料
+// @Description  获取当前登录管理员的个人资料
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  controllers.AdminProfileResponse  "返回个人资料"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      404  {object}  map[string]interface{}  "管理员不存在"
+// @Router       /api/v1/admin/me [get]
+func (c *AdminController) GetMe(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	admin, err := c.adminService.GetAdminByID(userID.(uint))
+	if err != nil {
+		ctx.JSON(404, utils.CreateResponse(nil, "管理员不存在"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(newAdminProfileResponse(admin)))
+}
+
+// UpdateAdminProfileRequest 更新管理员资料请求，未传的字段保持不变
+type UpdateAdminProfileRequest struct {
+	Nickname     *string `json:"nickname"`
+	AvatarURL    *string `json:"avatarUrl"`
+	Department   *string `json:"department"`
+	ContactPhone *string `json:"contactPhone"`
+}
+
+// UpdateMe godoc
+// @Summary      [后台]更新当前管理员资料
+// @Description  更新昵称、头像、部门和联系电话，未传的字段保持不变
+// @Tags         管理员
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      UpdateAdminProfileRequest  true  "个人资料"
+// @Success      200  {object}  controllers.AdminProfileResponse  "返回更新后的个人资料"
+// @Failure      400  {object}  map[string]interface{}  "请求数据错误"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/me [put]
+func (c *AdminController) UpdateMe(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "admin.update_profile")
+	middleware.SetAuditTarget(ctx, "admin", userID)
+
+	var req UpdateAdminProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求数据"))
+		return
+	}
+
+	if admin, err := c.adminService.GetAdminByID(userID.(uint)); err == nil {
+		middleware.SetAuditBefore(ctx, newAdminProfileResponse(admin))
+	}
+
+	admin, err := c.adminService.UpdateProfile(userID.(uint), services.ProfileUpdate{
+		Nickname:     req.Nickname,
+		AvatarURL:    req.AvatarURL,
+		Department:   req.Department,
+		ContactPhone: req.ContactPhone,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidProfile) {
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		if errors.Is(err, services.ErrUploadNotOwned) {
+			ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		ctx.JSON(500, utils.CreateResponse(nil, "更新个人资料失败"))
+		return
+	}
+
+	response := newAdminProfileResponse(admin)
+	middleware.SetAuditAfter(ctx, response)
+	ctx.JSON(200, utils.CreateResponse(response))
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+
+	"wishes/models"
+	"wishes/services"
+	"wishes/utils"
+)
+
+type AuditController struct {
+	auditService *services.AuditService
+}
+
+func NewAuditController(auditService *services.AuditService) *AuditController {
+	return &AuditController{
+		auditService: auditService,
+	}
+}
+
+type GetAuditLogsResponse struct {
+	Items      []models.AuditLog `json:"items"`
+	Pagination utils.Pagination  `json:"pagination"`
+}
+
+func parseAuditLogFilter(ctx *gin.Context) services.AuditLogFilter {
+	filter := services.AuditLogFilter{
+		ActorType:  ctx.Query("actorType"),
+		Action:     ctx.Query("action"),
+		TargetType: ctx.Query("targetType"),
+		TargetID:   ctx.Query("targetId"),
+		IP:         ctx.Query("ip"),
+	}
+	if actorID, err := strconv.ParseUint(ctx.Query("actorId"), 10, 32); err == nil {
+		filter.ActorID = uint(actorID)
+	}
+	if from, err := strconv.ParseInt(ctx.Query("from"), 10, 64); err == nil {
+		filter.From = from
+	}
+	if to, err := strconv.ParseInt(ctx.Query("to"), 10, 64); err == nil {
+		filter.To = to
+	}
+	return filter
+}
+
+// GetAuditLogs godoc
+// @Summary      [后台]查询审计日志
+// @Description  按操作人、动作、操作对象、IP和时间范围查询审计日志
+// @Tags         审计
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        actorType    query    string  false  "操作人类型：user 或 admin"
+// @Param        actorId      query    int     false  "操作人ID"
+// @Param        action       query    string  false  "动作，前缀匹配，例如 wish."
+// @Param        targetType   query    string  false  "操作对象类型，例如 wish、record、user"
+// @Param        targetId     query    string  false  "操作对象ID"
+// @Param        ip           query    string  false  "来源IP"
+// @Param        from         query    int     false  "起始时间戳（秒）"
+// @Param        to           query    int     false  "截止时间戳（秒）"
+// @Param        pageIndex    query    int     false  "页码，默认1"
+// @Param        pageSize     query    int     false  "每页数量，默认10"
+// @Success      200  {object}  controllers.GetAuditLogsResponse  "返回审计日志列表"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/audit-logs [get]
+func (c *AuditController) GetAuditLogs(ctx *gin.Context) {
+	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
+	pageIndex, err := strconv.Atoi(pageIndexStr)
+	if err != nil || pageIndex < 1 {
+		pageIndex = 1
+	}
+
+	pageSizeStr := ctx.DefaultQuery("pageSize", "10")
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	logs, total, err := c.auditService.GetAuditLogs(parseAuditLogFilter(ctx), pageIndex, pageSize)
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "获取审计日志失败"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(GetAuditLogsResponse{
+		Items:      logs,
+		Pagination: utils.NewPagination(total, pageIndex, pageSize),
+	}))
+}
+
+// ExportAuditLogs godoc
+// @Summary      [后台]导出审计日志
+// @Description  按查询条件导出审计日志为Excel文件，单次最多导出50000条
+// @Tags         审计
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security     ApiKeyAuth
+// @Param        actorType    query    string  false  "操作人类型：user 或 admin"
+// @Param        actorId      query    int     false  "操作人ID"
+// @Param        action       query    string  false  "动作，前缀匹配"
+// @Param        targetType   query    string  false  "操作对象类型"
+// @Param        targetId     query    string  false  "操作对象ID"
+// @Param        ip           query    string  false  "来源IP"
+// @Param        from         query    int     false  "起始时间戳（秒）"
+// @Param        to           query    int     false  "截止时间戳（秒）"
+// @Success      200  {file}  file  "审计日志Excel文件"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/audit-logs/export [get]
+func (c *AuditController) ExportAuditLogs(ctx *gin.Context) {
+	logs, err := c.auditService.ExportAuditLogs(parseAuditLogFilter(ctx))
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "导出审计日志失败"))
+		return
+	}
+
+	xlsx := excelize.NewFile()
+	defer xlsx.Close()
+
+	sheet := xlsx.GetSheetName(0)
+	headers := []any{"ID", "时间", "操作人类型", "操作人ID", "动作", "方法", "路径", "对象类型", "对象ID", "状态码", "IP", "User-Agent", "变更前", "变更后"}
+	if err := xlsx.SetSheetRow(sheet, "A1", &headers); err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "导出审计日志失败"))
+		return
+	}
+
+	for i, log := range logs {
+		row := []any{
+			log.ID,
+			time.Unix(log.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+			log.ActorType,
+			log.ActorID,
+			log.Action,
+			log.Method,
+			log.Path,
+			log.TargetType,
+			log.TargetID,
+			log.StatusCode,
+			log.IP,
+			log.UserAgent,
+			log.Before,
+			log.After,
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := xlsx.SetSheetRow(sheet, cell, &row); err != nil {
+			ctx.JSON(500, utils.CreateResponse(nil, "导出审计日志失败"))
+			return
+		}
+	}
+
+	fileName := fmt.Sprintf("audit-logs-%s.xlsx", time.Now().Format("20060102150405"))
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	ctx.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	if err := xlsx.Write(ctx.Writer); err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "写入审计日志导出文件失败", slog.String("error", err.Error()))
+	}
+}
@@ -1,10 +1,11 @@
 package controllers
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
 	"wishes/middleware"
@@ -16,32 +17,33 @@ import (
 type AuthController struct {
 	DB            *gorm.DB
 	WechatService *services.WechatService
+	AdminService  *services.AdminService
 }
 
-func NewAuthController(db *gorm.DB, wechatService *services.WechatService) *AuthController {
+func NewAuthController(db *gorm.DB, wechatService *services.WechatService, adminService *services.AdminService) *AuthController {
 	return &AuthController{
 		DB:            db,
 		WechatService: wechatService,
+		AdminService:  adminService,
 	}
 }
 
 type AdminRegisterRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	InvitationToken string `json:"invitationToken" binding:"required"`
+	Username        string `json:"username" binding:"required"`
+	Password        string `json:"password" binding:"required"`
 }
 
 // AdminRegister godoc
 // @Summary [后台]管理员注册
-// @Description 创建新管理员账号
+// @Description 使用现有管理员签发的一次性邀请令牌创建新管理员账号
 // @Tags 管理员
 // @Accept json
 // @Produce json
-// @Security ApiKeyAuth
 // @Param request body AdminRegisterRequest true "管理员注册信息"
 // @Success 201 {object} models.Admin
 // @Failure 400 {object} map[string]interface{} "请求参数错误"
-// @Failure 401 {object} map[string]interface{} "未授权"
-// @Failure 403 {object} map[string]interface{} "禁止访问"
+// @Failure 403 {object} map[string]interface{} "邀请令牌无效"
 // @Failure 500 {object} map[string]interface{} "服务器错误"
 // @Router /api/v1/admin/register [post]
 func (c *AuthController) AdminRegister(ctx *gin.Context) {
@@ -51,29 +53,24 @@ func (c *AuthController) AdminRegister(ctx *gin.Context) {
 		return
 	}
 
-	var existingAdmin models.Admin
-	if result := c.DB.Where("username = ?", req.Username).First(&existingAdmin); result.Error == nil {
-		ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, "管理员用户名已存在"))
-		return
-	}
+	middleware.SetAuditAction(ctx, "admin.register")
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	admin, err := c.AdminService.RegisterWithInvitation(req.InvitationToken, req.Username, req.Password)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "密码加密失败"))
-		return
-	}
-
-	admin := models.Admin{
-		Username: req.Username,
-		Password: string(hashedPassword),
-	}
-
-	if result := c.DB.Create(&admin); result.Error != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "创建管理员失败"))
+		switch {
+		case errors.Is(err, services.ErrInvitationInvalid):
+			ctx.JSON(http.StatusForbidden, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrAdminExists), errors.Is(err, services.ErrWeakPassword):
+			ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "创建管理员失败"))
+		}
 		return
 	}
 
 	admin.Password = ""
+	middleware.SetAuditTarget(ctx, "admin", admin.ID)
+	middleware.SetAuditAfter(ctx, admin)
 	ctx.JSON(http.StatusCreated, utils.CreateResponse(admin))
 }
 
@@ -83,13 +80,23 @@ type AdminLoginRequest struct {
 }
 
 type AdminLoginResponse struct {
-	Token string       `json:"token"`
+	Token string       `json:"token,omitempty"`
 	Admin models.Admin `json:"admin"`
+
+	// 需要两步验证时不返回 token，而是返回短期有效的 mfaToken，
+	// 客户端需携带 mfaToken 调用 /api/v1/admin/login/totp 完成登录
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken,omitempty"`
+	// 系统强制两步验证但该账号尚未绑定时为 true，需先调用 /api/v1/admin/login/totp/setup
+	EnrollmentRequired bool `json:"enrollmentRequired,omitempty"`
+
+	// 在登录过程中完成两步验证绑定时返回的恢复码，只返回一次
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
 }
 
 // AdminLogin godoc
 // @Summary [后台]管理员登录
-// @Description 管理员登录并获取认证令牌
+// @Description 管理员登录并获取认证令牌。启用两步验证的账号只返回 mfaToken，需再调用 /api/v1/admin/login/totp
 // @Tags 管理员
 // @Accept json
 // @Produce json
@@ -97,6 +104,7 @@ type AdminLoginResponse struct {
 // @Success 200 {object} AdminLoginResponse
 // @Failure 400 {object} map[string]interface{} "请求参数错误"
 // @Failure 401 {object} map[string]interface{} "未授权"
+// @Failure 429 {object} map[string]interface{} "登录失败次数过多，账号或IP已被临时锁定"
 // @Failure 500 {object} map[string]interface{} "服务器错误"
 // @Router /api/v1/admin/login [post]
 func (c *AuthController) AdminLogin(ctx *gin.Context) {
@@ -106,27 +114,159 @@ func (c *AuthController) AdminLogin(ctx *gin.Context) {
 		return
 	}
 
-	var admin models.Admin
-	if result := c.DB.Where("username = ?", req.Username).First(&admin); result.Error != nil {
-		ctx.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, "用户名或密码错误"))
+	admin, err := c.AdminService.Login(req.Username, req.Password, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAccountLocked):
+			ctx.JSON(http.StatusTooManyRequests, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrInvalidCredentials):
+			ctx.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "登录失败"))
+		}
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.Password)); err != nil {
-		ctx.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, "用户名或密码错误"))
+	admin.Password = ""
+
+	if c.AdminService.NeedsSecondFactor(admin) {
+		mfaToken, err := middleware.GenerateAdminMFAToken(*admin)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "生成令牌失败"))
+			return
+		}
+		ctx.JSON(http.StatusOK, utils.CreateResponse(AdminLoginResponse{
+			Admin:              *admin,
+			MFARequired:        true,
+			MFAToken:           mfaToken,
+			EnrollmentRequired: !admin.TOTPEnabled,
+		}))
 		return
 	}
 
-	token, err := middleware.GenerateAdminToken(admin)
+	token, err := middleware.GenerateAdminToken(*admin)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "生成令牌失败"))
 		return
 	}
 
-	admin.Password = ""
 	ctx.JSON(http.StatusOK, utils.CreateResponse(AdminLoginResponse{
 		Token: token,
-		Admin: admin,
+		Admin: *admin,
+	}))
+}
+
+type AdminLoginTOTPSetupRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+}
+
+// AdminLoginTOTPSetup godoc
+// @Summary [后台]登录时绑定两步验证
+// @Description 系统强制两步验证而账号尚未绑定时，使用登录返回的 mfaToken 获取 TOTP 密钥和二维码链接
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param request body AdminLoginTOTPSetupRequest true "两步验证临时令牌"
+// @Success 200 {object} services.TOTPEnrollment
+// @Failure 400 {object} map[string]interface{} "请求参数错误或已绑定"
+// @Failure 401 {object} map[string]interface{} "临时令牌无效或已过期"
+// @Failure 500 {object} map[string]interface{} "服务器错误"
+// @Router /api/v1/admin/login/totp/setup [post]
+func (c *AuthController) AdminLoginTOTPSetup(ctx *gin.Context) {
+	var req AdminLoginTOTPSetupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, "无效的请求参数"))
+		return
+	}
+
+	claims, err := middleware.ParseAdminMFAToken(req.MFAToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, "登录已过期，请重新登录"))
+		return
+	}
+
+	enrollment, err := c.AdminService.BeginTOTPEnrollment(claims.UserID)
+	if err != nil {
+		if errors.Is(err, services.ErrTOTPAlreadyEnabled) {
+			ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "生成两步验证密钥失败"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.CreateResponse(enrollment))
+}
+
+type AdminLoginTOTPRequest struct {
+	MFAToken     string `json:"mfaToken" binding:"required"`
+	Code         string `json:"code"`         // 验证器应用中的6位验证码
+	RecoveryCode string `json:"recoveryCode"` // 无法使用验证器时可改用恢复码
+}
+
+// AdminLoginTOTP godoc
+// @Summary [后台]管理员登录第二步
+// @Description 提交验证码或恢复码完成登录。若账号正在登录过程中绑定两步验证，验证码用于确认绑定并返回恢复码
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param request body AdminLoginTOTPRequest true "两步验证信息"
+// @Success 200 {object} AdminLoginResponse
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 401 {object} map[string]interface{} "临时令牌无效或验证码错误"
+// @Failure 429 {object} map[string]interface{} "失败次数过多，账号或IP已被临时锁定"
+// @Failure 500 {object} map[string]interface{} "服务器错误"
+// @Router /api/v1/admin/login/totp [post]
+func (c *AuthController) AdminLoginTOTP(ctx *gin.Context) {
+	var req AdminLoginTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, "无效的请求参数"))
+		return
+	}
+
+	claims, err := middleware.ParseAdminMFAToken(req.MFAToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, "登录已过期，请重新登录"))
+		return
+	}
+
+	admin, err := c.AdminService.GetAdminByID(claims.UserID)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, "登录已过期，请重新登录"))
+		return
+	}
+
+	var recoveryCodes []string
+	if admin.TOTPEnabled {
+		admin, err = c.AdminService.VerifySecondFactor(admin.ID, req.Code, req.RecoveryCode, ctx.ClientIP(), ctx.Request.UserAgent())
+	} else {
+		admin, recoveryCodes, err = c.AdminService.CompleteEnrollmentLogin(admin.ID, req.Code, ctx.ClientIP(), ctx.Request.UserAgent())
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAccountLocked):
+			ctx.JSON(http.StatusTooManyRequests, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrInvalidTOTPCode):
+			ctx.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrTOTPNotPending):
+			ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "登录失败"))
+		}
+		return
+	}
+
+	token, err := middleware.GenerateAdminToken(*admin)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "生成令牌失败"))
+		return
+	}
+
+	admin.Password = ""
+	ctx.JSON(http.StatusOK, utils.CreateResponse(AdminLoginResponse{
+		Token:         token,
+		Admin:         *admin,
+		RecoveryCodes: recoveryCodes,
 	}))
 }
 
@@ -157,9 +297,14 @@ func (c *AuthController) WechatLogin(ctx *gin.Context) {
 		return
 	}
 
-	token, user, err := c.WechatService.Login(req.Code)
+	token, user, err := c.WechatService.Login(ctx.Request.Context(), req.Code)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, err.Error()))
+		if errors.Is(err, services.ErrWechatCodeInvalid) {
+			ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		slog.ErrorContext(ctx.Request.Context(), "微信登录失败", slog.String("error", err.Error()))
+		ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "微信登录失败，请稍后重试"))
 		return
 	}
 
@@ -169,47 +314,89 @@ func (c *AuthController) WechatLogin(ctx *gin.Context) {
 	}))
 }
 
-type WechatUserInfoRequest struct {
-	Nickname  string `json:"nickName"`
-	AvatarURL string `json:"avatarUrl"`
+// BindWechatPhoneRequest 绑定手机号请求，code 与 encryptedData/iv 二选一，优先使用 code
+type BindWechatPhoneRequest struct {
+	Code          string `json:"code"`          // getPhoneNumber 返回的动态令牌
+	EncryptedData string `json:"encryptedData"` // getPhoneNumber 返回的加密数据
+	IV            string `json:"iv"`            // 加密算法的初始向量
+}
+
+// BindWechatPhoneResponse 绑定手机号响应
+type BindWechatPhoneResponse struct {
+	Phone           string `json:"phone"`
+	PhoneVerifiedAt int64  `json:"phoneVerifiedAt"`
 }
 
-// UpdateWechatUserInfo godoc
-// @Summary [小程序]更新微信用户信息
-// @Description 更新微信用户的昵称和头像
+// BindWechatPhone godoc
+// @Summary [小程序]绑定微信手机号
+// @Description 通过小程序 getPhoneNumber 授权获取手机号并保存，认领心愿时默认使用该手机号。可传入 code（推荐），或传入 encryptedData 和 iv 使用登录时的 session_key 解密
 // @Tags 用户
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
-// @Param request body WechatUserInfoRequest true "微信用户信息"
-// @Success 200 {object} models.User
-// @Failure 400 {object} map[string]interface{} "请求参数错误"
-// @Failure 401 {object} map[string]interface{} "未授权"
+// @Param request body BindWechatPhoneRequest true "手机号授权数据"
+// @Success 200 {object} BindWechatPhoneResponse
+// @Failure 400 {object} map[string]interface{} "请求参数错误或授权数据无效"
+// @Failure 401 {object} map[string]interface{} "用户未登录或微信会话已失效"
 // @Failure 500 {object} map[string]interface{} "服务器错误"
-// @Router /api/v1/user/userinfo [put]
-func (c *AuthController) UpdateWechatUserInfo(ctx *gin.Context) {
-	userID, exists := ctx.Get("userID")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, "未认证"))
-		return
-	}
+// @Router /api/v1/user/phone [post]
+func (c *AuthController) BindWechatPhone(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
 
-	var req WechatUserInfoRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, "无效的请求参数"))
+	middleware.SetAuditAction(ctx, "user.bind_phone")
+	middleware.SetAuditTarget(ctx, "user", userID)
+
+	var req BindWechatPhoneRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.Code == "" && (req.EncryptedData == "" || req.IV == "")) {
+		ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, "请提供code或encryptedData和iv"))
 		return
 	}
 
-	if err := c.WechatService.UpdateUserInfo(userID.(uint), req.Nickname, req.AvatarURL); err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "更新用户信息失败"))
+	var (
+		user *models.User
+		err  error
+	)
+	if req.Code != "" {
+		user, err = c.WechatService.BindPhoneByCode(ctx.Request.Context(), userID.(uint), req.Code)
+	} else {
+		user, err = c.WechatService.BindPhoneByEncryptedData(userID.(uint), req.EncryptedData, req.IV)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWechatSessionExpired):
+			ctx.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrWechatPhoneInvalid):
+			ctx.JSON(http.StatusBadRequest, utils.CreateResponse(nil, services.ErrWechatPhoneInvalid.Error()))
+		default:
+			slog.ErrorContext(ctx.Request.Context(), "绑定手机号失败", slog.String("error", err.Error()))
+			ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "绑定手机号失败"))
+		}
 		return
 	}
 
-	var user models.User
-	if err := c.DB.First(&user, userID).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.CreateResponse(nil, "获取用户信息失败"))
-		return
+	middleware.SetAuditAfter(ctx, gin.H{"phone": maskPhone(user.Phone)})
+	ctx.JSON(http.StatusOK, utils.CreateResponse(BindWechatPhoneResponse{
+		Phone:           user.Phone,
+		PhoneVerifiedAt: user.PhoneVerifiedAt,
+	}))
+}
+
+// maskPhone 隐藏手机号中间四位，用于审计日志等不需要完整号码的场景
+func maskPhone(phone string) string {
+	if len(phone) < 8 {
+		return phone
 	}
+	return phone[:len(phone)-8] + "****" + phone[len(phone)-4:]
+}
 
-	ctx.JSON(http.StatusOK, utils.CreateResponse(user))
+// JWKS godoc
+// @Summary 获取令牌校验公钥
+// @Description 返回 JSON Web Key Set 格式的公钥，可按令牌头部的 kid 选择公钥校验签名。密钥轮换期间会同时返回新旧公钥
+// @Tags 用户
+// @Produce json
+// @Success 200 {object} middleware.JWKS
+// @Router /.well-known/jwks.json [get]
+func (c *AuthController) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, middleware.PublicJWKS())
 }
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wishes/services"
+)
+
+type HealthController struct {
+	healthService *services.HealthService
+}
+
+func NewHealthController(healthService *services.HealthService) *HealthController {
+	return &HealthController{
+		healthService: healthService,
+	}
+}
+
+// HealthzResponse 存活检查结果
+type HealthzResponse struct {
+	Status string `json:"status"`
+}
+
+// Healthz godoc
+// @Summary 存活检查
+// @Description 进程能够处理请求即返回 200，不检查数据库和存储，用于判断是否需要重启容器
+// @Tags 运维
+// @Produce json
+// @Success 200 {object} controllers.HealthzResponse
+// @Router /healthz [get]
+func (c *HealthController) Healthz(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, HealthzResponse{Status: "ok"})
+}
+
+// Readyz godoc
+// @Summary 就绪检查
+// @Description 检查数据库能否读写、数据表是否已迁移到当前版本以及存储后端能否访问，全部通过时返回 200。服务正在停止时始终返回 503
+// @Tags 运维
+// @Produce json
+// @Success 200 {object} services.Readiness
+// @Failure 503 {object} services.Readiness "存在未通过的检查项"
+// @Router /readyz [get]
+func (c *HealthController) Readyz(ctx *gin.Context) {
+	readiness := c.healthService.Readiness(ctx.Request.Context())
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(status, readiness)
+}
+
+// Version godoc
+// @Summary 构建信息
+// @Description 返回服务的版本、git 提交和构建时间
+// @Tags 运维
+// @Produce json
+// @Success 200 {object} services.BuildInfo
+// @Router /version [get]
+func (c *HealthController) Version(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.healthService.BuildInfo())
+}
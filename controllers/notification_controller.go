@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"wishes/middleware"
+	"wishes/models"
+	"wishes/services"
+	"wishes/utils"
+)
+
+type NotificationController struct {
+	notificationService *services.NotificationService
+}
+
+func NewNotificationController(notificationService *services.NotificationService) *NotificationController {
+	return &NotificationController{
+		notificationService: notificationService,
+	}
+}
+
+// SubscriptionTemplate 可订阅的通知及其模板
+type SubscriptionTemplate struct {
+	Event      models.WishRecordStatus `json:"event"` // 触发通知的记录状态
+	TemplateID string                  `json:"templateId"`
+	Remaining  int                     `json:"remaining"` // 剩余可发送条数
+}
+
+// GetSubscriptionsResponse 订阅消息授权情况
+type GetSubscriptionsResponse struct {
+	Items []SubscriptionTemplate `json:"items"`
+}
+
+// GetSubscriptions godoc
+// @Summary      [小程序]获取订阅消息授权情况
+// @Description  返回记录状态变更通知使用的模板ID以及当前用户的剩余授权条数，小程序据此调用 wx.requestSubscribeMessage
+// @Tags         通知
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  controllers.GetSubscriptionsResponse  "返回模板和授权情况"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/user/subscriptions [get]
+func (c *NotificationController) GetSubscriptions(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	consents, err := c.notificationService.GetConsents(userID.(uint))
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "获取订阅授权失败"))
+		return
+	}
+	remaining := make(map[string]int, len(consents))
+	for _, consent := range consents {
+		remaining[consent.TemplateID] = consent.Remaining
+	}
+
+	items := []SubscriptionTemplate{}
+	for event, templateID := range c.notificationService.Templates() {
+		items = append(items, SubscriptionTemplate{
+			Event:      event,
+			TemplateID: templateID,
+			Remaining:  remaining[templateID],
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Event < items[j].Event })
+
+	ctx.JSON(200, utils.CreateResponse(GetSubscriptionsResponse{Items: items}))
+}
+
+// UpdateSubscriptionsRequest wx.requestSubscribeMessage 的授权结果
+type UpdateSubscriptionsRequest struct {
+	// 模板ID -> accept、reject、ban 或 filter
+	Results map[string]string `json:"results" binding:"required"`
+}
+
+// UpdateSubscriptions godoc
+// @Summary      [小程序]上报订阅消息授权结果
+// @Description  将 wx.requestSubscribeMessage 的返回结果原样上报。每次 accept 可接收一条通知，reject 或 ban 会清空剩余条数
+// @Tags         通知
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      UpdateSubscriptionsRequest  true  "授权结果"
+// @Success      200  {object}  controllers.GetSubscriptionsResponse  "返回更新后的授权情况"
+// @Failure      400  {object}  map[string]interface{}  "请求数据错误"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/user/subscriptions [post]
+func (c *NotificationController) UpdateSubscriptions(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "user.subscribe")
+	middleware.SetAuditTarget(ctx, "user", userID)
+
+	var req UpdateSubscriptionsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求数据"))
+		return
+	}
+	middleware.SetAuditAfter(ctx, req.Results)
+
+	if err := c.notificationService.RecordConsent(userID.(uint), req.Results); err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "保存订阅授权失败"))
+		return
+	}
+
+	c.GetSubscriptions(ctx)
+}
+
+type GetNotificationDeliveriesResponse struct {
+	Items      []models.NotificationDelivery `json:"items"`
+	Pagination utils.Pagination              `json:"pagination"`
+}
+
+// GetNotificationDeliveries godoc
+// @Summary      [后台]查询订阅消息投递记录
+// @Description  按用户、记录和投递状态查询订阅消息的发送情况
+// @Tags         通知
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        userId       query    int     false  "用户ID"
+// @Param        recordId     query    int     false  "认领记录ID"
+// @Param        state        query    string  false  "投递状态：pending、sent、failed、skipped"
+// @Param        pageIndex    query    int     false  "页码，默认1"
+// @Param        pageSize     query    int     false  "每页数量，默认10"
+// @Success      200  {object}  controllers.GetNotificationDeliveriesResponse  "返回投递记录列表"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/notifications [get]
+func (c *NotificationController) GetNotificationDeliveries(ctx *gin.Context) {
+	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
+	pageIndex, err := strconv.Atoi(pageIndexStr)
+	if err != nil || pageIndex < 1 {
+		pageIndex = 1
+	}
+
+	pageSizeStr := ctx.DefaultQuery("pageSize", "10")
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	filter := services.NotificationDeliveryFilter{
+		State: models.NotificationState(ctx.Query("state")),
+	}
+	if userID, err := strconv.ParseUint(ctx.Query("userId"), 10, 32); err == nil {
+		filter.UserID = uint(userID)
+	}
+	if recordID, err := strconv.ParseUint(ctx.Query("recordId"), 10, 32); err == nil {
+		filter.RecordID = uint(recordID)
+	}
+
+	deliveries, total, err := c.notificationService.GetDeliveries(filter, pageIndex, pageSize)
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "获取投递记录失败"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(GetNotificationDeliveriesResponse{
+		Items:      deliveries,
+		Pagination: utils.NewPagination(total, pageIndex, pageSize),
+	}))
+}
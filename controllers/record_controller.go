@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"errors"
+	"log/slog"
 	"sort"
 	"strconv"
+	"wishes/middleware"
 	"wishes/models"
 	"wishes/services"
 	"wishes/utils"
@@ -11,14 +14,20 @@ import (
 )
 
 type RecordController struct {
-	recordService *services.RecordService
+	recordService       *services.RecordService
+	notificationService *services.NotificationService
+	storageService      *services.StorageService
 }
 
 func NewRecordController(
 	recordService *services.RecordService,
+	notificationService *services.NotificationService,
+	storageService *services.StorageService,
 ) *RecordController {
 	return &RecordController{
-		recordService: recordService,
+		recordService:       recordService,
+		notificationService: notificationService,
+		storageService:      storageService,
 	}
 }
 
@@ -46,17 +55,8 @@ func (c *RecordController) GetWishRecords(ctx *gin.Context) {
 		return
 	}
 
-	userType, exists := ctx.Get("userType")
-	if !exists || userType != "user" {
-		ctx.JSON(401, utils.CreateResponse(nil, "只有用户可以查看点亮的心愿"))
-		return
-	}
-
-	isAdmin := false
-	isAdminValue, exists := ctx.Get("isAdmin")
-	if exists {
-		isAdmin, _ = isAdminValue.(bool)
-	}
+	// 拥有查看全部记录权限的志愿者可以查看所有用户的记录
+	isAdmin := middleware.HasPermission(ctx, middleware.PermRecordReadAll)
 
 	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
 	pageIndex, err := strconv.Atoi(pageIndexStr)
@@ -77,6 +77,9 @@ func (c *RecordController) GetWishRecords(ctx *gin.Context) {
 		ctx.JSON(500, utils.CreateResponse(nil, "获取心愿列表失败"))
 		return
 	}
+	for i := range records {
+		c.storageService.SignRecord(ctx.Request.Context(), &records[i])
+	}
 
 	response := GetWishRecordsResponse{
 		Items:      records,
@@ -100,12 +103,6 @@ func (c *RecordController) GetWishRecords(ctx *gin.Context) {
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /api/v1/admin/records [get]
 func (c *RecordController) GetAllRecords(ctx *gin.Context) {
-	userType, exists := ctx.Get("userType")
-	if !exists || userType != "admin" {
-		ctx.JSON(401, utils.CreateResponse(nil, "只有管理员可以查看所有记录"))
-		return
-	}
-
 	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
 	pageIndex, err := strconv.Atoi(pageIndexStr)
 	if err != nil || pageIndex < 1 {
@@ -125,6 +122,9 @@ func (c *RecordController) GetAllRecords(ctx *gin.Context) {
 		ctx.JSON(500, utils.CreateResponse(nil, "获取记录列表失败"))
 		return
 	}
+	for i := range records {
+		c.storageService.SignRecord(ctx.Request.Context(), &records[i])
+	}
 
 	response := GetWishRecordsResponse{
 		Items:      records,
@@ -199,7 +199,9 @@ func (c *RecordController) GetRecordByID(ctx *gin.Context) {
 	userID, exists := ctx.Get("userID")
 	userType, _ := ctx.Get("userType")
 
-	if userType == "admin" || (exists && userType == "user" && record.DonorID == userID.(uint)) {
+	if middleware.HasPermission(ctx, middleware.PermRecordReadAll) || (exists && userType == "user" && record.DonorID == userID.(uint)) {
+		c.storageService.SignRecord(ctx.Request.Context(), record)
+
 		// 构建进度数组
 		var progressItems []ProgressItem
 
@@ -354,12 +356,16 @@ func (c *RecordController) UpdateRecordStatus(ctx *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditAction(ctx, "record.update_status")
+	middleware.SetAuditTarget(ctx, "record", id)
+
 	// 获取记录详情，检查是否存在
-	_, err = c.recordService.GetRecordByIDWithoutRecursion(uint(id))
+	record, err := c.recordService.GetRecordByIDWithoutRecursion(uint(id))
 	if err != nil {
 		ctx.JSON(404, utils.CreateResponse(nil, "记录不存在"))
 		return
 	}
+	middleware.SetAuditBefore(ctx, record)
 
 	// 解析请求体
 	var req UpdateRecordStatusRequest
@@ -368,24 +374,43 @@ func (c *RecordController) UpdateRecordStatus(ctx *gin.Context) {
 		return
 	}
 
-	// 构造更新参数
-	params := map[string]any{
-		"shippingNumber":      req.ShippingNumber,
-		"confirmationMessage": req.ConfirmationMessage,
-		"confirmationPhotos":  req.ConfirmationPhotos,
-		"deliveryNumber":      req.DeliveryNumber,
-		"receiptMessage":      req.ReceiptMessage,
-		"receiptPhotos":       req.ReceiptPhotos,
-		"platformGiftMessage": req.PlatformGiftMessage,
-		"platformGiftPhotos":  req.PlatformGiftPhotos,
-		"ownerGiftMessage":    req.OwnerGiftMessage,
-		"ownerGiftPhotos":     req.OwnerGiftPhotos,
-	}
-
-	// 更新状态
-	if err := c.recordService.UpdateRecordStatus(uint(id), req.Status, params); err != nil {
-		ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
-		return
+	if middleware.HasPermission(ctx, middleware.PermRecordTransition) {
+		params := map[string]any{
+			"shippingNumber":      req.ShippingNumber,
+			"confirmationMessage": req.ConfirmationMessage,
+			"confirmationPhotos":  req.ConfirmationPhotos,
+			"deliveryNumber":      req.DeliveryNumber,
+			"receiptMessage":      req.ReceiptMessage,
+			"receiptPhotos":       req.ReceiptPhotos,
+			"platformGiftMessage": req.PlatformGiftMessage,
+			"platformGiftPhotos":  req.PlatformGiftPhotos,
+			"ownerGiftMessage":    req.OwnerGiftMessage,
+			"ownerGiftPhotos":     req.OwnerGiftPhotos,
+		}
+		if err := c.recordService.UpdateRecordStatus(uint(id), req.Status, params, uploadOwner(ctx)); err != nil {
+			if errors.Is(err, services.ErrUploadNotOwned) {
+				ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+				return
+			}
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+	} else {
+		// 没有状态变更权限的捐赠者只能把自己待寄出的记录改为待确认，且只能填写寄送单号
+		userID, _ := ctx.Get("userID")
+		userType, _ := ctx.Get("userType")
+		if userType != "user" || req.Status != models.StatusPendingConfirmation {
+			ctx.JSON(403, utils.CreateResponse(nil, "只能为自己待寄出的认领记录填写寄送单号"))
+			return
+		}
+		if err := c.recordService.ShipRecord(uint(id), userID.(uint), req.ShippingNumber); err != nil {
+			if errors.Is(err, services.ErrRecordNotShippable) {
+				ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+				return
+			}
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+			return
+		}
 	}
 
 	// 获取更新后的记录
@@ -394,7 +419,16 @@ func (c *RecordController) UpdateRecordStatus(ctx *gin.Context) {
 		ctx.JSON(500, utils.CreateResponse(nil, "获取更新后的记录失败"))
 		return
 	}
+	middleware.SetAuditAfter(ctx, updatedRecord)
+
+	// 通知捐赠者，发送失败不影响状态变更
+	if updatedRecord.Status != record.Status {
+		if err := c.notificationService.NotifyRecordStatus(updatedRecord); err != nil {
+			slog.ErrorContext(ctx.Request.Context(), "创建记录的状态通知失败", slog.Uint64("record_id", uint64(updatedRecord.ID)), slog.String("error", err.Error()))
+		}
+	}
 
+	c.storageService.SignRecord(ctx.Request.Context(), updatedRecord)
 	ctx.JSON(200, utils.CreateResponse(updatedRecord))
 }
 
@@ -427,6 +461,9 @@ func (c *RecordController) UpdateShippingInfo(ctx *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditAction(ctx, "record.update_shipping_info")
+	middleware.SetAuditTarget(ctx, "record", id)
+
 	// 获取用户ID和类型
 	userID, exists := ctx.Get("userID")
 	if !exists {
@@ -441,12 +478,15 @@ func (c *RecordController) UpdateShippingInfo(ctx *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditBefore(ctx, record)
+
 	// 判断是否有权限修改（只有记录捐赠者或管理员可以修改）
 	userTypeValue, _ := ctx.Get("userType")
 	userType, _ := userTypeValue.(string)
 
-	isAdmin := userType == "admin"
-	isDonor := record.DonorID == userID.(uint)
+	isAdmin := middleware.HasPermission(ctx, middleware.PermRecordEdit)
+	isDonor := userType == "user" && record.DonorID == userID.(uint) &&
+		middleware.HasPermission(ctx, middleware.PermRecordEditOwn)
 
 	if !isAdmin && !isDonor {
 		ctx.JSON(401, utils.CreateResponse(nil, "无权修改此记录"))
@@ -472,6 +512,8 @@ func (c *RecordController) UpdateShippingInfo(ctx *gin.Context) {
 		ctx.JSON(500, utils.CreateResponse(nil, "获取更新后的记录失败"))
 		return
 	}
+	middleware.SetAuditAfter(ctx, updatedRecord)
 
+	c.storageService.SignRecord(ctx.Request.Context(), updatedRecord)
 	ctx.JSON(200, utils.CreateResponse(updatedRecord))
 }
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"wishes/middleware"
+	"wishes/models"
+	"wishes/services"
+	"wishes/utils"
+)
+
+type RestrictionController struct {
+	restrictionService *services.RestrictionService
+}
+
+func NewRestrictionController(restrictionService *services.RestrictionService) *RestrictionController {
+	return &RestrictionController{
+		restrictionService: restrictionService,
+	}
+}
+
+// GetRestrictions godoc
+// @Summary      [后台]查询用户限制记录
+// @Description  获取小程序用户的全部限制记录，包括已解除和已到期的
+// @Tags         用户管理
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "用户ID"
+// @Success      200  {array}   models.UserRestriction  "返回限制记录"
+// @Failure      400  {object}  map[string]interface{}  "无效的用户ID"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/users/{id}/restrictions [get]
+func (c *RestrictionController) GetRestrictions(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的用户ID"))
+		return
+	}
+
+	restrictions, err := c.restrictionService.GetRestrictions(uint(userID))
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "获取限制记录失败"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(restrictions))
+}
+
+// RestrictUserRequest 限制用户请求
+type RestrictUserRequest struct {
+	Level     models.RestrictionLevel `json:"level" binding:"required"` // ban 或 browse_only
+	Reason    string                  `json:"reason" binding:"required"`
+	ExpiresAt int64                   `json:"expiresAt"` // 到期时间（秒级时间戳），0 或不传表示永久
+}
+
+// RestrictUser godoc
+// @Summary      [后台]封禁或限制用户
+// @Description  封禁（ban）的用户不能访问任何需要登录的接口，只读（browse_only）的用户只能浏览，不能认领心愿或提交修改。已签发的令牌立即受限，已有的限制会被替换
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      int                  true  "用户ID"
+// @Param        request  body      RestrictUserRequest  true  "限制信息"
+// @Success      200  {object}  models.UserRestriction  "返回新的限制"
+// @Failure      400  {object}  map[string]interface{}  "请求数据错误"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      404  {object}  map[string]interface{}  "用户不存在"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/users/{id}/restrictions [post]
+func (c *RestrictionController) RestrictUser(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的用户ID"))
+		return
+	}
+	adminID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "user.restrict")
+	middleware.SetAuditTarget(ctx, "user", userID)
+
+	var req RestrictUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求数据"))
+		return
+	}
+
+	if before, err := c.restrictionService.GetActiveRestriction(uint(userID)); err == nil && before != nil {
+		middleware.SetAuditBefore(ctx, before)
+	}
+
+	restriction, err := c.restrictionService.Restrict(uint(userID), req.Level, req.Reason, req.ExpiresAt, adminID.(uint))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRestriction):
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ctx.JSON(404, utils.CreateResponse(nil, "用户不存在"))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "限制用户失败"))
+		}
+		return
+	}
+
+	middleware.SetAuditAfter(ctx, restriction)
+	ctx.JSON(200, utils.CreateResponse(restriction))
+}
+
+// LiftRestriction godoc
+// @Summary      [后台]解除用户限制
+// @Description  提前解除用户当前生效的封禁或只读限制，限制记录会保留
+// @Tags         用户管理
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "用户ID"
+// @Success      200  {object}  map[string]interface{}  "解除成功"
+// @Failure      400  {object}  map[string]interface{}  "无效的用户ID"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录或无权限"
+// @Failure      404  {object}  map[string]interface{}  "该用户没有生效中的限制"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/users/{id}/restrictions [delete]
+func (c *RestrictionController) LiftRestriction(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的用户ID"))
+		return
+	}
+	adminID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "user.lift_restriction")
+	middleware.SetAuditTarget(ctx, "user", userID)
+
+	if before, err := c.restrictionService.GetActiveRestriction(uint(userID)); err == nil && before != nil {
+		middleware.SetAuditBefore(ctx, before)
+	}
+
+	if err := c.restrictionService.Lift(uint(userID), adminID.(uint)); err != nil {
+		if errors.Is(err, services.ErrRestrictionNotFound) {
+			ctx.JSON(404, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		ctx.JSON(500, utils.CreateResponse(nil, "解除限制失败"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(nil))
+}
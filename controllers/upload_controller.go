@@ -1,7 +1,12 @@
 package controllers
 
 import (
+	"errors"
+	"strconv"
+	"wishes/middleware"
+	"wishes/models"
 	"wishes/services"
+	"wishes/storage"
 	"wishes/utils"
 
 	"github.com/gin-gonic/gin"
@@ -9,31 +14,37 @@ import (
 
 type UploadController struct {
 	storageService *services.StorageService
+	uploadTracker  *services.UploadTracker
 }
 
 // NewUploadController 创建上传控制器实例
-func NewUploadController(storageService *services.StorageService) *UploadController {
+func NewUploadController(storageService *services.StorageService, uploadTracker *services.UploadTracker) *UploadController {
 	return &UploadController{
 		storageService: storageService,
+		uploadTracker:  uploadTracker,
 	}
 }
 
 // UploadImageResponse 上传图片响应数据
 type UploadImageResponse struct {
-	URL string `json:"url"` // 上传成功后的图片URL
+	URL       string                 `json:"url"` // 去除元数据后的原图URL
+	Original  services.UploadedImage `json:"original"`
+	Display   services.UploadedImage `json:"display"`   // 压缩后的展示图，心愿墙使用
+	Thumbnail services.UploadedImage `json:"thumbnail"` // 正方形缩略图
 }
 
 // UploadImage godoc
 // @Summary      上传图片
-// @Description  上传图片到腾讯云对象存储
+// @Description  上传图片到配置的存储后端（腾讯云对象存储或本地磁盘）。图片会被重新编码以去除EXIF等元数据（包括GPS位置）并按拍摄方向自动旋转，同时生成展示图和正方形缩略图
 // @Tags         文件上传
 // @Accept       multipart/form-data
 // @Produce      json
 // @Param        file    formData    file     true  "图片文件"
-// @Param        directory  formData    string  false  "存储目录，例如: images/avatar"
-// @Success      200  {object}  controllers.UploadImageResponse  "上传成功，返回图片URL"
+// @Param        purpose  formData    string  true  "上传用途：avatar、wish_photo、shipping_proof、receipt_photo 或 gift_photo"
+// @Success      200  {object}  controllers.UploadImageResponse  "上传成功，返回原图、展示图和缩略图"
 // @Failure      400  {object}  map[string]interface{}  "请求参数错误"
 // @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      403  {object}  map[string]interface{}  "当前角色不能上传该用途的文件"
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /api/v1/upload/image [post]
 func (c *UploadController) UploadImage(ctx *gin.Context) {
@@ -51,32 +62,230 @@ func (c *UploadController) UploadImage(ctx *gin.Context) {
 		return
 	}
 
-	// 检查文件类型
-	contentType := file.Header.Get("Content-Type")
-	if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" && contentType != "image/webp" {
-		ctx.JSON(400, utils.CreateResponse(nil, "只支持上传JPG、PNG、GIF或WEBP格式的图片"))
+	// 存储路径由用途和上传者决定，客户端不能指定
+	purpose := ctx.PostForm("purpose")
+
+	// 文件类型由服务根据内容识别，这里不检查客户端声明的 Content-Type
+
+	// 上传图片到存储后端
+	images, err := c.storageService.UploadImage(uploadOwner(ctx), file, purpose)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadForbidden) {
+			ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		if errors.Is(err, services.ErrInvalidUpload) {
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		ctx.JSON(500, utils.CreateResponse(nil, "上传图片失败: "+err.Error()))
+		return
+	}
+
+	// 返回成功响应和各规格的图片URL
+	response := UploadImageResponse{
+		URL:       images[0].URL,
+		Original:  images[0],
+		Display:   images[1],
+		Thumbnail: images[2],
+	}
+
+	middleware.SetAuditAction(ctx, "upload.image")
+	middleware.SetAuditAfter(ctx, response)
+
+	ctx.JSON(200, utils.CreateResponse(response))
+}
+
+// CreateUploadTicketRequest 申请直传凭证请求
+type CreateUploadTicketRequest struct {
+	ContentType string `json:"contentType" binding:"required"` // image/jpeg、image/png、image/gif 或 image/webp
+	Size        int64  `json:"size" binding:"required"`        // 文件大小，单位字节，上传时必须一致
+	Purpose     string `json:"purpose" binding:"required"`     // 上传用途：avatar、wish_photo、shipping_proof、receipt_photo 或 gift_photo
+}
+
+// CreateUploadTicketResponse 直传凭证
+type CreateUploadTicketResponse struct {
+	Ticket  models.UploadTicket      `json:"ticket"`
+	Request storage.PresignedRequest `json:"request"` // 客户端按此发送上传请求，请求头必须原样携带
+}
+
+// CreateUploadTicket godoc
+// @Summary      申请直传凭证
+// @Description  签发短期有效的上传地址，客户端直接把文件上传到对象存储，不经过服务器。文件路径由服务端生成，类型和大小在签名中固定。上传完成后需调用确认接口
+// @Tags         文件上传
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      CreateUploadTicketRequest  true  "文件信息"
+// @Success      200  {object}  controllers.CreateUploadTicketResponse  "返回上传凭证和上传请求"
+// @Failure      400  {object}  map[string]interface{}  "文件类型或大小不符合要求"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      403  {object}  map[string]interface{}  "当前角色不能上传该用途的文件"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/upload/tickets [post]
+func (c *UploadController) CreateUploadTicket(ctx *gin.Context) {
+	middleware.SetAuditAction(ctx, "upload.create_ticket")
+
+	var req CreateUploadTicketRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求数据"))
 		return
 	}
 
-	// 检查文件大小（限制为5MB）
-	if file.Size > 5*1024*1024 {
-		ctx.JSON(400, utils.CreateResponse(nil, "图片大小不能超过5MB"))
+	ticket, request, err := c.storageService.CreateUploadTicket(ctx.Request.Context(), uploadOwner(ctx), req.Purpose, req.ContentType, req.Size)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUpload):
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrUploadForbidden):
+			ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "申请上传凭证失败"))
+		}
 		return
 	}
 
-	// 获取存储目录参数，默认为"images"
-	directory := ctx.DefaultPostForm("directory", "images")
+	middleware.SetAuditTarget(ctx, "upload_ticket", ticket.ID)
+	middleware.SetAuditAfter(ctx, ticket)
+	ctx.JSON(200, utils.CreateResponse(CreateUploadTicketResponse{
+		Ticket:  *ticket,
+		Request: *request,
+	}))
+}
+
+// DirectUpload godoc
+// @Summary      中转上传
+// @Description  存储后端不支持预签名时（本地存储），由服务器接收直传凭证对应的文件。地址由申请直传凭证接口返回，令牌即授权，无需登录
+// @Tags         文件上传
+// @Accept       image/jpeg,image/png,image/gif,image/webp
+// @Produce      json
+// @Param        token  path      string  true  "上传令牌"
+// @Success      200  {object}  map[string]interface{}  "上传成功"
+// @Failure      400  {object}  map[string]interface{}  "文件与凭证不一致"
+// @Failure      404  {object}  map[string]interface{}  "上传凭证不存在"
+// @Failure      410  {object}  map[string]interface{}  "上传凭证已过期或已使用"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/upload/direct/{token} [put]
+func (c *UploadController) DirectUpload(ctx *gin.Context) {
+	middleware.SetAuditAction(ctx, "upload.direct")
 
-	// 上传图片到腾讯云COS
-	fileURL, err := c.storageService.UploadImage(file, directory)
+	ticket, err := c.storageService.ReceiveDirectUpload(ctx.Request.Context(), ctx.Param("token"),
+		ctx.ContentType(), ctx.Request.ContentLength, ctx.Request.Body)
 	if err != nil {
-		ctx.JSON(500, utils.CreateResponse(nil, "上传图片失败: "+err.Error()))
+		switch {
+		case errors.Is(err, services.ErrUploadTicketNotFound):
+			ctx.JSON(404, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrUploadTicketExpired), errors.Is(err, services.ErrUploadTicketUsed):
+			ctx.JSON(410, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrUploadMismatch):
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "上传文件失败"))
+		}
 		return
 	}
 
-	// 返回成功响应和图片URL
-	response := UploadImageResponse{
-		URL: fileURL,
+	middleware.SetAuditTarget(ctx, "upload_ticket", ticket.ID)
+	ctx.JSON(200, utils.CreateResponse(nil))
+}
+
+// CompleteUploadResponse 确认上传的结果
+type CompleteUploadResponse struct {
+	Ticket models.UploadTicket `json:"ticket"`
+	URL    string              `json:"url"` // 文件访问地址
+}
+
+// CompleteUploadTicket godoc
+// @Summary      确认直传完成
+// @Description  检查文件已上传到存储且类型和大小与凭证一致，重新编码并去除 EXIF 等元数据后返回原图地址，同时生成展示图和缩略图。不一致的文件会被删除。重复确认返回相同结果
+// @Tags         文件上传
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "上传凭证ID"
+// @Success      200  {object}  controllers.CompleteUploadResponse  "返回文件地址"
+// @Failure      400  {object}  map[string]interface{}  "文件尚未上传或与凭证不一致"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      404  {object}  map[string]interface{}  "上传凭证不存在"
+// @Failure      410  {object}  map[string]interface{}  "上传凭证已过期或已使用"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/upload/tickets/{id}/complete [post]
+func (c *UploadController) CompleteUploadTicket(ctx *gin.Context) {
+	ticketID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的上传凭证ID"))
+		return
 	}
-	ctx.JSON(200, utils.CreateResponse(response))
+
+	middleware.SetAuditAction(ctx, "upload.complete")
+	middleware.SetAuditTarget(ctx, "upload_ticket", ticketID)
+
+	ticket, fileURL, err := c.storageService.CompleteUploadTicket(ctx.Request.Context(), uploadOwner(ctx), uint(ticketID))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUploadTicketNotFound):
+			ctx.JSON(404, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrUploadTicketExpired), errors.Is(err, services.ErrUploadTicketUsed):
+			ctx.JSON(410, utils.CreateResponse(nil, err.Error()))
+		case errors.Is(err, services.ErrUploadNotReceived), errors.Is(err, services.ErrUploadMismatch):
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+		default:
+			ctx.JSON(500, utils.CreateResponse(nil, "确认上传失败"))
+		}
+		return
+	}
+
+	middleware.SetAuditAfter(ctx, gin.H{"key": ticket.ResultKey(), "url": fileURL})
+	ctx.JSON(200, utils.CreateResponse(CompleteUploadResponse{
+		Ticket: *ticket,
+		URL:    fileURL,
+	}))
+}
+
+// CollectGarbageRequest 清理上传文件请求
+type CollectGarbageRequest struct {
+	DryRun bool `json:"dryRun"` // 只列出将要删除的文件，不实际删除
+}
+
+// CollectGarbage godoc
+// @Summary      清理上传文件
+// @Description  立即删除不再被心愿、认领记录或头像引用且超过保留期的上传文件（后台任务也会定期执行）。dryRun 为 true 时只返回将要删除的文件
+// @Tags         文件上传
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      CollectGarbageRequest  false  "清理选项"
+// @Success      200  {object}  services.GCReport  "清理结果"
+// @Failure      401  {object}  map[string]interface{}  "未登录"
+// @Failure      403  {object}  map[string]interface{}  "没有权限"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/admin/uploads/gc [post]
+func (c *UploadController) CollectGarbage(ctx *gin.Context) {
+	var req CollectGarbageRequest
+	// 请求体可以为空
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(400, utils.CreateResponse(nil, "请求参数错误"))
+			return
+		}
+	}
+
+	middleware.SetAuditAction(ctx, "upload.gc")
+	middleware.SetAuditBefore(ctx, req)
+
+	report, err := c.uploadTracker.CollectGarbage(ctx.Request.Context(), req.DryRun)
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "清理上传文件失败"))
+		return
+	}
+
+	middleware.SetAuditAfter(ctx, gin.H{"removed": len(report.Removed), "bytes": report.Bytes, "failed": report.Failed})
+	ctx.JSON(200, utils.CreateResponse(report))
+}
+
+func uploadOwner(ctx *gin.Context) services.UploadOwner {
+	userID, _ := ctx.Get("userID")
+	userType, _ := ctx.Get("userType")
+	id, _ := userID.(uint)
+	t, _ := userType.(string)
+	return services.UploadOwner{UserID: id, UserType: t, Role: middleware.CurrentRole(ctx)}
 }
@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"strconv"
+	"wishes/middleware"
 	"wishes/models"
 	"wishes/services"
 	"wishes/utils"
@@ -38,12 +40,6 @@ type GetAdminUsersResponse struct {
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /api/v1/users/admin [get]
 func (c *UserController) GetAdminUsers(ctx *gin.Context) {
-	userType, exists := ctx.Get("userType")
-	if !exists || userType != "admin" {
-		ctx.JSON(401, utils.CreateResponse(nil, "只有系统管理员可以查看用户列表"))
-		return
-	}
-
 	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
 	pageIndex, err := strconv.Atoi(pageIndexStr)
 	if err != nil || pageIndex < 1 {
@@ -83,12 +79,6 @@ func (c *UserController) GetAdminUsers(ctx *gin.Context) {
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /api/v1/users/regular [get]
 func (c *UserController) GetNonAdminUsers(ctx *gin.Context) {
-	userType, exists := ctx.Get("userType")
-	if !exists || userType != "admin" {
-		ctx.JSON(401, utils.CreateResponse(nil, "只有系统管理员可以查看用户列表"))
-		return
-	}
-
 	pageIndexStr := ctx.DefaultQuery("pageIndex", "1")
 	pageIndex, err := strconv.Atoi(pageIndexStr)
 	if err != nil || pageIndex < 1 {
@@ -135,12 +125,6 @@ type UpdateUserAdminRequest struct {
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /api/v1/users/{id}/admin [put]
 func (c *UserController) UpdateUserAdmin(ctx *gin.Context) {
-	userType, exists := ctx.Get("userType")
-	if !exists || userType != "admin" {
-		ctx.JSON(401, utils.CreateResponse(nil, "只有系统管理员可以更新用户权限"))
-		return
-	}
-
 	userIDStr := ctx.Param("id")
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
@@ -148,12 +132,19 @@ func (c *UserController) UpdateUserAdmin(ctx *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditAction(ctx, "user.update_admin")
+	middleware.SetAuditTarget(ctx, "user", userID)
+
 	var req UpdateUserAdminRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求数据"))
 		return
 	}
 
+	if user, err := c.userService.GetUserByID(uint(userID)); err == nil {
+		middleware.SetAuditBefore(ctx, user)
+	}
+
 	if err := c.userService.UpdateUserAdminStatus(uint(userID), req.IsAdmin); err != nil {
 		if err.Error() == "未找到ID为"+userIDStr+"的用户" {
 			ctx.JSON(404, utils.CreateResponse(nil, "用户不存在"))
@@ -163,6 +154,10 @@ func (c *UserController) UpdateUserAdmin(ctx *gin.Context) {
 		return
 	}
 
+	if user, err := c.userService.GetUserByID(uint(userID)); err == nil {
+		middleware.SetAuditAfter(ctx, user)
+	}
+
 	message := "更新用户权限成功"
 	if req.IsAdmin {
 		message = "已将用户设置为管理员"
@@ -172,3 +167,103 @@ func (c *UserController) UpdateUserAdmin(ctx *gin.Context) {
 
 	ctx.JSON(200, utils.CreateResponse(nil, message))
 }
+
+// UserProfileResponse 小程序用户个人资料
+type UserProfileResponse struct {
+	ID        uint        `json:"id"`
+	Nickname  string      `json:"nickname"`
+	AvatarURL string      `json:"avatarUrl"`
+	Role      models.Role `json:"role"`
+	IsAdmin   bool        `json:"isAdmin"`
+	Phone     string      `json:"phone"` // 经微信验证的手机号，未绑定时为空
+	CreatedAt int64       `json:"createdAt"`
+}
+
+func newUserProfileResponse(user *models.User) UserProfileResponse {
+	return UserProfileResponse{
+		ID:        user.ID,
+		Nickname:  user.Nickname,
+		AvatarURL: user.AvatarURL,
+		Role:      user.Role(),
+		IsAdmin:   user.IsAdmin,
+		Phone:     user.Phone,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+// GetMe godoc
+// @Summary      [小程序]获取当前用户资料
+// @Description  获取当前登录的小程序用户的个人资料
+// @Tags         用户
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  controllers.UserProfileResponse  "返回个人资料"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      404  {object}  map[string]interface{}  "用户不存在"
+// @Router       /api/v1/user/me [get]
+func (c *UserController) GetMe(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	user, err := c.userService.GetUserByID(userID.(uint))
+	if err != nil {
+		ctx.JSON(404, utils.CreateResponse(nil, "用户不存在"))
+		return
+	}
+
+	ctx.JSON(200, utils.CreateResponse(newUserProfileResponse(user)))
+}
+
+// UpdateUserProfileRequest 更新小程序用户资料请求，未传的字段保持不变
+type UpdateUserProfileRequest struct {
+	Nickname  *string `json:"nickname"`
+	AvatarURL *string `json:"avatarUrl"`
+}
+
+// UpdateMe godoc
+// @Summary      [小程序]更新当前用户资料
+// @Description  更新昵称和头像，未传的字段保持不变。昵称不超过32个字符，头像须为http或https链接
+// @Tags         用户
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      UpdateUserProfileRequest  true  "个人资料"
+// @Success      200  {object}  controllers.UserProfileResponse  "返回更新后的个人资料"
+// @Failure      400  {object}  map[string]interface{}  "请求数据错误"
+// @Failure      401  {object}  map[string]interface{}  "用户未登录"
+// @Failure      500  {object}  map[string]interface{}  "服务器错误"
+// @Router       /api/v1/user/me [put]
+func (c *UserController) UpdateMe(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	middleware.SetAuditAction(ctx, "user.update_profile")
+	middleware.SetAuditTarget(ctx, "user", userID)
+
+	var req UpdateUserProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, utils.CreateResponse(nil, "无效的请求数据"))
+		return
+	}
+
+	if user, err := c.userService.GetUserByID(userID.(uint)); err == nil {
+		middleware.SetAuditBefore(ctx, newUserProfileResponse(user))
+	}
+
+	user, err := c.userService.UpdateProfile(userID.(uint), req.Nickname, req.AvatarURL)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidProfile) {
+			ctx.JSON(400, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		if errors.Is(err, services.ErrUploadNotOwned) {
+			ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+			return
+		}
+		ctx.JSON(500, utils.CreateResponse(nil, "更新个人资料失败"))
+		return
+	}
+
+	response := newUserProfileResponse(user)
+	middleware.SetAuditAfter(ctx, response)
+	ctx.JSON(200, utils.CreateResponse(response))
+}
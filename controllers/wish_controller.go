@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -8,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/xuri/excelize/v2"
 
+	"wishes/middleware"
 	"wishes/models"
 	"wishes/services"
 	"wishes/utils"
@@ -17,17 +19,25 @@ type WishController struct {
 	wishService   *services.WishService
 	recordService *services.RecordService
 	userService   *services.UserService
+	// restrictionService 认领前再次检查用户限制，JWTAuth 之外的第二道防线
+	restrictionService *services.RestrictionService
+	// storageService 为返回的照片地址签名
+	storageService *services.StorageService
 }
 
 func NewWishController(
 	wishService *services.WishService,
 	recordService *services.RecordService,
 	userService *services.UserService,
+	restrictionService *services.RestrictionService,
+	storageService *services.StorageService,
 ) *WishController {
 	return &WishController{
-		wishService:   wishService,
-		recordService: recordService,
-		userService:   userService,
+		wishService:        wishService,
+		recordService:      recordService,
+		userService:        userService,
+		restrictionService: restrictionService,
+		storageService:     storageService,
 	}
 }
 
@@ -38,7 +48,7 @@ type GetWishesResponse struct {
 
 // GetWishes godoc
 // @Summary      [小程序/后台]获取心愿列表
-// @Description  获取心愿列表，支持分页和过滤
+// @Description  获取心愿列表，支持分页和过滤。未登录或没有权限的调用方只能看到心愿照片的公开缩略图，志愿者和管理员携带令牌时返回原图的签名地址
 // @Tags         心愿
 // @Accept       json
 // @Produce      json
@@ -79,6 +89,14 @@ func (c *WishController) GetWishes(ctx *gin.Context) {
 		ctx.JSON(500, utils.CreateResponse(nil, "获取心愿列表失败"))
 		return
 	}
+	// 孩子的照片原图只对志愿者和管理员签名
+	for i := range wishes {
+		if middleware.HasPermission(ctx, middleware.PermWishPhoto) {
+			c.storageService.SignWish(ctx.Request.Context(), &wishes[i].Wish)
+		} else {
+			c.storageService.PublicWish(&wishes[i].Wish)
+		}
+	}
 
 	ctx.JSON(200, utils.CreateResponse(GetWishesResponse{
 		Items:      wishes,
@@ -125,11 +143,21 @@ func (c *WishController) CreateWish(ctx *gin.Context) {
 		IsPublished: wish.IsPublished,
 	}
 
-	if err := c.wishService.CreateWish(&newWish); err != nil {
+	middleware.SetAuditAction(ctx, "wish.create")
+
+	if err := c.wishService.CreateWish(&newWish, uploadOwner(ctx)); err != nil {
+		if errors.Is(err, services.ErrUploadNotOwned) {
+			ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+			return
+		}
 		ctx.JSON(500, utils.CreateResponse(nil, "无法创建心愿"))
 		return
 	}
 
+	middleware.SetAuditTarget(ctx, "wish", newWish.ID)
+	middleware.SetAuditAfter(ctx, newWish)
+
+	c.storageService.SignWish(ctx.Request.Context(), &newWish)
 	ctx.JSON(201, utils.CreateResponse(newWish))
 }
 
@@ -151,6 +179,16 @@ func (c *WishController) DeleteWish(ctx *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditAction(ctx, "wish.delete")
+	middleware.SetAuditTarget(ctx, "wish", wishID)
+
+	wish, err := c.wishService.GetWishByID(uint(wishID))
+	if err != nil {
+		ctx.JSON(404, utils.CreateResponse(nil, "心愿不存在"))
+		return
+	}
+	middleware.SetAuditBefore(ctx, wish)
+
 	if err := c.wishService.DeleteWish(uint(wishID)); err != nil {
 		ctx.JSON(500, utils.CreateResponse(nil, "无法删除心愿"))
 		return
@@ -189,11 +227,15 @@ func (c *WishController) UpdateWish(ctx *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditAction(ctx, "wish.update")
+	middleware.SetAuditTarget(ctx, "wish", wishID)
+
 	wish, err := c.wishService.GetWishByID(uint(wishID))
 	if err != nil {
 		ctx.JSON(404, utils.CreateResponse(nil, "心愿不存在"))
 		return
 	}
+	middleware.SetAuditBefore(ctx, wish)
 
 	var wishInfo UpdateWishRequest
 	if err := ctx.ShouldBindJSON(&wishInfo); err != nil {
@@ -209,24 +251,30 @@ func (c *WishController) UpdateWish(ctx *gin.Context) {
 	wish.PhotoURL = &wishInfo.PhotoURL
 	wish.IsPublished = wishInfo.IsPublished
 
-	if err := c.wishService.UpdateWish(wish); err != nil {
+	if err := c.wishService.UpdateWish(wish, uploadOwner(ctx)); err != nil {
+		if errors.Is(err, services.ErrUploadNotOwned) {
+			ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+			return
+		}
 		ctx.JSON(500, utils.CreateResponse(nil, "无法更新心愿"))
 		return
 	}
+	middleware.SetAuditAfter(ctx, wish)
 
+	c.storageService.SignWish(ctx.Request.Context(), wish)
 	ctx.JSON(200, utils.CreateResponse(wish))
 }
 
 type UpdateWishDonorRequest struct {
 	Name    string `json:"donorName"`
-	Mobile  string `json:"donorMobile"`
+	Mobile  string `json:"donorMobile"` // 不填时使用已绑定的微信手机号
 	Address string `json:"address"`
 	Comment string `json:"comment"`
 }
 
 // ClaimWish godoc
 // @Summary      [小程序]点亮心愿
-// @Description  创建一条认领记录
+// @Description  创建一条认领记录，未填写手机号时默认使用已绑定的微信手机号
 // @Tags         心愿
 // @Accept       json
 // @Produce      json
@@ -234,6 +282,7 @@ type UpdateWishDonorRequest struct {
 // @Param        request  body      UpdateWishDonorRequest  true  "捐赠者信息"
 // @Success      200   {object}  models.Wish  "返回更新后的心愿"
 // @Failure      400   {object}  map[string]interface{}  "请求数据无效"
+// @Failure      403   {object}  map[string]interface{}  "账号被限制或正在注销"
 // @Failure      404   {object}  map[string]interface{}  "心愿不存在"
 // @Failure      500   {object}  map[string]interface{}  "服务器错误"
 // @Router       /api/v1/wishes/{id}/donor [put]
@@ -243,11 +292,9 @@ func (c *WishController) ClaimWish(ctx *gin.Context) {
 		ctx.JSON(401, utils.CreateResponse(nil, "登录已过期"))
 		return
 	}
-	userType, exists := ctx.Get("userType")
-	if !exists || userType != "user" {
-		ctx.JSON(401, utils.CreateResponse(nil, "只有用户可以点亮心愿"))
-		return
-	}
+
+	middleware.SetAuditAction(ctx, "wish.claim")
+	middleware.SetAuditTarget(ctx, "wish", ctx.Param("id"))
 
 	donor, err := c.userService.GetUserByID(userID.(uint))
 	if err != nil {
@@ -255,6 +302,17 @@ func (c *WishController) ClaimWish(ctx *gin.Context) {
 		return
 	}
 
+	// 封禁和只读限制都不能认领心愿
+	restriction, err := c.restrictionService.GetActiveRestriction(donor.ID)
+	if err != nil {
+		ctx.JSON(500, utils.CreateResponse(nil, "检查用户状态失败"))
+		return
+	}
+	if restriction != nil {
+		ctx.JSON(403, utils.CreateResponse(middleware.RestrictionNotice(restriction), middleware.RestrictionMessage(restriction)))
+		return
+	}
+
 	wishID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
 		ctx.JSON(400, utils.CreateResponse(nil, "无效的心愿ID"))
@@ -278,6 +336,10 @@ func (c *WishController) ClaimWish(ctx *gin.Context) {
 		return
 	}
 
+	if donorInfo.Mobile == "" {
+		donorInfo.Mobile = donor.Phone
+	}
+
 	newRecord := models.WishRecord{
 		DonorName:    donorInfo.Name,
 		DonorMobile:  donorInfo.Mobile,
@@ -288,6 +350,10 @@ func (c *WishController) ClaimWish(ctx *gin.Context) {
 		DonorID: donor.ID,
 	}
 	if err := c.recordService.CreateRecordWithWish(&newRecord, wish); err != nil {
+		if errors.Is(err, services.ErrClaimDeletionPending) {
+			ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+			return
+		}
 		ctx.JSON(500, utils.CreateResponse(nil, "创建认领记录失败"))
 		return
 	}
@@ -299,7 +365,10 @@ func (c *WishController) ClaimWish(ctx *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditAfter(ctx, createdRecord)
+
 	// 返回新创建的记录
+	c.storageService.SignRecord(ctx.Request.Context(), createdRecord)
 	ctx.JSON(200, utils.CreateResponse(createdRecord))
 }
 
@@ -330,6 +399,8 @@ type BatchCreateWishRequest struct {
 // @Failure      500   {object}  map[string]interface{}  "服务器错误"
 // @Router       /api/v1/wishes/batch [post]
 func (c *WishController) BatchCreateWishes(ctx *gin.Context) {
+	middleware.SetAuditAction(ctx, "wish.batch_create")
+
 	contentType := ctx.GetHeader("Content-Type")
 
 	var wishes []*models.Wish
@@ -481,11 +552,21 @@ func (c *WishController) BatchCreateWishes(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.wishService.BatchCreateWishes(wishes); err != nil {
+	if err := c.wishService.BatchCreateWishes(wishes, uploadOwner(ctx)); err != nil {
+		if errors.Is(err, services.ErrUploadNotOwned) {
+			ctx.JSON(403, utils.CreateResponse(nil, err.Error()))
+			return
+		}
 		ctx.JSON(500, utils.CreateResponse(nil, "批量导入心愿失败"))
 		return
 	}
 
+	wishIDs := make([]uint, len(wishes))
+	for i, wish := range wishes {
+		wishIDs[i] = wish.ID
+	}
+	middleware.SetAuditAfter(ctx, gin.H{"count": len(wishes), "wishIds": wishIDs})
+
 	ctx.JSON(201, utils.CreateResponse("批量导入心愿成功"))
 }
 
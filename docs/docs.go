@@ -15,64 +15,34 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/admin/login": {
-            "post": {
-                "description": "管理员登录并获取认证令牌",
-                "consumes": [
-                    "application/json"
-                ],
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "返回 JSON Web Key Set 格式的公钥，可按令牌头部的 kid 选择公钥校验签名。密钥轮换期间会同时返回新旧公钥",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "管理员"
-                ],
-                "summary": "[后台]管理员登录",
-                "parameters": [
-                    {
-                        "description": "管理员登录信息",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/controllers.AdminLoginRequest"
-                        }
-                    }
+                    "用户"
                 ],
+                "summary": "获取令牌校验公钥",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/controllers.AdminLoginResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "请求参数错误",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "401": {
-                        "description": "未授权",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "500": {
-                        "description": "服务器错误",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/middleware.JWKS"
                         }
                     }
                 }
             }
         },
-        "/api/v1/admin/records": {
+        "/api/v1/admin/admins": {
             "get": {
-                "description": "获取系统中所有心愿认领记录，支持分页和状态过滤",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "获取所有后台管理员账号",
                 "consumes": [
                     "application/json"
                 ],
@@ -80,9 +50,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "记录"
+                    "管理员"
                 ],
-                "summary": "[后台]获取所有心愿认领记录",
+                "summary": "[后台]获取后台管理员列表",
                 "parameters": [
                     {
                         "type": "integer",
@@ -95,19 +65,13 @@ const docTemplate = `{
                         "description": "每页数量，默认10",
                         "name": "pageSize",
                         "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "状态过滤，可选值：pending_shipment, pending_confirmation等",
-                        "name": "status",
-                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "返回记录列表",
+                        "description": "返回管理员列表",
                         "schema": {
-                            "$ref": "#/definitions/controllers.GetWishRecordsResponse"
+                            "$ref": "#/definitions/controllers.GetAdminsResponse"
                         }
                     },
                     "401": {
@@ -127,14 +91,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/admin/register": {
-            "post": {
+        "/api/v1/admin/admins/{id}/password": {
+            "put": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "创建新管理员账号",
+                "description": "为指定管理员设置新密码，并解除该账号的登录锁定，该管理员之前签发的令牌全部失效。不能重设自己的密码，请使用 /admin/me/password",
                 "consumes": [
                     "application/json"
                 ],
@@ -144,41 +108,56 @@ const docTemplate = `{
                 "tags": [
                     "管理员"
                 ],
-                "summary": "[后台]管理员注册",
+                "summary": "[后台]重设其他管理员的密码",
                 "parameters": [
                     {
-                        "description": "管理员注册信息",
+                        "type": "integer",
+                        "description": "管理员ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "新密码",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/controllers.AdminRegisterRequest"
+                            "$ref": "#/definitions/controllers.ResetPasswordRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "重设成功",
                         "schema": {
-                            "$ref": "#/definitions/models.Admin"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "请求参数错误",
+                        "description": "新密码不符合要求",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "401": {
-                        "description": "未授权",
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "403": {
-                        "description": "禁止访问",
+                        "description": "不能重设自己的密码",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "管理员不存在",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -194,9 +173,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/records/{id}": {
-            "get": {
-                "description": "根据ID获取单个心愿认领记录的详细信息",
+        "/api/v1/admin/admins/{id}/totp": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "用于管理员丢失验证设备的情况，清除后该账号可重新绑定。系统强制两步验证时，该账号之前签发的令牌全部失效，下次登录时必须重新绑定。不能清除自己的两步验证，请使用 /admin/me/totp/disable",
                 "consumes": [
                     "application/json"
                 ],
@@ -204,13 +188,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "记录"
+                    "管理员"
                 ],
-                "summary": "[小程序/后台]获取单个心愿认领记录详情",
+                "summary": "[后台]清除其他管理员的两步验证",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "记录ID",
+                        "description": "管理员ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -218,27 +202,28 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "返回记录详情",
+                        "description": "清除成功",
                         "schema": {
-                            "$ref": "#/definitions/controllers.RecordDetailResponse"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
-                    "400": {
-                        "description": "无效的ID",
+                    "401": {
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "401": {
-                        "description": "未登录或无权限",
+                    "403": {
+                        "description": "不能清除自己的两步验证",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "404": {
-                        "description": "记录不存在",
+                        "description": "管理员不存在",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -254,9 +239,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/records/{id}/shipping-info": {
-            "put": {
-                "description": "更新收货人姓名、手机号和地址",
+        "/api/v1/admin/audit-logs": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "按操作人、动作、操作对象、IP和时间范围查询审计日志",
                 "consumes": [
                     "application/json"
                 ],
@@ -264,50 +254,80 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "记录"
+                    "审计"
                 ],
-                "summary": "[小程序/后台]更新心愿认领记录的收货信息",
+                "summary": "[后台]查询审计日志",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "操作人类型：user 或 admin",
+                        "name": "actorType",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "记录ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "操作人ID",
+                        "name": "actorId",
+                        "in": "query"
                     },
                     {
-                        "description": "收货信息",
-                        "name": "params",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/controllers.UpdateShippingInfoRequest"
-                        }
+                        "type": "string",
+                        "description": "动作，前缀匹配，例如 wish.",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "操作对象类型，例如 wish、record、user",
+                        "name": "targetType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "操作对象ID",
+                        "name": "targetId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "来源IP",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "起始时间戳（秒）",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "截止时间戳（秒）",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "页码，默认1",
+                        "name": "pageIndex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量，默认10",
+                        "name": "pageSize",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "返回更新后的记录",
-                        "schema": {
-                            "$ref": "#/definitions/models.WishRecord"
-                        }
-                    },
-                    "400": {
-                        "description": "参数错误",
+                        "description": "返回审计日志列表",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/controllers.GetAuditLogsResponse"
                         }
                     },
                     "401": {
-                        "description": "未登录或无权限",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "404": {
-                        "description": "记录不存在",
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -323,67 +343,87 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/records/{id}/status": {
-            "put": {
-                "description": "更新记录状态并提供相应所需信息",
-                "consumes": [
-                    "application/json"
+        "/api/v1/admin/audit-logs/export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "按查询条件导出审计日志为Excel文件，单次最多导出50000条",
                 "produces": [
-                    "application/json"
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
                 ],
                 "tags": [
-                    "记录"
+                    "审计"
                 ],
-                "summary": "[小程序/后台]更新心愿认领记录状态",
+                "summary": "[后台]导出审计日志",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "操作人类型：user 或 admin",
+                        "name": "actorType",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "记录ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "操作人ID",
+                        "name": "actorId",
+                        "in": "query"
                     },
                     {
-                        "description": "更新参数",
-                        "name": "params",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/controllers.UpdateRecordStatusRequest"
-                        }
+                        "type": "string",
+                        "description": "动作，前缀匹配",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "操作对象类型",
+                        "name": "targetType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "操作对象ID",
+                        "name": "targetId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "来源IP",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "起始时间戳（秒）",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "截止时间戳（秒）",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "返回更新后的记录",
+                        "description": "审计日志Excel文件",
                         "schema": {
-                            "$ref": "#/definitions/models.WishRecord"
+                            "type": "file"
                         }
                     },
-                    "400": {
-                        "description": "参数错误",
+                    "401": {
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "401": {
-                        "description": "未登录或无权限",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "404": {
-                        "description": "记录不存在",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "500": {
-                        "description": "服务器错误",
+                    "500": {
+                        "description": "服务器错误",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -392,50 +432,59 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/upload/image": {
-            "post": {
-                "description": "上传图片到腾讯云对象存储",
+        "/api/v1/admin/deletion-requests": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "按用户和状态查询小程序用户的注销申请",
                 "consumes": [
-                    "multipart/form-data"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "文件上传"
+                    "用户管理"
                 ],
-                "summary": "上传图片",
+                "summary": "[后台]查询注销申请",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "图片文件",
-                        "name": "file",
-                        "in": "formData",
-                        "required": true
+                        "type": "integer",
+                        "description": "用户ID",
+                        "name": "userId",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "存储目录，例如: images/avatar",
-                        "name": "directory",
-                        "in": "formData"
+                        "description": "状态：pending、cancelled、completed",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "页码，默认1",
+                        "name": "pageIndex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量，默认10",
+                        "name": "pageSize",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "上传成功，返回图片URL",
-                        "schema": {
-                            "$ref": "#/definitions/controllers.UploadImageResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "请求参数错误",
+                        "description": "返回注销申请列表",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/controllers.GetDeletionRequestsResponse"
                         }
                     },
                     "401": {
-                        "description": "用户未登录",
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -451,9 +500,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/user/login": {
-            "post": {
-                "description": "通过微信小程序临时登录凭证code进行登录",
+        "/api/v1/admin/invitations": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "获取所有邀请及其创建、使用和撤销记录",
                 "consumes": [
                     "application/json"
                 ],
@@ -461,29 +515,32 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户"
+                    "管理员"
                 ],
-                "summary": "[小程序]微信小程序登录",
+                "summary": "[后台]获取管理员邀请列表",
                 "parameters": [
                     {
-                        "description": "微信登录请求",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/controllers.WechatLoginRequest"
-                        }
+                        "type": "integer",
+                        "description": "页码，默认1",
+                        "name": "pageIndex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量，默认10",
+                        "name": "pageSize",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "返回邀请列表",
                         "schema": {
-                            "$ref": "#/definitions/controllers.WechatLoginResponse"
+                            "$ref": "#/definitions/controllers.GetInvitationsResponse"
                         }
                     },
-                    "400": {
-                        "description": "请求参数错误",
+                    "401": {
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -497,11 +554,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/user/records": {
-            "get": {
-                "description": "获取当前登录用户点亮心愿的记录（如果是管理员账号，获取所有用户的记录）",
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "生成一次性的管理员注册邀请令牌，令牌明文只返回一次",
                 "consumes": [
                     "application/json"
                 ],
@@ -509,32 +569,36 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "记录"
+                    "管理员"
                 ],
-                "summary": "[小程序]获取用户点亮心愿的记录（如果是管理员账号，获取所有用户的记录）",
+                "summary": "[后台]创建管理员邀请",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "页码，默认1",
-                        "name": "pageIndex",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "每页数量，默认10",
-                        "name": "pageSize",
-                        "in": "query"
+                        "description": "邀请信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CreateInvitationRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "返回用户点亮的心愿列表",
+                    "201": {
+                        "description": "返回邀请及令牌",
                         "schema": {
-                            "$ref": "#/definitions/controllers.GetWishesResponse"
+                            "$ref": "#/definitions/controllers.CreateInvitationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "401": {
-                        "description": "用户未登录",
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -550,14 +614,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/user/userinfo": {
-            "put": {
+        "/api/v1/admin/invitations/{id}": {
+            "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "更新微信用户的昵称和头像",
+                "description": "撤销尚未使用的邀请，撤销后令牌无法再用于注册",
                 "consumes": [
                     "application/json"
                 ],
@@ -565,36 +629,41 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户"
+                    "管理员"
                 ],
-                "summary": "[小程序]更新微信用户信息",
+                "summary": "[后台]撤销管理员邀请",
                 "parameters": [
                     {
-                        "description": "微信用户信息",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/controllers.WechatUserInfoRequest"
-                        }
+                        "type": "integer",
+                        "description": "邀请ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "返回撤销后的邀请",
                         "schema": {
-                            "$ref": "#/definitions/models.User"
+                            "$ref": "#/definitions/controllers.InvitationResponse"
                         }
                     },
                     "400": {
-                        "description": "请求参数错误",
+                        "description": "邀请已失效",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "401": {
-                        "description": "未授权",
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "邀请不存在",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -610,9 +679,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/admin": {
-            "get": {
-                "description": "获取所有具有管理员权限的用户",
+        "/api/v1/admin/login": {
+            "post": {
+                "description": "管理员登录并获取认证令牌。启用两步验证的账号只返回 mfaToken，需再调用 /api/v1/admin/login/totp",
                 "consumes": [
                     "application/json"
                 ],
@@ -620,32 +689,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户管理"
+                    "管理员"
                 ],
-                "summary": "获取所有拥有管理员权限的用户",
+                "summary": "[后台]管理员登录",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "页码，默认1",
-                        "name": "pageIndex",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "每页数量，默认10",
-                        "name": "pageSize",
-                        "in": "query"
+                        "description": "管理员登录信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.AdminLoginRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "返回管理员用户列表",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/controllers.GetAdminUsersResponse"
+                            "$ref": "#/definitions/controllers.AdminLoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "401": {
-                        "description": "用户未登录或无权限",
+                        "description": "未授权",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "429": {
+                        "description": "登录失败次数过多，账号或IP已被临时锁定",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -661,9 +741,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/regular": {
+        "/api/v1/admin/login-attempts": {
             "get": {
-                "description": "获取所有不具有管理员权限的普通用户",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "查看管理员登录尝试，包括失败和被锁定的尝试",
                 "consumes": [
                     "application/json"
                 ],
@@ -671,10 +756,28 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户管理"
+                    "管理员"
                 ],
-                "summary": "获取所有不拥有管理员权限的用户",
+                "summary": "[后台]获取管理员登录记录",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "按用户名过滤",
+                        "name": "username",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "按IP过滤",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "按是否成功过滤，不传为全部",
+                        "name": "success",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "description": "页码，默认1",
@@ -690,9 +793,9 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "返回普通用户列表",
+                        "description": "返回登录记录",
                         "schema": {
-                            "$ref": "#/definitions/controllers.GetAdminUsersResponse"
+                            "$ref": "#/definitions/controllers.GetLoginAttemptsResponse"
                         }
                     },
                     "401": {
@@ -712,9 +815,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/{id}/admin": {
-            "put": {
-                "description": "设置或取消用户的管理员权限",
+        "/api/v1/admin/login/totp": {
+            "post": {
+                "description": "提交验证码或恢复码完成登录。若账号正在登录过程中绑定两步验证，验证码用于确认绑定并返回恢复码",
                 "consumes": [
                     "application/json"
                 ],
@@ -722,51 +825,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "用户管理"
+                    "管理员"
                 ],
-                "summary": "更新用户管理员权限",
+                "summary": "[后台]管理员登录第二步",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "用户ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "请求数据",
+                        "description": "两步验证信息",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/controllers.UpdateUserAdminRequest"
+                            "$ref": "#/definitions/controllers.AdminLoginTOTPRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "更新成功",
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/controllers.AdminLoginResponse"
                         }
                     },
                     "400": {
-                        "description": "请求数据错误",
+                        "description": "请求参数错误",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "401": {
-                        "description": "用户未登录或无权限",
+                        "description": "临时令牌无效或验证码错误",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "404": {
-                        "description": "用户不存在",
+                    "429": {
+                        "description": "失败次数过多，账号或IP已被临时锁定",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -782,9 +877,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/wishes": {
-            "get": {
-                "description": "获取心愿列表，支持分页和过滤",
+        "/api/v1/admin/login/totp/setup": {
+            "post": {
+                "description": "系统强制两步验证而账号尚未绑定时，使用登录返回的 mfaToken 获取 TOTP 密钥和二维码链接",
                 "consumes": [
                     "application/json"
                 ],
@@ -792,53 +887,85 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "心愿"
+                    "管理员"
                 ],
-                "summary": "[小程序/后台]获取心愿列表",
+                "summary": "[后台]登录时绑定两步验证",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "按心愿内容模糊搜索",
-                        "name": "content",
-                        "in": "query"
-                    },
-                    {
-                        "type": "boolean",
-                        "default": false,
-                        "description": "按完成状态过滤,默认为false",
-                        "name": "isDone",
-                        "in": "query"
+                        "description": "两步验证临时令牌",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.AdminLoginTOTPSetupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.TOTPEnrollment"
+                        }
                     },
-                    {
-                        "type": "boolean",
-                        "description": "按公开状态过滤,不传为全部",
-                        "name": "isPublished",
-                        "in": "query"
+                    "400": {
+                        "description": "请求参数错误或已绑定",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     },
-                    {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "页码，默认1",
-                        "name": "pageIndex",
-                        "in": "query"
+                    "401": {
+                        "description": "临时令牌无效或已过期",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/me": {
+            "get": {
+                "security": [
                     {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "每页数量，默认10",
-                        "name": "pageSize",
-                        "in": "query"
+                        "ApiKeyAuth": []
                     }
                 ],
+                "description": "获取当前登录管理员的个人资料",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "管理员"
+                ],
+                "summary": "[后台]获取当前管理员资料",
                 "responses": {
                     "200": {
-                        "description": "返回心愿列表和分页信息",
+                        "description": "返回个人资料",
                         "schema": {
-                            "$ref": "#/definitions/controllers.GetWishesResponse"
+                            "$ref": "#/definitions/controllers.AdminProfileResponse"
                         }
                     },
-                    "500": {
-                        "description": "服务器错误",
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "管理员不存在",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -846,8 +973,13 @@ const docTemplate = `{
                     }
                 }
             },
-            "post": {
-                "description": "创建一个新的心愿",
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "更新昵称、头像、部门和联系电话，未传的字段保持不变",
                 "consumes": [
                     "application/json"
                 ],
@@ -855,29 +987,36 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "心愿"
+                    "管理员"
                 ],
-                "summary": "[后台]创建新心愿",
+                "summary": "[后台]更新当前管理员资料",
                 "parameters": [
                     {
-                        "description": "心愿信息",
+                        "description": "个人资料",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/controllers.CreateWishRequest"
+                            "$ref": "#/definitions/controllers.UpdateAdminProfileRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "返回创建的心愿",
+                    "200": {
+                        "description": "返回更新后的个人资料",
                         "schema": {
-                            "$ref": "#/definitions/models.Wish"
+                            "$ref": "#/definitions/controllers.AdminProfileResponse"
                         }
                     },
                     "400": {
-                        "description": "请求数据无效",
+                        "description": "请求数据错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -893,46 +1032,52 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/wishes/batch": {
-            "post": {
-                "description": "批量导入多个心愿，支持JSON和XLSX文件",
+        "/api/v1/admin/me/password": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "验证旧密码后设置新密码，新密码需至少10个字符且同时包含字母和数字。修改后之前签发的令牌全部失效，需要重新登录",
                 "consumes": [
-                    "multipart/form-data",
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "心愿"
+                    "管理员"
                 ],
-                "summary": "[后台]批量导入心愿",
+                "summary": "[后台]修改当前管理员密码",
                 "parameters": [
                     {
-                        "description": "JSON格式的心愿信息数组",
+                        "description": "旧密码和新密码",
                         "name": "request",
                         "in": "body",
+                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/controllers.BatchCreateWishRequest"
+                            "$ref": "#/definitions/controllers.ChangePasswordRequest"
                         }
-                    },
-                    {
-                        "type": "file",
-                        "description": "Excel文件，支持 .xlsx 和 .xls",
-                        "name": "file",
-                        "in": "formData"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "返回导入结果",
+                    "200": {
+                        "description": "修改成功",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "请求数据无效",
+                        "description": "旧密码错误或新密码不符合要求",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -948,9 +1093,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/wishes/{id}": {
-            "put": {
-                "description": "更新心愿",
+        "/api/v1/admin/me/totp/disable": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "验证密码后关闭当前管理员的两步验证，系统强制启用时不可关闭",
                 "consumes": [
                     "application/json"
                 ],
@@ -958,36 +1108,44 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "心愿"
+                    "管理员"
                 ],
-                "summary": "[后台]更新心愿",
+                "summary": "[后台]关闭两步验证",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "心愿ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "心愿信息",
+                        "description": "当前密码",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/controllers.UpdateWishRequest"
+                            "$ref": "#/definitions/controllers.DisableTOTPRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "返回更新后的心愿",
+                        "description": "关闭成功",
                         "schema": {
-                            "$ref": "#/definitions/models.Wish"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "请求数据无效",
+                        "description": "密码错误或未启用",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "系统强制启用两步验证",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -1001,9 +1159,16 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "删除心愿",
+            }
+        },
+        "/api/v1/admin/me/totp/enable": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "提交验证器生成的验证码确认绑定，成功后返回恢复码",
                 "consumes": [
                     "application/json"
                 ],
@@ -1011,28 +1176,36 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "心愿"
+                    "管理员"
                 ],
-                "summary": "[后台]删除心愿",
+                "summary": "[后台]启用两步验证",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "心愿ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "验证码",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.TOTPCodeRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "成功删除心愿",
+                        "description": "返回恢复码",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.RecoveryCodesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "验证码错误或未获取密钥",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "400": {
-                        "description": "请求数据无效",
+                    "401": {
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -1048,9 +1221,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/wishes/{id}/donor": {
-            "put": {
-                "description": "创建一条认领记录",
+        "/api/v1/admin/me/totp/recovery-codes": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "提交当前验证码后生成一组新的恢复码，旧恢复码全部失效",
                 "consumes": [
                     "application/json"
                 ],
@@ -1058,43 +1236,36 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "心愿"
+                    "管理员"
                 ],
-                "summary": "[小程序]点亮心愿",
+                "summary": "[后台]重新生成恢复码",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "心愿ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "捐赠者信息",
+                        "description": "验证码",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/controllers.UpdateWishDonorRequest"
+                            "$ref": "#/definitions/controllers.TOTPCodeRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "返回更新后的心愿",
+                        "description": "返回新的恢复码",
                         "schema": {
-                            "$ref": "#/definitions/models.Wish"
+                            "$ref": "#/definitions/controllers.RecoveryCodesResponse"
                         }
                     },
                     "400": {
-                        "description": "请求数据无效",
+                        "description": "验证码错误或未启用",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "404": {
-                        "description": "心愿不存在",
+                    "401": {
+                        "description": "用户未登录或无权限",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -1109,51 +1280,2903 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "controllers.AdminLoginRequest": {
-            "type": "object",
-            "required": [
-                "password",
-                "username"
-            ],
-            "properties": {
-                "password": {
-                    "type": "string"
-                },
-                "username": {
+        },
+        "/api/v1/admin/me/totp/setup": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "为当前管理员生成待确认的TOTP密钥和二维码链接，需调用启用接口确认后才会生效",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "管理员"
+                ],
+                "summary": "[后台]获取两步验证绑定密钥",
+                "responses": {
+                    "200": {
+                        "description": "返回密钥和二维码链接",
+                        "schema": {
+                            "$ref": "#/definitions/services.TOTPEnrollment"
+                        }
+                    },
+                    "400": {
+                        "description": "已启用两步验证",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/notifications": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "按用户、记录和投递状态查询订阅消息的发送情况",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "通知"
+                ],
+                "summary": "[后台]查询订阅消息投递记录",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "用户ID",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "认领记录ID",
+                        "name": "recordId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "投递状态：pending、sent、failed、skipped",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "页码，默认1",
+                        "name": "pageIndex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量，默认10",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回投递记录列表",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.GetNotificationDeliveriesResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/records": {
+            "get": {
+                "description": "获取系统中所有心愿认领记录，支持分页和状态过滤",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "记录"
+                ],
+                "summary": "[后台]获取所有心愿认领记录",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码，默认1",
+                        "name": "pageIndex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量，默认10",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "状态过滤，可选值：pending_shipment, pending_confirmation等",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回记录列表",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.GetWishRecordsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/register": {
+            "post": {
+                "description": "使用现有管理员签发的一次性邀请令牌创建新管理员账号",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "管理员"
+                ],
+                "summary": "[后台]管理员注册",
+                "parameters": [
+                    {
+                        "description": "管理员注册信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.AdminRegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Admin"
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "邀请令牌无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/uploads/gc": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "立即删除不再被心愿、认领记录或头像引用且超过保留期的上传文件（后台任务也会定期执行）。dryRun 为 true 时只返回将要删除的文件",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件上传"
+                ],
+                "summary": "清理上传文件",
+                "parameters": [
+                    {
+                        "description": "清理选项",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CollectGarbageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "清理结果",
+                        "schema": {
+                            "$ref": "#/definitions/services.GCReport"
+                        }
+                    },
+                    "401": {
+                        "description": "未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "没有权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/records/{id}": {
+            "get": {
+                "description": "根据ID获取单个心愿认领记录的详细信息",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "记录"
+                ],
+                "summary": "[小程序/后台]获取单个心愿认领记录详情",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "记录ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回记录详情",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.RecordDetailResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "无效的ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "记录不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/records/{id}/shipping-info": {
+            "put": {
+                "description": "更新收货人姓名、手机号和地址",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "记录"
+                ],
+                "summary": "[小程序/后台]更新心愿认领记录的收货信息",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "记录ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "收货信息",
+                        "name": "params",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateShippingInfoRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回更新后的记录",
+                        "schema": {
+                            "$ref": "#/definitions/models.WishRecord"
+                        }
+                    },
+                    "400": {
+                        "description": "参数错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "记录不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/records/{id}/status": {
+            "put": {
+                "description": "更新记录状态并提供相应所需信息",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "记录"
+                ],
+                "summary": "[小程序/后台]更新心愿认领记录状态",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "记录ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "更新参数",
+                        "name": "params",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateRecordStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回更新后的记录",
+                        "schema": {
+                            "$ref": "#/definitions/models.WishRecord"
+                        }
+                    },
+                    "400": {
+                        "description": "参数错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "记录不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/upload/direct/{token}": {
+            "put": {
+                "description": "存储后端不支持预签名时（本地存储），由服务器接收直传凭证对应的文件。地址由申请直传凭证接口返回，令牌即授权，无需登录",
+                "consumes": [
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif",
+                    "image/webp"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件上传"
+                ],
+                "summary": "中转上传",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "上传令牌",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "上传成功",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "文件与凭证不一致",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "上传凭证不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "410": {
+                        "description": "上传凭证已过期或已使用",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/upload/image": {
+            "post": {
+                "description": "上传图片到配置的存储后端（腾讯云对象存储或本地磁盘）。图片会被重新编码以去除EXIF等元数据（包括GPS位置）并按拍摄方向自动旋转，同时生成展示图和正方形缩略图",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件上传"
+                ],
+                "summary": "上传图片",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "图片文件",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "上传用途：avatar、wish_photo、shipping_proof、receipt_photo 或 gift_photo",
+                        "name": "purpose",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "上传成功，返回原图、展示图和缩略图",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UploadImageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "当前角色不能上传该用途的文件",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/upload/tickets": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "签发短期有效的上传地址，客户端直接把文件上传到对象存储，不经过服务器。文件路径由服务端生成，类型和大小在签名中固定。上传完成后需调用确认接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件上传"
+                ],
+                "summary": "申请直传凭证",
+                "parameters": [
+                    {
+                        "description": "文件信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CreateUploadTicketRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回上传凭证和上传请求",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CreateUploadTicketResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "文件类型或大小不符合要求",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "当前角色不能上传该用途的文件",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/upload/tickets/{id}/complete": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "检查文件已上传到存储且类型和大小与凭证一致，重新编码并去除 EXIF 等元数据后返回原图地址，同时生成展示图和缩略图。不一致的文件会被删除。重复确认返回相同结果",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件上传"
+                ],
+                "summary": "确认直传完成",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "上传凭证ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回文件地址",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CompleteUploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "文件尚未上传或与凭证不一致",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "上传凭证不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "410": {
+                        "description": "上传凭证已过期或已使用",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/login": {
+            "post": {
+                "description": "通过微信小程序临时登录凭证code进行登录",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "[小程序]微信小程序登录",
+                "parameters": [
+                    {
+                        "description": "微信登录请求",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.WechatLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.WechatLoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/me": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "获取当前登录的小程序用户的个人资料",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "[小程序]获取当前用户资料",
+                "responses": {
+                    "200": {
+                        "description": "返回个人资料",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UserProfileResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "用户不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "更新昵称和头像，未传的字段保持不变。昵称不超过32个字符，头像须为http或https链接",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "[小程序]更新当前用户资料",
+                "parameters": [
+                    {
+                        "description": "个人资料",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateUserProfileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回更新后的个人资料",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UserProfileResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "请求数据错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/me/deletion": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "获取最近一次注销申请，没有申请时返回 null",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "[小程序]查询注销申请",
+                "responses": {
+                    "200": {
+                        "description": "返回注销申请",
+                        "schema": {
+                            "$ref": "#/definitions/models.AccountDeletionRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "提交注销申请，冷静期结束后将匿名化姓名、手机号、地址等个人信息，冷静期内可以撤销。有进行中的认领记录时不能申请",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "[小程序]申请注销账号",
+                "parameters": [
+                    {
+                        "description": "注销原因",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.RequestDeletionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回注销申请",
+                        "schema": {
+                            "$ref": "#/definitions/models.AccountDeletionRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "已有待处理的申请或有进行中的认领记录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "在冷静期内撤销注销申请",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "[小程序]撤销注销申请",
+                "responses": {
+                    "200": {
+                        "description": "返回已撤销的注销申请",
+                        "schema": {
+                            "$ref": "#/definitions/models.AccountDeletionRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "没有可撤销的注销申请",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/me/export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "下载包含个人资料、认领记录、订阅授权和注销申请的 zip 压缩包，文件均为 JSON 格式",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "[小程序]导出个人数据",
+                "responses": {
+                    "200": {
+                        "description": "个人数据压缩包",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/phone": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "通过小程序 getPhoneNumber 授权获取手机号并保存，认领心愿时默认使用该手机号。可传入 code（推荐），或传入 encryptedData 和 iv 使用登录时的 session_key 解密",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户"
+                ],
+                "summary": "[小程序]绑定微信手机号",
+                "parameters": [
+                    {
+                        "description": "手机号授权数据",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.BindWechatPhoneRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.BindWechatPhoneResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误或授权数据无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或微信会话已失效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/records": {
+            "get": {
+                "description": "获取当前登录用户点亮心愿的记录（如果是管理员账号，获取所有用户的记录）",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "记录"
+                ],
+                "summary": "[小程序]获取用户点亮心愿的记录（如果是管理员账号，获取所有用户的记录）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码，默认1",
+                        "name": "pageIndex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量，默认10",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回用户点亮的心愿列表",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.GetWishesResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/user/subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "返回记录状态变更通知使用的模板ID以及当前用户的剩余授权条数，小程序据此调用 wx.requestSubscribeMessage",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "通知"
+                ],
+                "summary": "[小程序]获取订阅消息授权情况",
+                "responses": {
+                    "200": {
+                        "description": "返回模板和授权情况",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.GetSubscriptionsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "将 wx.requestSubscribeMessage 的返回结果原样上报。每次 accept 可接收一条通知，reject 或 ban 会清空剩余条数",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "通知"
+                ],
+                "summary": "[小程序]上报订阅消息授权结果",
+                "parameters": [
+                    {
+                        "description": "授权结果",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateSubscriptionsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回更新后的授权情况",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.GetSubscriptionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "请求数据错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/admin": {
+            "get": {
+                "description": "获取所有具有管理员权限的用户",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "获取所有拥有管理员权限的用户",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码，默认1",
+                        "name": "pageIndex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量，默认10",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回管理员用户列表",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.GetAdminUsersResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/regular": {
+            "get": {
+                "description": "获取所有不具有管理员权限的普通用户",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "获取所有不拥有管理员权限的用户",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码，默认1",
+                        "name": "pageIndex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量，默认10",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回普通用户列表",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.GetAdminUsersResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/admin": {
+            "put": {
+                "description": "设置或取消用户的管理员权限",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "更新用户管理员权限",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "用户ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "请求数据",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateUserAdminRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "更新成功",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "请求数据错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "用户不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/restrictions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "获取小程序用户的全部限制记录，包括已解除和已到期的",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "[后台]查询用户限制记录",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "用户ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回限制记录",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.UserRestriction"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "无效的用户ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "封禁（ban）的用户不能访问任何需要登录的接口，只读（browse_only）的用户只能浏览，不能认领心愿或提交修改。已签发的令牌立即受限，已有的限制会被替换",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "[后台]封禁或限制用户",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "用户ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "限制信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.RestrictUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回新的限制",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserRestriction"
+                        }
+                    },
+                    "400": {
+                        "description": "请求数据错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "用户不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "提前解除用户当前生效的封禁或只读限制，限制记录会保留",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用户管理"
+                ],
+                "summary": "[后台]解除用户限制",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "用户ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "解除成功",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "无效的用户ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "用户未登录或无权限",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "该用户没有生效中的限制",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/wishes": {
+            "get": {
+                "description": "获取心愿列表，支持分页和过滤。未登录或没有权限的调用方只能看到心愿照片的公开缩略图，志愿者和管理员携带令牌时返回原图的签名地址",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "心愿"
+                ],
+                "summary": "[小程序/后台]获取心愿列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "按心愿内容模糊搜索",
+                        "name": "content",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "按完成状态过滤,默认为false",
+                        "name": "isDone",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "按公开状态过滤,不传为全部",
+                        "name": "isPublished",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "页码，默认1",
+                        "name": "pageIndex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "每页数量，默认10",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回心愿列表和分页信息",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.GetWishesResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "创建一个新的心愿",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "心愿"
+                ],
+                "summary": "[后台]创建新心愿",
+                "parameters": [
+                    {
+                        "description": "心愿信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CreateWishRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "返回创建的心愿",
+                        "schema": {
+                            "$ref": "#/definitions/models.Wish"
+                        }
+                    },
+                    "400": {
+                        "description": "请求数据无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/wishes/batch": {
+            "post": {
+                "description": "批量导入多个心愿，支持JSON和XLSX文件",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "心愿"
+                ],
+                "summary": "[后台]批量导入心愿",
+                "parameters": [
+                    {
+                        "description": "JSON格式的心愿信息数组",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.BatchCreateWishRequest"
+                        }
+                    },
+                    {
+                        "type": "file",
+                        "description": "Excel文件，支持 .xlsx 和 .xls",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "返回导入结果",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "请求数据无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/wishes/{id}": {
+            "put": {
+                "description": "更新心愿",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "心愿"
+                ],
+                "summary": "[后台]更新心愿",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "心愿ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "心愿信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateWishRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回更新后的心愿",
+                        "schema": {
+                            "$ref": "#/definitions/models.Wish"
+                        }
+                    },
+                    "400": {
+                        "description": "请求数据无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "删除心愿",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "心愿"
+                ],
+                "summary": "[后台]删除心愿",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "心愿ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功删除心愿",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "请求数据无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/wishes/{id}/donor": {
+            "put": {
+                "description": "创建一条认领记录，未填写手机号时默认使用已绑定的微信手机号",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "心愿"
+                ],
+                "summary": "[小程序]点亮心愿",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "心愿ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "捐赠者信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateWishDonorRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回更新后的心愿",
+                        "schema": {
+                            "$ref": "#/definitions/models.Wish"
+                        }
+                    },
+                    "400": {
+                        "description": "请求数据无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "账号被限制或正在注销",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "心愿不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "服务器错误",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "进程能够处理请求即返回 200，不检查数据库和存储，用于判断是否需要重启容器",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "运维"
+                ],
+                "summary": "存活检查",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.HealthzResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "检查数据库能否读写、数据表是否已迁移到当前版本以及存储后端能否访问，全部通过时返回 200。服务正在停止时始终返回 503",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "运维"
+                ],
+                "summary": "就绪检查",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.Readiness"
+                        }
+                    },
+                    "503": {
+                        "description": "存在未通过的检查项",
+                        "schema": {
+                            "$ref": "#/definitions/services.Readiness"
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "返回服务的版本、git 提交和构建时间",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "运维"
+                ],
+                "summary": "构建信息",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.BuildInfo"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "controllers.AdminLoginRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.AdminLoginResponse": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "$ref": "#/definitions/models.Admin"
+                },
+                "enrollmentRequired": {
+                    "description": "系统强制两步验证但该账号尚未绑定时为 true，需先调用 /api/v1/admin/login/totp/setup",
+                    "type": "boolean"
+                },
+                "mfaRequired": {
+                    "description": "需要两步验证时不返回 token，而是返回短期有效的 mfaToken，\n客户端需携带 mfaToken 调用 /api/v1/admin/login/totp 完成登录",
+                    "type": "boolean"
+                },
+                "mfaToken": {
+                    "type": "string"
+                },
+                "recoveryCodes": {
+                    "description": "在登录过程中完成两步验证绑定时返回的恢复码，只返回一次",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.AdminLoginTOTPRequest": {
+            "type": "object",
+            "required": [
+                "mfaToken"
+            ],
+            "properties": {
+                "code": {
+                    "description": "验证器应用中的6位验证码",
+                    "type": "string"
+                },
+                "mfaToken": {
+                    "type": "string"
+                },
+                "recoveryCode": {
+                    "description": "无法使用验证器时可改用恢复码",
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.AdminLoginTOTPSetupRequest": {
+            "type": "object",
+            "required": [
+                "mfaToken"
+            ],
+            "properties": {
+                "mfaToken": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.AdminProfileResponse": {
+            "type": "object",
+            "properties": {
+                "avatarUrl": {
+                    "type": "string"
+                },
+                "contactPhone": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "integer"
+                },
+                "department": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "nickname": {
+                    "type": "string"
+                },
+                "passwordChangedAt": {
+                    "type": "integer"
+                },
+                "totpEnabled": {
+                    "type": "boolean"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.AdminRegisterRequest": {
+            "type": "object",
+            "required": [
+                "invitationToken",
+                "password",
+                "username"
+            ],
+            "properties": {
+                "invitationToken": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.BatchCreateWishItem": {
+            "type": "object",
+            "properties": {
+                "childName": {
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "gender": {
+                    "$ref": "#/definitions/models.Gender"
+                },
+                "grade": {
+                    "type": "string"
+                },
+                "photoUrl": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.BatchCreateWishRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controllers.BatchCreateWishItem"
+                    }
+                }
+            }
+        },
+        "controllers.BindWechatPhoneRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "getPhoneNumber 返回的动态令牌",
+                    "type": "string"
+                },
+                "encryptedData": {
+                    "description": "getPhoneNumber 返回的加密数据",
+                    "type": "string"
+                },
+                "iv": {
+                    "description": "加密算法的初始向量",
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.BindWechatPhoneResponse": {
+            "type": "object",
+            "properties": {
+                "phone": {
+                    "type": "string"
+                },
+                "phoneVerifiedAt": {
+                    "type": "integer"
+                }
+            }
+        },
+        "controllers.ChangePasswordRequest": {
+            "type": "object",
+            "required": [
+                "newPassword",
+                "oldPassword"
+            ],
+            "properties": {
+                "newPassword": {
+                    "type": "string"
+                },
+                "oldPassword": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.CollectGarbageRequest": {
+            "type": "object",
+            "properties": {
+                "dryRun": {
+                    "description": "只列出将要删除的文件，不实际删除",
+                    "type": "boolean"
+                }
+            }
+        },
+        "controllers.CompleteUploadResponse": {
+            "type": "object",
+            "properties": {
+                "ticket": {
+                    "$ref": "#/definitions/models.UploadTicket"
+                },
+                "url": {
+                    "description": "文件访问地址",
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.CreateInvitationRequest": {
+            "type": "object",
+            "properties": {
+                "note": {
+                    "type": "string"
+                },
+                "validHours": {
+                    "description": "有效小时数，默认72，最长720",
+                    "type": "integer"
+                }
+            }
+        },
+        "controllers.CreateInvitationResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "integer"
+                },
+                "createdById": {
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "type": "integer"
+                },
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "integer"
+                },
+                "revokedById": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.AdminInvitationStatus"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "integer"
+                },
+                "usedAt": {
+                    "type": "integer"
+                },
+                "usedById": {
+                    "type": "integer"
+                }
+            }
+        },
+        "controllers.CreateUploadTicketRequest": {
+            "type": "object",
+            "required": [
+                "contentType",
+                "purpose",
+                "size"
+            ],
+            "properties": {
+                "contentType": {
+                    "description": "image/jpeg、image/png、image/gif 或 image/webp",
+                    "type": "string"
+                },
+                "purpose": {
+                    "description": "上传用途：avatar、wish_photo、shipping_proof、receipt_photo 或 gift_photo",
+                    "type": "string"
+                },
+                "size": {
+                    "description": "文件大小，单位字节，上传时必须一致",
+                    "type": "integer"
+                }
+            }
+        },
+        "controllers.CreateUploadTicketResponse": {
+            "type": "object",
+            "properties": {
+                "request": {
+                    "description": "客户端按此发送上传请求，请求头必须原样携带",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/storage.PresignedRequest"
+                        }
+                    ]
+                },
+                "ticket": {
+                    "$ref": "#/definitions/models.UploadTicket"
+                }
+            }
+        },
+        "controllers.CreateWishRequest": {
+            "type": "object",
+            "properties": {
+                "childName": {
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "gender": {
+                    "$ref": "#/definitions/models.Gender"
+                },
+                "grade": {
+                    "type": "string"
+                },
+                "isPublished": {
+                    "type": "boolean"
+                },
+                "photoUrl": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.DisableTOTPRequest": {
+            "type": "object",
+            "required": [
+                "password"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.GetAdminUsersResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.User"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/utils.Pagination"
+                }
+            }
+        },
+        "controllers.GetAdminsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Admin"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/utils.Pagination"
+                }
+            }
+        },
+        "controllers.GetAuditLogsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AuditLog"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/utils.Pagination"
+                }
+            }
+        },
+        "controllers.GetDeletionRequestsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AccountDeletionRequest"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/utils.Pagination"
+                }
+            }
+        },
+        "controllers.GetInvitationsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controllers.InvitationResponse"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/utils.Pagination"
+                }
+            }
+        },
+        "controllers.GetLoginAttemptsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.LoginAttempt"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/utils.Pagination"
+                }
+            }
+        },
+        "controllers.GetNotificationDeliveriesResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.NotificationDelivery"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/utils.Pagination"
+                }
+            }
+        },
+        "controllers.GetSubscriptionsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controllers.SubscriptionTemplate"
+                    }
+                }
+            }
+        },
+        "controllers.GetWishRecordsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WishRecord"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/utils.Pagination"
+                }
+            }
+        },
+        "controllers.GetWishesResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.WishResponse"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/utils.Pagination"
+                }
+            }
+        },
+        "controllers.HealthzResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.InvitationResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "integer"
+                },
+                "createdById": {
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "type": "integer"
+                },
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "integer"
+                },
+                "revokedById": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.AdminInvitationStatus"
+                },
+                "updatedAt": {
+                    "type": "integer"
+                },
+                "usedAt": {
+                    "type": "integer"
+                },
+                "usedById": {
+                    "type": "integer"
+                }
+            }
+        },
+        "controllers.ProgressItem": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "信息，如有",
+                    "type": "string"
+                },
+                "photos": {
+                    "description": "照片，如有",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "对应的状态值",
+                    "type": "string"
+                },
+                "timestamp": {
+                    "description": "时间戳",
+                    "type": "integer"
+                },
+                "trackingNumber": {
+                    "description": "单号，如有",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "进度类型：creation, shipping, confirmation, delivery, receipt, cancellation",
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.RecordDetailResponse": {
+            "type": "object",
+            "properties": {
+                "childName": {
+                    "type": "string"
+                },
+                "claimedAt": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "type": "integer"
+                },
+                "donorAddress": {
+                    "type": "string"
+                },
+                "donorMobile": {
+                    "type": "string"
+                },
+                "donorName": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "记录基本信息",
+                    "type": "integer"
+                },
+                "progress": {
+                    "description": "进度数组",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controllers.ProgressItem"
+                    }
+                },
+                "status": {
+                    "$ref": "#/definitions/models.WishRecordStatus"
+                },
+                "updatedAt": {
+                    "type": "integer"
+                },
+                "wishContent": {
+                    "type": "string"
+                },
+                "wishReason": {
                     "type": "string"
                 }
             }
         },
-        "controllers.AdminLoginResponse": {
+        "controllers.RecoveryCodesResponse": {
             "type": "object",
             "properties": {
-                "admin": {
-                    "$ref": "#/definitions/models.Admin"
+                "recoveryCodes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "controllers.RequestDeletionRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.ResetPasswordRequest": {
+            "type": "object",
+            "required": [
+                "newPassword"
+            ],
+            "properties": {
+                "newPassword": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.RestrictUserRequest": {
+            "type": "object",
+            "required": [
+                "level",
+                "reason"
+            ],
+            "properties": {
+                "expiresAt": {
+                    "description": "到期时间（秒级时间戳），0 或不传表示永久",
+                    "type": "integer"
                 },
-                "token": {
+                "level": {
+                    "description": "ban 或 browse_only",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.RestrictionLevel"
+                        }
+                    ]
+                },
+                "reason": {
                     "type": "string"
                 }
             }
         },
-        "controllers.AdminRegisterRequest": {
+        "controllers.SubscriptionTemplate": {
+            "type": "object",
+            "properties": {
+                "event": {
+                    "description": "触发通知的记录状态",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.WishRecordStatus"
+                        }
+                    ]
+                },
+                "remaining": {
+                    "description": "剩余可发送条数",
+                    "type": "integer"
+                },
+                "templateId": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.TOTPCodeRequest": {
             "type": "object",
             "required": [
-                "password",
-                "username"
+                "code"
             ],
             "properties": {
-                "password": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.UpdateAdminProfileRequest": {
+            "type": "object",
+            "properties": {
+                "avatarUrl": {
                     "type": "string"
                 },
-                "username": {
+                "contactPhone": {
+                    "type": "string"
+                },
+                "department": {
+                    "type": "string"
+                },
+                "nickname": {
                     "type": "string"
                 }
             }
         },
-        "controllers.BatchCreateWishItem": {
+        "controllers.UpdateRecordStatusRequest": {
+            "type": "object",
+            "properties": {
+                "confirmationMessage": {
+                    "type": "string"
+                },
+                "confirmationPhotos": {
+                    "type": "string"
+                },
+                "deliveryNumber": {
+                    "type": "string"
+                },
+                "ownerGiftMessage": {
+                    "type": "string"
+                },
+                "ownerGiftPhotos": {
+                    "type": "string"
+                },
+                "platformGiftMessage": {
+                    "type": "string"
+                },
+                "platformGiftPhotos": {
+                    "type": "string"
+                },
+                "receiptMessage": {
+                    "type": "string"
+                },
+                "receiptPhotos": {
+                    "type": "string"
+                },
+                "shippingNumber": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.WishRecordStatus"
+                }
+            }
+        },
+        "controllers.UpdateShippingInfoRequest": {
+            "type": "object",
+            "required": [
+                "donorAddress",
+                "donorMobile",
+                "donorName"
+            ],
+            "properties": {
+                "donorAddress": {
+                    "type": "string"
+                },
+                "donorMobile": {
+                    "type": "string"
+                },
+                "donorName": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.UpdateSubscriptionsRequest": {
+            "type": "object",
+            "required": [
+                "results"
+            ],
+            "properties": {
+                "results": {
+                    "description": "模板ID -\u003e accept、reject、ban 或 filter",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "controllers.UpdateUserAdminRequest": {
+            "type": "object",
+            "required": [
+                "isAdmin"
+            ],
+            "properties": {
+                "isAdmin": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "controllers.UpdateUserProfileRequest": {
+            "type": "object",
+            "properties": {
+                "avatarUrl": {
+                    "type": "string"
+                },
+                "nickname": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.UpdateWishDonorRequest": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "donorMobile": {
+                    "description": "不填时使用已绑定的微信手机号",
+                    "type": "string"
+                },
+                "donorName": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.UpdateWishRequest": {
             "type": "object",
             "properties": {
                 "childName": {
@@ -1168,6 +4191,9 @@ const docTemplate = `{
                 "grade": {
                     "type": "string"
                 },
+                "isPublished": {
+                    "type": "boolean"
+                },
                 "photoUrl": {
                     "type": "string"
                 },
@@ -1176,122 +4202,192 @@ const docTemplate = `{
                 }
             }
         },
-        "controllers.BatchCreateWishRequest": {
+        "controllers.UploadImageResponse": {
             "type": "object",
             "properties": {
-                "data": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/controllers.BatchCreateWishItem"
-                    }
+                "display": {
+                    "description": "压缩后的展示图，心愿墙使用",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.UploadedImage"
+                        }
+                    ]
+                },
+                "original": {
+                    "$ref": "#/definitions/services.UploadedImage"
+                },
+                "thumbnail": {
+                    "description": "正方形缩略图",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.UploadedImage"
+                        }
+                    ]
+                },
+                "url": {
+                    "description": "去除元数据后的原图URL",
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.UserProfileResponse": {
+            "type": "object",
+            "properties": {
+                "avatarUrl": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isAdmin": {
+                    "type": "boolean"
+                },
+                "nickname": {
+                    "type": "string"
+                },
+                "phone": {
+                    "description": "经微信验证的手机号，未绑定时为空",
+                    "type": "string"
+                },
+                "role": {
+                    "$ref": "#/definitions/models.Role"
+                }
+            }
+        },
+        "controllers.WechatLoginRequest": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.WechatLoginResponse": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                },
+                "user": {
+                    "$ref": "#/definitions/models.User"
                 }
             }
         },
-        "controllers.CreateWishRequest": {
+        "middleware.JWK": {
             "type": "object",
             "properties": {
-                "childName": {
+                "alg": {
                     "type": "string"
                 },
-                "content": {
+                "crv": {
+                    "description": "OKP 曲线",
                     "type": "string"
                 },
-                "gender": {
-                    "$ref": "#/definitions/models.Gender"
+                "e": {
+                    "description": "RSA 指数",
+                    "type": "string"
                 },
-                "grade": {
+                "kid": {
                     "type": "string"
                 },
-                "isPublished": {
-                    "type": "boolean"
+                "kty": {
+                    "type": "string"
                 },
-                "photoUrl": {
+                "n": {
+                    "description": "RSA 模数",
                     "type": "string"
                 },
-                "reason": {
+                "use": {
                     "type": "string"
-                }
-            }
-        },
-        "controllers.GetAdminUsersResponse": {
-            "type": "object",
-            "properties": {
-                "items": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.User"
-                    }
                 },
-                "pagination": {
-                    "$ref": "#/definitions/utils.Pagination"
+                "x": {
+                    "description": "OKP 公钥",
+                    "type": "string"
                 }
             }
         },
-        "controllers.GetWishRecordsResponse": {
+        "middleware.JWKS": {
             "type": "object",
             "properties": {
-                "items": {
+                "keys": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.WishRecord"
+                        "$ref": "#/definitions/middleware.JWK"
                     }
-                },
-                "pagination": {
-                    "$ref": "#/definitions/utils.Pagination"
                 }
             }
         },
-        "controllers.GetWishesResponse": {
+        "models.AccountDeletionRequest": {
+            "description": "小程序用户的注销申请，冷静期结束后匿名化个人信息",
             "type": "object",
             "properties": {
-                "items": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Wish"
-                    }
+                "cancelledAt": {
+                    "type": "integer"
                 },
-                "pagination": {
-                    "$ref": "#/definitions/utils.Pagination"
-                }
-            }
-        },
-        "controllers.ProgressItem": {
-            "type": "object",
-            "properties": {
-                "message": {
-                    "description": "信息，如有",
-                    "type": "string"
+                "completedAt": {
+                    "type": "integer"
                 },
-                "photos": {
-                    "description": "照片，如有",
-                    "type": "string"
+                "createdAt": {
+                    "type": "integer"
                 },
-                "status": {
-                    "description": "对应的状态值",
-                    "type": "string"
+                "deletedAt": {
+                    "type": "integer"
                 },
-                "timestamp": {
-                    "description": "时间戳",
+                "id": {
                     "type": "integer"
                 },
-                "trackingNumber": {
-                    "description": "单号，如有",
+                "reason": {
                     "type": "string"
                 },
-                "type": {
-                    "description": "进度类型：creation, shipping, confirmation, delivery, receipt, cancellation",
-                    "type": "string"
+                "scheduledAt": {
+                    "description": "冷静期结束时间",
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.AccountDeletionStatus"
+                },
+                "updatedAt": {
+                    "type": "integer"
+                },
+                "userId": {
+                    "type": "integer"
                 }
             }
         },
-        "controllers.RecordDetailResponse": {
+        "models.AccountDeletionStatus": {
+            "description": "注销申请状态",
+            "type": "string",
+            "enum": [
+                "pending",
+                "cancelled",
+                "completed"
+            ],
+            "x-enum-comments": {
+                "DeletionCancelled": "用户已撤销",
+                "DeletionCompleted": "个人信息已匿名化",
+                "DeletionPending": "冷静期内，可以撤销"
+            },
+            "x-enum-varnames": [
+                "DeletionPending",
+                "DeletionCancelled",
+                "DeletionCompleted"
+            ]
+        },
+        "models.Admin": {
+            "description": "系统管理员信息",
             "type": "object",
             "properties": {
-                "childName": {
+                "avatarUrl": {
                     "type": "string"
                 },
-                "claimedAt": {
-                    "type": "integer"
+                "contactPhone": {
+                    "type": "string"
                 },
                 "createdAt": {
                     "type": "integer"
@@ -1299,227 +4395,386 @@ const docTemplate = `{
                 "deletedAt": {
                     "type": "integer"
                 },
-                "donorAddress": {
+                "department": {
                     "type": "string"
                 },
-                "donorMobile": {
+                "id": {
+                    "type": "integer"
+                },
+                "nickname": {
                     "type": "string"
                 },
-                "donorName": {
+                "password": {
                     "type": "string"
                 },
-                "id": {
-                    "description": "记录基本信息",
+                "passwordChangedAt": {
                     "type": "integer"
                 },
-                "progress": {
-                    "description": "进度数组",
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/controllers.ProgressItem"
-                    }
-                },
-                "status": {
-                    "$ref": "#/definitions/models.WishRecordStatus"
+                "totpEnabled": {
+                    "type": "boolean"
                 },
                 "updatedAt": {
                     "type": "integer"
                 },
-                "wishContent": {
-                    "type": "string"
-                },
-                "wishReason": {
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "controllers.UpdateRecordStatusRequest": {
+        "models.AdminInvitationStatus": {
+            "description": "管理员邀请状态",
+            "type": "string",
+            "enum": [
+                "pending",
+                "used",
+                "revoked",
+                "expired"
+            ],
+            "x-enum-varnames": [
+                "InvitationPending",
+                "InvitationUsed",
+                "InvitationRevoked",
+                "InvitationExpired"
+            ]
+        },
+        "models.AuditLog": {
+            "description": "审计日志，只允许追加，不允许修改和删除",
             "type": "object",
             "properties": {
-                "confirmationMessage": {
+                "action": {
                     "type": "string"
                 },
-                "confirmationPhotos": {
-                    "type": "string"
+                "actorId": {
+                    "type": "integer"
                 },
-                "deliveryNumber": {
+                "actorType": {
                     "type": "string"
                 },
-                "ownerGiftMessage": {
+                "after": {
+                    "description": "变更后快照（JSON）",
                     "type": "string"
                 },
-                "ownerGiftPhotos": {
+                "before": {
+                    "description": "变更前快照（JSON）",
                     "type": "string"
                 },
-                "platformGiftMessage": {
+                "createdAt": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip": {
                     "type": "string"
                 },
-                "platformGiftPhotos": {
+                "method": {
                     "type": "string"
                 },
-                "receiptMessage": {
+                "path": {
                     "type": "string"
                 },
-                "receiptPhotos": {
+                "statusCode": {
+                    "type": "integer"
+                },
+                "targetId": {
                     "type": "string"
                 },
-                "shippingNumber": {
+                "targetType": {
                     "type": "string"
                 },
-                "status": {
-                    "$ref": "#/definitions/models.WishRecordStatus"
+                "userAgent": {
+                    "type": "string"
                 }
             }
         },
-        "controllers.UpdateShippingInfoRequest": {
-            "type": "object",
-            "required": [
-                "donorAddress",
-                "donorMobile",
-                "donorName"
+        "models.Gender": {
+            "description": "用户性别类型",
+            "type": "string",
+            "enum": [
+                "male",
+                "female"
             ],
+            "x-enum-varnames": [
+                "Male",
+                "Female"
+            ]
+        },
+        "models.LoginAttempt": {
+            "description": "管理员登录尝试记录",
+            "type": "object",
             "properties": {
-                "donorAddress": {
-                    "type": "string"
+                "adminId": {
+                    "type": "integer"
                 },
-                "donorMobile": {
+                "createdAt": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip": {
                     "type": "string"
                 },
-                "donorName": {
+                "reason": {
+                    "description": "invalid_credentials, invalid_totp, locked",
                     "type": "string"
-                }
-            }
-        },
-        "controllers.UpdateUserAdminRequest": {
-            "type": "object",
-            "required": [
-                "isAdmin"
-            ],
-            "properties": {
-                "isAdmin": {
+                },
+                "success": {
                     "type": "boolean"
+                },
+                "userAgent": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
                 }
             }
         },
-        "controllers.UpdateWishDonorRequest": {
+        "models.NotificationDelivery": {
+            "description": "订阅消息投递记录",
             "type": "object",
             "properties": {
-                "address": {
-                    "type": "string"
+                "attempts": {
+                    "type": "integer"
                 },
-                "comment": {
+                "createdAt": {
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "type": "integer"
+                },
+                "event": {
+                    "description": "触发通知的记录状态",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.WishRecordStatus"
+                        }
+                    ]
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastError": {
                     "type": "string"
                 },
-                "donorMobile": {
+                "nextAttemptAt": {
+                    "type": "integer"
+                },
+                "payload": {
+                    "description": "发送的订阅消息，JSON 格式",
                     "type": "string"
                 },
-                "donorName": {
+                "recordId": {
+                    "type": "integer"
+                },
+                "sentAt": {
+                    "type": "integer"
+                },
+                "state": {
+                    "$ref": "#/definitions/models.NotificationState"
+                },
+                "templateId": {
                     "type": "string"
+                },
+                "updatedAt": {
+                    "type": "integer"
+                },
+                "userId": {
+                    "type": "integer"
                 }
             }
         },
-        "controllers.UpdateWishRequest": {
+        "models.NotificationState": {
+            "description": "订阅消息投递状态",
+            "type": "string",
+            "enum": [
+                "pending",
+                "sent",
+                "failed",
+                "skipped"
+            ],
+            "x-enum-comments": {
+                "NotificationFailed": "重试次数用完或不可重试的错误",
+                "NotificationPending": "等待发送或等待重试",
+                "NotificationSkipped": "用户没有剩余授权，未发送"
+            },
+            "x-enum-varnames": [
+                "NotificationPending",
+                "NotificationSent",
+                "NotificationFailed",
+                "NotificationSkipped"
+            ]
+        },
+        "models.RestrictionLevel": {
+            "description": "用户限制级别",
+            "type": "string",
+            "enum": [
+                "ban",
+                "browse_only"
+            ],
+            "x-enum-comments": {
+                "RestrictionBan": "封禁，不能访问任何需要登录的接口",
+                "RestrictionBrowseOnly": "只能浏览，不能认领心愿或提交修改"
+            },
+            "x-enum-varnames": [
+                "RestrictionBan",
+                "RestrictionBrowseOnly"
+            ]
+        },
+        "models.Role": {
+            "description": "角色，决定可用的权限集合",
+            "type": "string",
+            "enum": [
+                "donor",
+                "volunteer",
+                "admin"
+            ],
+            "x-enum-comments": {
+                "RoleAdmin": "后台系统管理员",
+                "RoleDonor": "普通小程序用户",
+                "RoleVolunteer": "被授予管理权限的小程序用户"
+            },
+            "x-enum-varnames": [
+                "RoleDonor",
+                "RoleVolunteer",
+                "RoleAdmin"
+            ]
+        },
+        "models.Upload": {
+            "description": "已上传到存储的文件。同一张图片的原图、展示图和缩略图共用 GroupKey，一起保留或删除",
             "type": "object",
             "properties": {
-                "childName": {
+                "contentType": {
                     "type": "string"
                 },
-                "content": {
+                "createdAt": {
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "type": "integer"
+                },
+                "groupKey": {
+                    "description": "原图的 key",
                     "type": "string"
                 },
-                "gender": {
-                    "$ref": "#/definitions/models.Gender"
+                "height": {
+                    "type": "integer"
                 },
-                "grade": {
+                "id": {
+                    "type": "integer"
+                },
+                "key": {
                     "type": "string"
                 },
-                "isPublished": {
-                    "type": "boolean"
+                "orphanedAt": {
+                    "description": "OrphanedAt 不再被任何数据引用的时间，0 表示正在被引用。超过保留期后由清理任务删除",
+                    "type": "integer"
                 },
-                "photoUrl": {
+                "purpose": {
                     "type": "string"
                 },
-                "reason": {
-                    "type": "string"
-                }
-            }
-        },
-        "controllers.UploadImageResponse": {
-            "type": "object",
-            "properties": {
-                "url": {
-                    "description": "上传成功后的图片URL",
+                "refCount": {
+                    "description": "RefCount 引用该组文件的数据条数，为 0 时才可以删除",
+                    "type": "integer"
+                },
+                "sha256": {
+                    "description": "SHA256 上传内容的摘要，同一用途下内容相同的图片只保存一份",
                     "type": "string"
-                }
-            }
-        },
-        "controllers.WechatLoginRequest": {
-            "type": "object",
-            "required": [
-                "code"
-            ],
-            "properties": {
-                "code": {
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "integer"
+                },
+                "uploaderId": {
+                    "type": "integer"
+                },
+                "uploaderType": {
+                    "description": "user 或 admin",
                     "type": "string"
-                }
-            }
-        },
-        "controllers.WechatLoginResponse": {
-            "type": "object",
-            "properties": {
-                "token": {
+                },
+                "variant": {
+                    "description": "original、display 或 thumbnail",
                     "type": "string"
                 },
-                "user": {
-                    "$ref": "#/definitions/models.User"
+                "width": {
+                    "type": "integer"
                 }
             }
         },
-        "controllers.WechatUserInfoRequest": {
+        "models.UploadTicket": {
+            "description": "客户端直传文件的上传凭证，约束了文件路径、类型和大小",
             "type": "object",
             "properties": {
-                "avatarUrl": {
-                    "type": "string"
+                "completedAt": {
+                    "type": "integer"
                 },
-                "nickName": {
+                "contentType": {
                     "type": "string"
-                }
-            }
-        },
-        "models.Admin": {
-            "description": "系统管理员信息",
-            "type": "object",
-            "properties": {
+                },
                 "createdAt": {
                     "type": "integer"
                 },
                 "deletedAt": {
                     "type": "integer"
                 },
+                "duplicateOf": {
+                    "description": "DuplicateOf 内容与同一用途下已有的文件相同时为该文件的 key，直传的副本已删除",
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "integer"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "password": {
+                "key": {
+                    "type": "string"
+                },
+                "processedKey": {
+                    "description": "ProcessedKey 重新编码后原图的 key，直传的文件已删除",
                     "type": "string"
                 },
+                "purpose": {
+                    "description": "上传用途",
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.UploadTicketStatus"
+                },
                 "updatedAt": {
                     "type": "integer"
                 },
-                "username": {
+                "userId": {
+                    "type": "integer"
+                },
+                "userType": {
+                    "description": "user 或 admin",
                     "type": "string"
                 }
             }
         },
-        "models.Gender": {
-            "description": "用户性别类型",
+        "models.UploadTicketStatus": {
+            "description": "上传凭证状态",
             "type": "string",
             "enum": [
-                "male",
-                "female"
+                "pending",
+                "completed",
+                "rejected"
             ],
+            "x-enum-comments": {
+                "UploadTicketCompleted": "已确认文件存在且符合约束",
+                "UploadTicketPending": "等待客户端上传",
+                "UploadTicketRejected": "上传的文件不符合约束，已删除"
+            },
             "x-enum-varnames": [
-                "Male",
-                "Female"
+                "UploadTicketPending",
+                "UploadTicketCompleted",
+                "UploadTicketRejected"
             ]
         },
         "models.User": {
@@ -1544,6 +4799,13 @@ const docTemplate = `{
                 "nickname": {
                     "type": "string"
                 },
+                "phone": {
+                    "description": "经微信验证的手机号，认领心愿时作为默认联系电话",
+                    "type": "string"
+                },
+                "phoneVerifiedAt": {
+                    "type": "integer"
+                },
                 "updatedAt": {
                     "type": "integer"
                 },
@@ -1555,6 +4817,48 @@ const docTemplate = `{
                 }
             }
         },
+        "models.UserRestriction": {
+            "description": "管理员对小程序用户施加的限制，解除后保留记录",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "integer"
+                },
+                "createdBy": {
+                    "description": "施加限制的管理员ID",
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "type": "integer"
+                },
+                "expiresAt": {
+                    "description": "到期时间，0 表示永久",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "level": {
+                    "$ref": "#/definitions/models.RestrictionLevel"
+                },
+                "liftedAt": {
+                    "type": "integer"
+                },
+                "liftedBy": {
+                    "description": "提前解除限制的管理员ID",
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "integer"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
         "models.Wish": {
             "description": "心愿信息",
             "type": "object",
@@ -1735,6 +5039,182 @@ const docTemplate = `{
                 "StatusCancelled"
             ]
         },
+        "services.BuildInfo": {
+            "type": "object",
+            "properties": {
+                "buildTime": {
+                    "type": "string"
+                },
+                "commit": {
+                    "type": "string"
+                },
+                "goVersion": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.GCReport": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "description": "删除的文件总大小",
+                    "type": "integer"
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "description": "从存储删除失败的 key，下次清理时重试",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Upload"
+                    }
+                }
+            }
+        },
+        "services.Readiness": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ReadinessCheck"
+                    }
+                },
+                "ready": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "services.ReadinessCheck": {
+            "type": "object",
+            "properties": {
+                "durationMs": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "ok": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "services.TOTPEnrollment": {
+            "type": "object",
+            "properties": {
+                "provisioningUri": {
+                    "description": "otpauth:// 链接，可渲染为二维码",
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.UploadedImage": {
+            "type": "object",
+            "properties": {
+                "contentType": {
+                    "type": "string"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.WishResponse": {
+            "type": "object",
+            "properties": {
+                "activeRecord": {
+                    "$ref": "#/definitions/models.WishRecord"
+                },
+                "activeRecordId": {
+                    "type": "integer"
+                },
+                "childName": {
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "type": "integer"
+                },
+                "gender": {
+                    "$ref": "#/definitions/models.Gender"
+                },
+                "grade": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isDone": {
+                    "type": "boolean"
+                },
+                "isPublished": {
+                    "type": "boolean"
+                },
+                "photoUrl": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "integer"
+                }
+            }
+        },
+        "storage.PresignedRequest": {
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "headers": {
+                    "description": "必须原样携带的请求头",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "method": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
         "utils.Pagination": {
             "type": "object",
             "properties": {
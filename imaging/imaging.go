@@ -0,0 +1,159 @@
+// Package imaging 对上传的图片重新编码，去除 EXIF 等元数据（包括 GPS 位置），
+// 按 EXIF 方向自动旋转，并生成展示图和缩略图。只使用纯 Go 实现的编解码器。
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册 GIF 解码
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册 WebP 解码
+)
+
+var ErrUnsupportedImage = errors.New("无法识别的图片")
+
+// Options 图片处理参数
+type Options struct {
+	DisplayMaxSize int // 展示图最长边，原图更小时不放大
+	ThumbnailSize  int // 缩略图边长，居中裁剪为正方形
+	JPEGQuality    int
+	Limits         Limits
+}
+
+// DefaultOptions 默认的图片处理参数
+var DefaultOptions = Options{
+	DisplayMaxSize: 1280,
+	ThumbnailSize:  320,
+	JPEGQuality:    85,
+	Limits: Limits{
+		MaxDimension: 8192,
+		MaxPixels:    40_000_000,
+	},
+}
+
+// Variant 处理后的一种图片规格
+type Variant struct {
+	Name        string // original、display 或 thumbnail
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+const (
+	VariantOriginal  = "original"
+	VariantDisplay   = "display"
+	VariantThumbnail = "thumbnail"
+)
+
+// Process 检查图片后解码，生成原图、展示图和缩略图三种规格。原图保持尺寸，但同样重新编码，
+// 不保留任何元数据。动图只保留第一帧。
+func Process(data []byte, opts Options) ([]Variant, error) {
+	info, err := Inspect(data, opts.Limits)
+	if err != nil {
+		return nil, err
+	}
+	format := info.Format
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	img := toNRGBA(src)
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	// 有透明通道的图片使用 PNG，PNG 原图保持 PNG，其余使用 JPEG
+	alpha := !img.Opaque()
+	originalPNG := alpha || format == "png"
+
+	original, err := encode(VariantOriginal, img, originalPNG, opts.JPEGQuality)
+	if err != nil {
+		return nil, err
+	}
+	display, err := encode(VariantDisplay, fit(img, opts.DisplayMaxSize), alpha, opts.JPEGQuality)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail, err := encode(VariantThumbnail, cover(img, opts.ThumbnailSize), alpha, opts.JPEGQuality)
+	if err != nil {
+		return nil, err
+	}
+	return []Variant{*original, *display, *thumbnail}, nil
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// fit 等比缩小到最长边不超过 maxSize
+func fit(img *image.NRGBA, maxSize int) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxSize && h <= maxSize {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxSize/w)
+		w = maxSize
+	} else {
+		w = max(1, w*maxSize/h)
+		h = maxSize
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// cover 居中裁剪为正方形并缩放到 size
+func cover(img *image.NRGBA, size int) *image.NRGBA {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	crop := image.Rect(x0, y0, x0+side, y0+side)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
+
+func encode(name string, img *image.NRGBA, asPNG bool, quality int) (*Variant, error) {
+	var buf bytes.Buffer
+	v := &Variant{Name: name, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if asPNG {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		v.ContentType, v.Ext = "image/png", ".png"
+	} else {
+		if err := jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		v.ContentType, v.Ext = "image/jpeg", ".jpg"
+	}
+	v.Data = buf.Bytes()
+	return v, nil
+}
+
+// flatten 把半透明像素合成到白色背景上，JPEG 不支持透明通道
+func flatten(img *image.NRGBA) image.Image {
+	if img.Opaque() {
+		return img
+	}
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
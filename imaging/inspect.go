@@ -0,0 +1,243 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+)
+
+var (
+	ErrImageTooLarge = errors.New("图片超出大小限制")
+	// ErrPolyglot 文件除图片外还夹带了其他内容，例如末尾追加的压缩包或嵌入的脚本
+	ErrPolyglot = errors.New("图片包含额外的数据")
+)
+
+// Limits 上传图片的限制，零值表示不限制
+type Limits struct {
+	MaxBytes     int64 // 文件大小
+	MaxDimension int   // 最长边像素
+	MaxPixels    int   // 总像素数，防止解压炸弹
+}
+
+// Info 根据文件内容识别出的图片信息
+type Info struct {
+	Format      string // jpeg、png、gif 或 webp
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+var formats = []struct {
+	name        string
+	contentType string
+	ext         string
+	match       func([]byte) bool
+	end         func([]byte) (int, error)
+}{
+	{"jpeg", "image/jpeg", ".jpg", func(b []byte) bool { return bytes.HasPrefix(b, []byte{0xFF, 0xD8, 0xFF}) }, jpegEnd},
+	{"png", "image/png", ".png", func(b []byte) bool { return bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")) }, pngEnd},
+	{"gif", "image/gif", ".gif", func(b []byte) bool {
+		return bytes.HasPrefix(b, []byte("GIF87a")) || bytes.HasPrefix(b, []byte("GIF89a"))
+	}, gifEnd},
+	{"webp", "image/webp", ".webp", func(b []byte) bool {
+		return len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP"
+	}, webpEnd},
+}
+
+// suspiciousMarkers 图片中不应出现的内容，常见于伪装成图片的网页脚本
+var suspiciousMarkers = [][]byte{
+	[]byte("<?php"),
+	[]byte("<script"),
+	[]byte("<html"),
+	[]byte("<svg"),
+	[]byte("<iframe"),
+	[]byte("<!doctype"),
+}
+
+// Sniff 根据文件开头的魔数识别图片格式，不信任客户端声明的类型和扩展名
+func Sniff(data []byte) (*Info, error) {
+	for _, f := range formats {
+		if f.match(data) {
+			return &Info{Format: f.name, ContentType: f.contentType, Ext: f.ext}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: 只支持JPG、PNG、GIF或WEBP格式的图片", ErrUnsupportedImage)
+}
+
+// Inspect 识别图片格式并检查限制：文件必须从头到尾都是一张完整的图片，
+// 末尾不能追加其他数据，也不能包含网页脚本；尺寸不能超过限制。只解析文件头，不解码像素。
+func Inspect(data []byte, limits Limits) (*Info, error) {
+	if limits.MaxBytes > 0 && int64(len(data)) > limits.MaxBytes {
+		return nil, fmt.Errorf("%w: 文件不能超过%dMB", ErrImageTooLarge, limits.MaxBytes/1024/1024)
+	}
+
+	info, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range formats {
+		if f.name != info.Format {
+			continue
+		}
+		end, err := f.end(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+		}
+		// 部分设备会在文件末尾补零，其余任何数据都视为夹带
+		if len(bytes.Trim(data[end:], "\x00")) > 0 {
+			return nil, fmt.Errorf("%w: 图片结束后还有%d字节", ErrPolyglot, len(data)-end)
+		}
+	}
+
+	lower := bytes.ToLower(data)
+	for _, marker := range suspiciousMarkers {
+		if bytes.Contains(lower, marker) {
+			return nil, fmt.Errorf("%w: 包含 %s", ErrPolyglot, marker)
+		}
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if format != info.Format {
+		return nil, fmt.Errorf("%w: 文件头与内容不一致", ErrUnsupportedImage)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, fmt.Errorf("%w: 无效的图片尺寸", ErrUnsupportedImage)
+	}
+	if limits.MaxDimension > 0 && (cfg.Width > limits.MaxDimension || cfg.Height > limits.MaxDimension) {
+		return nil, fmt.Errorf("%w: 图片宽高不能超过%d像素", ErrImageTooLarge, limits.MaxDimension)
+	}
+	if limits.MaxPixels > 0 && cfg.Width*cfg.Height > limits.MaxPixels {
+		return nil, fmt.Errorf("%w: 图片像素数不能超过%d", ErrImageTooLarge, limits.MaxPixels)
+	}
+
+	info.Width, info.Height = cfg.Width, cfg.Height
+	return info, nil
+}
+
+var errTruncated = errors.New("文件不完整")
+
+// jpegEnd 返回 EOI 标记之后的位置。扫描数据中的 0xFF 后跟 0x00 或 RST 标记，
+// 其余标记（渐进式 JPEG 的 DHT、SOS 等）按段长度跳过
+func jpegEnd(data []byte) (int, error) {
+	pos := 2
+	for pos+1 < len(data) {
+		if data[pos] != 0xFF {
+			// 熵编码数据
+			pos++
+			continue
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0x00 || marker == 0xFF || (marker >= 0xD0 && marker <= 0xD7):
+			pos++
+			if marker != 0xFF {
+				pos++
+			}
+		case marker == 0xD9:
+			return pos + 2, nil
+		default:
+			if pos+4 > len(data) {
+				return 0, errTruncated
+			}
+			length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+			if length < 2 {
+				return 0, errors.New("无效的JPEG段")
+			}
+			pos += 2 + length
+		}
+	}
+	return 0, errTruncated
+}
+
+// pngEnd 返回 IEND 块之后的位置
+func pngEnd(data []byte) (int, error) {
+	pos := 8
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		if length < 0 || length > len(data) {
+			return 0, errTruncated
+		}
+		chunk := string(data[pos+4 : pos+8])
+		next := pos + 12 + length
+		if next > len(data) {
+			return 0, errTruncated
+		}
+		if chunk == "IEND" {
+			return next, nil
+		}
+		pos = next
+	}
+	return 0, errTruncated
+}
+
+// gifEnd 返回结束符 0x3B 之后的位置
+func gifEnd(data []byte) (int, error) {
+	if len(data) < 13 {
+		return 0, errTruncated
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipSubBlocks 跳过以长度为0的块结尾的数据子块
+	skipSubBlocks := func() error {
+		for {
+			if pos >= len(data) {
+				return errTruncated
+			}
+			size := int(data[pos])
+			pos++
+			if size == 0 {
+				return nil
+			}
+			pos += size
+		}
+	}
+
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B:
+			return pos + 1, nil
+		case 0x21:
+			pos += 2
+			if err := skipSubBlocks(); err != nil {
+				return 0, err
+			}
+		case 0x2C:
+			if pos+10 > len(data) {
+				return 0, errTruncated
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			// LZW 最小码长
+			pos++
+			if err := skipSubBlocks(); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, errors.New("无效的GIF块")
+		}
+	}
+	return 0, errTruncated
+}
+
+// webpEnd 返回 RIFF 头中声明的文件结束位置
+func webpEnd(data []byte) (int, error) {
+	size := int(binary.LittleEndian.Uint32(data[4:8]))
+	end := 8 + size + size%2
+	if size < 4 || end > len(data)+size%2 {
+		return 0, errTruncated
+	}
+	return min(end, len(data)), nil
+}
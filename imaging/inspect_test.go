@@ -0,0 +1,161 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(32, 24), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(32, 24)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngChunk 编码一个带校验和的 PNG 块
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// bombPNG 只有文件头声明了宽高的 PNG，文件很小，解码时却需要 width*height*4 字节内存
+func bombPNG(width, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32(nil, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8位 RGBA
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = append(data, pngChunk("IHDR", ihdr)...)
+	data = append(data, pngChunk("IDAT", []byte{0x78, 0x9c, 0x03, 0x00, 0x00, 0x00, 0x00, 0x01})...)
+	return append(data, pngChunk("IEND", nil)...)
+}
+
+// insertChunk 在 IHDR 之后插入一个块
+func insertChunk(data []byte, chunk []byte) []byte {
+	at := 8 + 12 + 13
+	result := append([]byte{}, data[:at]...)
+	result = append(result, chunk...)
+	return append(result, data[at:]...)
+}
+
+func TestInspectAcceptsImages(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"jpeg":    testJPEG(t),
+		"png":     testPNG(t),
+		"png末尾补零": append(testPNG(t), make([]byte, 16)...),
+	} {
+		info, err := Inspect(data, DefaultOptions.Limits)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if info.Width != 32 || info.Height != 24 {
+			t.Errorf("%s: 尺寸应为 32x24，实际 %dx%d", name, info.Width, info.Height)
+		}
+	}
+}
+
+func TestInspectRejectsPolyglots(t *testing.T) {
+	zip := []byte("PK\x03\x04\x14\x00\x00\x00\x08\x00payload.php")
+	cases := map[string][]byte{
+		"JPEG末尾追加压缩包": append(testJPEG(t), zip...),
+		"PNG末尾追加压缩包":  append(testPNG(t), zip...),
+		"PNG文本块中的脚本":  insertChunk(testPNG(t), pngChunk("tEXt", []byte("Comment\x00<?php system($_GET['c']); ?>"))),
+		"JPEG注释中的网页":  insertJPEGComment(testJPEG(t), "<SCRIPT>alert(1)</SCRIPT>"),
+	}
+	for name, data := range cases {
+		if _, err := Inspect(data, DefaultOptions.Limits); !errors.Is(err, ErrPolyglot) {
+			t.Errorf("%s: 应返回 ErrPolyglot，实际 %v", name, err)
+		}
+	}
+}
+
+// insertJPEGComment 在 SOI 之后插入 COM 段
+func insertJPEGComment(data []byte, comment string) []byte {
+	segment := []byte{0xFF, 0xFE}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(comment)+2))
+	segment = append(segment, comment...)
+	result := append([]byte{}, data[:2]...)
+	result = append(result, segment...)
+	return append(result, data[2:]...)
+}
+
+func TestInspectRejectsDecompressionBombs(t *testing.T) {
+	cases := []struct {
+		name   string
+		data   []byte
+		limits Limits
+	}{
+		{"宽高超出限制", bombPNG(100_000, 100_000), DefaultOptions.Limits},
+		{"宽高未超出但像素数超出", bombPNG(8000, 8000), DefaultOptions.Limits},
+		{"用途的最长边限制", testPNG(t), Limits{MaxDimension: 16}},
+		{"用途的文件大小限制", testPNG(t), Limits{MaxBytes: 64}},
+	}
+	for _, tc := range cases {
+		if _, err := Inspect(tc.data, tc.limits); !errors.Is(err, ErrImageTooLarge) {
+			t.Errorf("%s: 应返回 ErrImageTooLarge，实际 %v", tc.name, err)
+		}
+	}
+
+	// Process 在解码之前检查，不会为炸弹分配内存
+	if _, err := Process(bombPNG(100_000, 100_000), DefaultOptions); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Process 应返回 ErrImageTooLarge，实际 %v", err)
+	}
+}
+
+func TestInspectRejectsMalformedImages(t *testing.T) {
+	jpg := testJPEG(t)
+	cases := map[string][]byte{
+		"不是图片":      []byte("<html><body>hello</body></html>"),
+		"SVG":       []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`),
+		"截断的JPEG":   jpg[:len(jpg)/2],
+		"截断的PNG":    bombPNG(32, 24)[:40],
+		"GIF文件头":    append([]byte("GIF89a"), testPNG(t)...),
+		"声明过大的WEBP": []byte("RIFF\xff\xff\xff\x00WEBPVP8 "),
+	}
+	for name, data := range cases {
+		if _, err := Inspect(data, DefaultOptions.Limits); !errors.Is(err, ErrUnsupportedImage) {
+			t.Errorf("%s: 应返回 ErrUnsupportedImage，实际 %v", name, err)
+		}
+	}
+}
+
+func TestSniffIgnoresDeclaredType(t *testing.T) {
+	// 格式只由内容决定，扩展名和类型由服务端生成
+	info, err := Sniff(testPNG(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "png" || info.ContentType != "image/png" || info.Ext != ".png" {
+		t.Fatalf("应识别为 PNG: %+v", info)
+	}
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger 通过 slog 输出数据库日志：查询出错时输出错误，超过 SlowThreshold 时输出慢查询警告。
+// SQL 中的参数不会输出，避免把用户数据写入日志
+type GormLogger struct {
+	SlowThreshold time.Duration // 0 表示不记录慢查询
+	level         gormlogger.LogLevel
+}
+
+// NewGormLogger 创建数据库日志记录器
+func NewGormLogger(slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{SlowThreshold: slowThreshold, level: gormlogger.Warn}
+}
+
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "数据库查询失败",
+			slog.String("error", err.Error()),
+			slog.String("sql", sql),
+			slog.Int64("rows", rows),
+			slog.Float64("duration_ms", durationMillis(elapsed)),
+		)
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "数据库慢查询",
+			slog.String("sql", sql),
+			slog.Int64("rows", rows),
+			slog.Float64("duration_ms", durationMillis(elapsed)),
+			slog.Float64("threshold_ms", durationMillis(l.SlowThreshold)),
+		)
+	case l.level >= gormlogger.Info:
+		sql, rows := fc()
+		slog.DebugContext(ctx, "数据库查询",
+			slog.String("sql", sql),
+			slog.Int64("rows", rows),
+			slog.Float64("duration_ms", durationMillis(elapsed)),
+		)
+	}
+}
+
+// ParamsFilter 丢弃 SQL 的参数，输出的 SQL 中保留占位符
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	return sql, nil
+}
+
+// durationMillis 把耗时转换为毫秒，保留小数
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,143 @@
+// Package logging 基于 log/slog 输出结构化日志。请求相关的日志自动带上请求ID、路由和当前用户，
+// 密码、令牌、手机号等敏感字段在输出前脱敏
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Options 日志输出配置
+type Options struct {
+	Level  slog.Level
+	Format string    // json 或 text，默认为 json
+	Output io.Writer // 默认为标准输出
+}
+
+// ParseLevel 解析 debug、info、warn 或 error，空字符串视为 info
+func ParseLevel(s string) (slog.Level, error) {
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("无效的日志级别 %q，可选 debug、info、warn 或 error", s)
+	}
+	return level, nil
+}
+
+// New 按配置创建日志记录器
+func New(opts Options) (*slog.Logger, error) {
+	output := opts.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	handlerOpts := &slog.HandlerOptions{
+		Level:       opts.Level,
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "json":
+		handler = slog.NewJSONHandler(output, handlerOpts)
+	case "text":
+		handler = slog.NewTextHandler(output, handlerOpts)
+	default:
+		return nil, fmt.Errorf("不支持的日志格式 %s，可选 json 或 text", opts.Format)
+	}
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// Setup 创建日志记录器并设为 slog 的默认记录器，标准库 log 的输出也会转到这里
+func Setup(opts Options) error {
+	logger, err := New(opts)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// RequestFields 请求相关的日志字段，由中间件在请求开始时创建，认证通过后补充用户信息
+type RequestFields struct {
+	mu        sync.RWMutex
+	requestID string
+	route     string
+	userID    uint
+	userType  string
+}
+
+type requestFieldsKey struct{}
+
+// WithRequest 创建请求的日志字段并放入 ctx
+func WithRequest(ctx context.Context, requestID, route string) (context.Context, *RequestFields) {
+	fields := &RequestFields{requestID: requestID, route: route}
+	return context.WithValue(ctx, requestFieldsKey{}, fields), fields
+}
+
+// FromContext 返回 ctx 中的请求日志字段，不在请求中时返回 nil
+func FromContext(ctx context.Context) *RequestFields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(requestFieldsKey{}).(*RequestFields)
+	return fields
+}
+
+// SetUser 记录当前请求的用户，fields 为 nil 时不做任何事
+func (f *RequestFields) SetUser(userID uint, userType string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.userID = userID
+	f.userType = userType
+}
+
+// RequestID 返回请求ID
+func (f *RequestFields) RequestID() string {
+	if f == nil {
+		return ""
+	}
+	return f.requestID
+}
+
+func (f *RequestFields) attrs() []slog.Attr {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	attrs := []slog.Attr{slog.String("request_id", f.requestID)}
+	if f.route != "" {
+		attrs = append(attrs, slog.String("route", f.route))
+	}
+	if f.userType != "" {
+		attrs = append(attrs, slog.Uint64("user_id", uint64(f.userID)), slog.String("user_type", f.userType))
+	}
+	return attrs
+}
+
+// contextHandler 为带有请求信息的日志补充请求ID、路由和用户
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if fields := FromContext(ctx); fields != nil {
+		r.AddAttrs(fields.attrs()...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
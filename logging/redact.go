@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Redacted 替换敏感字段的值
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys 日志字段或查询参数名包含这些词时不输出原值，比较时忽略大小写、下划线和连字符
+var sensitiveKeys = []string{
+	"password",
+	"secret",
+	"token",
+	"authorization",
+	"cookie",
+	"signature",
+	"sessionkey",
+	"openid",
+	"unionid",
+	"phone",
+	"mobile",
+	"address",
+	"totp",
+	"recoverycode",
+}
+
+// sensitiveExactKeys 只在完全相同时才视为敏感的字段名，避免误伤 status_code 等字段
+var sensitiveExactKeys = map[string]bool{
+	"code": true, // 小程序登录和手机号授权的 code
+}
+
+// IsSensitive 判断字段名是否属于敏感字段
+func IsSensitive(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	if sensitiveExactKeys[normalized] {
+		return true
+	}
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(normalized, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindGroup && IsSensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// RedactQuery 返回脱敏后的查询字符串，敏感参数的值替换为 [REDACTED]
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	for key := range values {
+		if IsSensitive(key) {
+			values[key] = []string{Redacted}
+		}
+	}
+	// 占位符不转义，便于阅读
+	return strings.ReplaceAll(values.Encode(), url.QueryEscape(Redacted), Redacted)
+}
@@ -1,13 +1,30 @@
 package main
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 	"wishes/config"
 	"wishes/controllers"
 	_ "wishes/docs"
+	"wishes/logging"
+	"wishes/metrics"
 	"wishes/middleware"
 	"wishes/routes"
 	"wishes/services"
+	"wishes/storage"
+	"wishes/wechat"
+
+	"github.com/gin-gonic/gin"
 )
 
 // @title           心愿墙 API
@@ -18,32 +35,219 @@ func main() {
 	cst8 := time.FixedZone("CST", 8*3600)
 	time.Local = cst8
 
+	// 子命令，例如 server create-admin
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:], cst8); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg := config.LoadConfig()
-	middleware.InitJWTSecret(cfg.JWTSecret)
-	db := config.InitDB(cfg, cst8)
+	if err := setupLogging(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "初始化日志失败: %v\n", err)
+		os.Exit(1)
+	}
+	jwtKeys, err := loadJWTKeys(cfg)
+	if err != nil {
+		fatal("加载JWT密钥失败", err)
+	}
+	middleware.InitJWTKeys(jwtKeys)
+	db, err := config.InitDB(cfg, cst8)
+	if err != nil {
+		fatal("初始化数据库失败", err)
+	}
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		fatal("启用数据库指标失败", err)
+	}
+
+	wechatClient := wechat.NewClient(wechat.Options{
+		AppID:      cfg.WechatAppID,
+		AppSecret:  cfg.WechatAppSecret,
+		BaseURL:    cfg.WechatAPIBaseURL,
+		Timeout:    cfg.WechatAPITimeout,
+		MaxRetries: cfg.WechatAPIMaxRetries,
+	})
+
+	store, err := newStorage(cfg)
+	if err != nil {
+		fatal("初始化文件存储失败", err)
+	}
 
 	// 初始化服务
-	wechatService := services.NewWechatService(db, cfg.WechatAppID, cfg.WechatAppSecret, cfg.JWTSecret)
-	wishService := services.NewWishService(db)
-	recordService := services.NewRecordService(db)
-	userService := services.NewUserService(db)
-	storageService := services.NewStorageService(cfg)
+	wechatService := services.NewWechatService(db, wechatClient)
+	uploadTracker := services.NewUploadTracker(db, store, cfg)
+	wishService := services.NewWishService(db, uploadTracker)
+	recordService := services.NewRecordService(db, uploadTracker)
+	userService := services.NewUserService(db, uploadTracker)
+	adminService := services.NewAdminService(db, uploadTracker, cfg)
+	auditService := services.NewAuditService(db)
+	notificationService := services.NewNotificationService(db, wechatService, cfg)
+	accountService := services.NewAccountService(db, uploadTracker, cfg)
+	restrictionService := services.NewRestrictionService(db)
+	storageService := services.NewStorageService(db, store, uploadTracker, cfg)
+	healthService := services.NewHealthService(db, store, buildInfo())
+	metrics.Registry.MustRegister(services.NewRecordStatusCollector(db))
+
+	// 已签发的令牌也要受封禁和注销的约束
+	middleware.SetUserAccessChecker(restrictionService.CheckUserAccess)
+	middleware.SetAdminAccessChecker(adminService.CheckAdminAccess)
 
 	// 初始化控制器
-	authController := controllers.NewAuthController(db, wechatService)
-	wishController := controllers.NewWishController(wishService, recordService, userService)
-	recordController := controllers.NewRecordController(recordService)
+	authController := controllers.NewAuthController(db, wechatService, adminService)
+	wishController := controllers.NewWishController(wishService, recordService, userService, restrictionService, storageService)
+	recordController := controllers.NewRecordController(recordService, notificationService, storageService)
 	userController := controllers.NewUserController(userService)
-	uploadController := controllers.NewUploadController(storageService)
+	adminController := controllers.NewAdminController(adminService)
+	auditController := controllers.NewAuditController(auditService)
+	notificationController := controllers.NewNotificationController(notificationService)
+	accountController := controllers.NewAccountController(accountService)
+	restrictionController := controllers.NewRestrictionController(restrictionService)
+	uploadController := controllers.NewUploadController(storageService, uploadTracker)
+	healthController := controllers.NewHealthController(healthService)
 
 	// 设置路由
 	r := routes.SetupRouter(routes.SetupRouterOptions{
-		AuthController:   authController,
-		WishController:   wishController,
-		RecordController: recordController,
-		UserController:   userController,
-		UploadController: uploadController,
+		AuthController:         authController,
+		WishController:         wishController,
+		RecordController:       recordController,
+		UserController:         userController,
+		AdminController:        adminController,
+		AuditController:        auditController,
+		NotificationController: notificationController,
+		AccountController:      accountController,
+		RestrictionController:  restrictionController,
+		UploadController:       uploadController,
+		HealthController:       healthController,
+		LocalStorage:           localStorage(store),
+		AuditRecorder:          auditService,
+		MetricsToken:           cfg.MetricsToken,
 	})
 
-	r.Run(cfg.ServerAddress)
+	// 收到 SIGINT 或 SIGTERM 后停止接受新请求，处理完已有请求再退出
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 后台任务在服务停止后才结束，避免处理中的请求依赖的任务提前退出
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	workers := []<-chan struct{}{
+		// 后台发送订阅消息
+		notificationService.Start(workerCtx),
+		// 后台处理冷静期已结束的注销申请
+		accountService.Start(workerCtx, time.Hour),
+		// 后台清理不再被引用的上传文件
+		uploadTracker.Start(workerCtx, cfg.UploadGCInterval),
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.ServerAddress,
+		Handler:           r,
+		ReadTimeout:       cfg.ServerReadTimeout,
+		ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+		WriteTimeout:      cfg.ServerWriteTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
+	}
+
+	exitCode := 0
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		slog.Error("监听端口失败", slog.String("addr", srv.Addr), slog.String("error", err.Error()))
+		exitCode = 1
+	} else {
+		slog.Info("服务已启动", slog.String("addr", ln.Addr().String()), slog.String("commit", healthService.BuildInfo().Commit))
+		serveCtx := drainFirst(ctx, cfg.ServerShutdownDelay, healthService.SetShuttingDown)
+		if err := serve(serveCtx, srv, ln, cfg.ServerShutdownTimeout); err != nil {
+			slog.Error("服务异常退出", slog.String("error", err.Error()))
+			exitCode = 1
+		}
+		slog.Info("服务已停止，等待后台任务结束")
+	}
+
+	stopWorkers()
+	for _, done := range workers {
+		<-done
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Error("关闭数据库失败", slog.String("error", err.Error()))
+			exitCode = 1
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// setupLogging 按 LOG_LEVEL 和 LOG_FORMAT 设置默认的 slog 记录器
+func setupLogging(cfg *config.Config) error {
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	return logging.Setup(logging.Options{Level: level, Format: cfg.LogFormat})
+}
+
+// fatal 记录启动失败的原因并退出
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.String("error", err.Error()))
+	os.Exit(1)
+}
+
+// loadJWTKeys 配置了密钥目录时使用非对称密钥签名。只有非 release 模式才允许退回到 JWT_SECRET，
+// 便于本地开发；生产环境未配置密钥目录时拒绝启动
+func loadJWTKeys(cfg *config.Config) (*middleware.KeySet, error) {
+	if cfg.JWTKeysDir != "" {
+		return middleware.LoadKeySet(cfg.JWTKeysDir, cfg.JWTSigningKID, cfg.JWTSecret)
+	}
+	if gin.Mode() == gin.ReleaseMode {
+		return nil, errors.New("release 模式必须通过 JWT_KEYS_DIR 配置非对称签名密钥")
+	}
+	return middleware.NewHMACKeySet(cfg.JWTSecret)
+}
+
+// newStorage 按 STORAGE_BACKEND 创建文件存储后端
+func newStorage(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.StorageBackend {
+	case "cos":
+		return storage.NewCOSStorage(storage.COSOptions{
+			SecretID:  cfg.COSSecretID,
+			SecretKey: cfg.COSSecretKey,
+			Region:    cfg.COSRegion,
+			Bucket:    cfg.COSBucketName,
+			BaseURL:   cfg.COSBaseURL,
+		})
+	case "local":
+		local, err := storage.NewLocalStorage(cfg.StorageLocalDir, cfg.StorageLocalURLPrefix)
+		if err != nil {
+			return nil, err
+		}
+		local.SetSigningKey(storageSigningKey(cfg))
+		return local, nil
+	case "memory":
+		slog.Warn("使用内存存储，重启后上传的文件会丢失")
+		return storage.NewMemoryStorage(""), nil
+	default:
+		return nil, fmt.Errorf("不支持的存储后端 %s，可选 cos、local 或 memory", cfg.StorageBackend)
+	}
+}
+
+// storageSigningKey 返回签发私有文件访问地址的密钥。未单独配置时由 JWT_SECRET 派生，
+// 避免同一个密钥同时用于两种签名
+func storageSigningKey(cfg *config.Config) []byte {
+	if len(cfg.StorageSigningKey) > 0 {
+		return cfg.StorageSigningKey
+	}
+	if len(cfg.JWTSecret) == 0 {
+		slog.Warn("未设置 STORAGE_SIGNING_KEY，无法访问本地存储中的私有文件")
+		return nil
+	}
+	mac := hmac.New(sha256.New, cfg.JWTSecret)
+	mac.Write([]byte("storage-url-signing"))
+	return mac.Sum(nil)
+}
+
+// localStorage 使用本地存储时返回它，用于提供静态文件服务
+func localStorage(store storage.Storage) *storage.LocalStorage {
+	local, _ := store.(*storage.LocalStorage)
+	return local
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"wishes/config"
+)
+
+func TestLoadJWTKeysRequiresKeyDirInRelease(t *testing.T) {
+	cfg := &config.Config{JWTSecret: bytes.Repeat([]byte("x"), 32)}
+
+	mode := gin.Mode()
+	t.Cleanup(func() { gin.SetMode(mode) })
+
+	gin.SetMode(gin.ReleaseMode)
+	if _, err := loadJWTKeys(cfg); err == nil {
+		t.Error("release 模式未配置密钥目录时应拒绝启动")
+	}
+
+	gin.SetMode(gin.DebugMode)
+	if _, err := loadJWTKeys(cfg); err != nil {
+		t.Errorf("开发模式可以使用 JWT_SECRET: %v", err)
+	}
+}
+
+func TestRetireJWTKeyRefusesCurrentSigningKey(t *testing.T) {
+	dir := t.TempDir()
+	for _, kid := range []string{"20260101-aaaa", "20260201-bbbb"} {
+		if err := genJWTKeyCommand([]string{"-dir", dir, "-kid", kid}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 未设置 JWT_SIGNING_KID 时，kid 最大的私钥就是签名密钥
+	t.Setenv("JWT_SIGNING_KID", "")
+	if err := retireJWTKeyCommand([]string{"-dir", dir, "-kid", "20260201-bbbb"}); err == nil {
+		t.Error("不能停用 kid 最大的私钥")
+	}
+
+	t.Setenv("JWT_SIGNING_KID", "20260101-aaaa")
+	if err := retireJWTKeyCommand([]string{"-dir", dir, "-kid", "20260101-aaaa"}); err == nil {
+		t.Error("不能停用 JWT_SIGNING_KID 指定的密钥")
+	}
+	if err := retireJWTKeyCommand([]string{"-dir", dir, "-kid", "20260201-bbbb"}); err != nil {
+		t.Errorf("应可以停用其他密钥: %v", err)
+	}
+}
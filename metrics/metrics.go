@@ -0,0 +1,94 @@
+// Package metrics 定义服务的 Prometheus 指标，包括 HTTP 请求、数据库查询和业务事件，
+// 由 Handler 在 /metrics 输出
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "wishes"
+
+// Registry 本服务的指标注册表，不使用全局默认注册表，避免依赖库注册的指标混入
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// HTTP 请求。route 是路由模板（例如 /api/v1/wishes/:id），避免按实际路径产生过多的标签值
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "按路由、方法和状态码统计的 HTTP 请求数",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "按路由和方法统计的 HTTP 请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	HTTPRequestsInFlight = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "正在处理的 HTTP 请求数",
+	})
+)
+
+// 数据库查询。operation 是 create、query、update、delete、row 或 raw
+var (
+	DBQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "按操作和数据表统计的数据库查询耗时",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table"})
+
+	DBQueryErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "按操作和数据表统计的数据库查询错误数，不包括查询不到记录",
+	}, []string{"operation", "table"})
+)
+
+// 业务事件
+var (
+	WishesClaimed = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "wishes_claimed_total",
+		Help:      "被认领的心愿数",
+	})
+
+	RecordsCompleted = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_completed_total",
+		Help:      "进入已完成状态的认领记录数",
+	})
+
+	Uploads = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uploads_total",
+		Help:      "按用途统计的上传图片数，deduplicated 表示内容与已有图片相同、未保存副本",
+	}, []string{"purpose", "deduplicated"})
+)
+
+// Handler 输出 Registry 中的所有指标
+func Handler() http.Handler {
+	// 个别指标统计失败（例如查询数据库超时）时仍输出其余指标
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+		Registry:      Registry,
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"wishes/logging"
+	"wishes/models"
+)
+
+const (
+	auditContextKey = "auditEntry"
+	auditSaltKey    = "auditSalt"
+)
+
+// AuditRecorder 持久化审计日志，由 services.AuditService 实现
+type AuditRecorder interface {
+	Record(entry *models.AuditLog) error
+}
+
+// Audit 为所有写操作记录审计日志。日志在 defer 中写入，
+// 因此处理函数提前返回或 panic 都不会跳过记录。
+func Audit(recorder AuditRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		entry := &models.AuditLog{}
+		c.Set(auditContextKey, entry)
+		// 同一条日志的变更前后快照使用相同的盐，盐不保存，不同日志之间的摘要无法关联
+		salt := make([]byte, 16)
+		rand.Read(salt)
+		c.Set(auditSaltKey, string(salt))
+
+		defer func() {
+			p := recover()
+
+			if entry.Action == "" {
+				entry.Action = c.Request.Method + " " + c.FullPath()
+			}
+			if userID, ok := c.Get("userID"); ok {
+				entry.ActorID, _ = userID.(uint)
+			}
+			if userType, ok := c.Get("userType"); ok {
+				entry.ActorType, _ = userType.(string)
+			}
+			entry.Method = c.Request.Method
+			entry.Path = c.Request.URL.Path
+			entry.StatusCode = c.Writer.Status()
+			if p != nil {
+				entry.StatusCode = http.StatusInternalServerError
+			}
+			entry.IP = c.ClientIP()
+			entry.UserAgent = c.Request.UserAgent()
+
+			if err := recorder.Record(entry); err != nil {
+				slog.ErrorContext(c.Request.Context(), "写入审计日志失败", slog.String("error", err.Error()))
+			}
+
+			if p != nil {
+				panic(p)
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+func currentAuditEntry(c *gin.Context) *models.AuditLog {
+	value, ok := c.Get(auditContextKey)
+	if !ok {
+		return nil
+	}
+	entry, _ := value.(*models.AuditLog)
+	return entry
+}
+
+// SetAuditAction 设置审计动作名称，例如 wish.delete
+func SetAuditAction(c *gin.Context, action string) {
+	if entry := currentAuditEntry(c); entry != nil {
+		entry.Action = action
+	}
+}
+
+// SetAuditTarget 设置操作对象
+func SetAuditTarget(c *gin.Context, targetType string, targetID any) {
+	if entry := currentAuditEntry(c); entry != nil {
+		entry.TargetType = targetType
+		entry.TargetID = fmt.Sprint(targetID)
+	}
+}
+
+// SetAuditBefore 记录变更前快照，调用时立即序列化，之后对 v 的修改不会影响快照。
+// 审计日志不可修改，注销账号时无法清除，快照中的个人信息字段在序列化时替换为 [REDACTED:摘要]，
+// 摘要相同说明该字段在变更前后没有变化
+func SetAuditBefore(c *gin.Context, v any) {
+	if entry := currentAuditEntry(c); entry != nil {
+		entry.Before = auditSnapshot(v, c.GetString(auditSaltKey))
+	}
+}
+
+// SetAuditAfter 记录变更后快照
+func SetAuditAfter(c *gin.Context, v any) {
+	if entry := currentAuditEntry(c); entry != nil {
+		entry.After = auditSnapshot(v, c.GetString(auditSaltKey))
+	}
+}
+
+// auditPersonalKeys 注销账号时会被匿名化、但不属于 logging.IsSensitive 的个人信息字段
+var auditPersonalKeys = map[string]bool{
+	"nickname":       true,
+	"avatarUrl":      true,
+	"donorName":      true,
+	"donorComment":   true,
+	"shippingNumber": true,
+}
+
+func auditSnapshot(v any, salt string) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%q", fmt.Sprint(v))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return string(data)
+	}
+	data, err = json.Marshal(redactSnapshot(value, salt))
+	if err != nil {
+		return fmt.Sprintf("%q", fmt.Sprint(v))
+	}
+	return string(data)
+}
+
+// redactSnapshot 递归替换快照中个人信息字段的值，空值保持原样
+func redactSnapshot(value any, salt string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if field == nil || field == "" {
+				continue
+			}
+			if logging.IsSensitive(key) || auditPersonalKeys[key] {
+				v[key] = redactedDigest(field, salt)
+				continue
+			}
+			v[key] = redactSnapshot(field, salt)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactSnapshot(item, salt)
+		}
+	}
+	return value
+}
+
+// redactedDigest 返回带值摘要的占位符，例如 [REDACTED:1a2b3c4d]。
+// 摘要只用于比较同一条日志中字段是否变化，盐只在请求期间存在，无法通过枚举手机号等取值反推原值
+func redactedDigest(value any, salt string) string {
+	data, _ := json.Marshal(value)
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write(data)
+	return strings.TrimSuffix(logging.Redacted, "]") + ":" + hex.EncodeToString(mac.Sum(nil)[:4]) + "]"
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"wishes/models"
+)
+
+func TestAuditSnapshotRedactsPersonalInfo(t *testing.T) {
+	shippingNumber := "SF123456"
+	record := models.WishRecord{
+		Status:         models.StatusPendingShipment,
+		WishID:         7,
+		DonorName:      "张三",
+		DonorMobile:    "13800138000",
+		DonorAddress:   "北京市朝阳区某某路 1 号",
+		DonorComment:   "加油",
+		ShippingNumber: &shippingNumber,
+		Donor: &models.User{
+			WechatOpenID: "o-openid",
+			Nickname:     "小张",
+			Phone:        "13800138000",
+		},
+	}
+
+	snapshot := auditSnapshot(record, "salt")
+	for _, value := range []string{"张三", "13800138000", "某某路", "加油", "SF123456", "o-openid", "小张"} {
+		if strings.Contains(snapshot, value) {
+			t.Errorf("快照中包含个人信息 %q: %s", value, snapshot)
+		}
+	}
+	for _, value := range []string{`"status":"pending_shipment"`, `"wishId":7`, `"donorMobile":"[REDACTED:`} {
+		if !strings.Contains(snapshot, value) {
+			t.Errorf("快照中缺少 %s: %s", value, snapshot)
+		}
+	}
+}
+
+type auditRecorderFunc func(entry *models.AuditLog) error
+
+func (f auditRecorderFunc) Record(entry *models.AuditLog) error { return f(entry) }
+
+// TestAuditSnapshotShowsChangedFields 同一条日志中未修改的个人信息字段摘要相同，修改过的字段摘要不同，
+// 不同日志中相同的值摘要不同
+func TestAuditSnapshotShowsChangedFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var entries []*models.AuditLog
+	router := gin.New()
+	router.Use(Audit(auditRecorderFunc(func(entry *models.AuditLog) error {
+		entries = append(entries, entry)
+		return nil
+	})))
+	router.PUT("/records/:id/shipping-info", func(c *gin.Context) {
+		record := models.WishRecord{DonorName: "张三", DonorMobile: "13800138000", DonorAddress: "北京市朝阳区某某路 1 号"}
+		SetAuditBefore(c, record)
+		record.DonorAddress = "上海市黄浦区某某路 2 号"
+		SetAuditAfter(c, record)
+	})
+	for range 2 {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/records/1/shipping-info", nil))
+	}
+
+	fields := func(snapshot string) map[string]any {
+		t.Helper()
+		var v map[string]any
+		if err := json.Unmarshal([]byte(snapshot), &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	before, after := fields(entries[0].Before), fields(entries[0].After)
+	for _, key := range []string{"donorName", "donorMobile", "donorAddress"} {
+		if value, _ := before[key].(string); !strings.HasPrefix(value, "[REDACTED:") {
+			t.Fatalf("%s 应替换为带摘要的占位符，实际 %v", key, before[key])
+		}
+	}
+	if before["donorName"] != after["donorName"] || before["donorMobile"] != after["donorMobile"] {
+		t.Errorf("未修改的字段摘要应相同: %v, %v", before, after)
+	}
+	if before["donorAddress"] == after["donorAddress"] {
+		t.Errorf("修改过的字段摘要应不同: %v", after["donorAddress"])
+	}
+	if other := fields(entries[1].Before); other["donorMobile"] == before["donorMobile"] {
+		t.Errorf("不同日志中的摘要不应可以关联: %v", other["donorMobile"])
+	}
+}
@@ -9,14 +9,61 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 
+	"wishes/logging"
 	"wishes/models"
 	"wishes/utils"
 )
 
-var jwtSecret []byte
+var jwtKeys *KeySet
 
-func InitJWTSecret(secret []byte) {
-	jwtSecret = secret
+// InitJWTKeys 设置签发和校验令牌使用的密钥
+func InitJWTKeys(keys *KeySet) {
+	jwtKeys = keys
+}
+
+// PublicJWKS 返回校验令牌使用的公钥集合
+func PublicJWKS() JWKS {
+	return jwtKeys.JWKS()
+}
+
+// UserAccess 小程序用户的当前状态，每次请求时从数据库读取，令牌签发后的变化立即生效
+type UserAccess struct {
+	IsAdmin     bool                    // 是否为志愿者，取消后旧令牌不再拥有志愿者权限
+	Restriction *models.UserRestriction // 当前生效的限制，nil 表示不受限制
+}
+
+// UserAccessChecker 查询小程序用户的当前状态；
+// 用户已注销等不允许继续使用令牌的情况返回错误
+type UserAccessChecker func(userID uint) (*UserAccess, error)
+
+var userAccessChecker UserAccessChecker
+
+// SetUserAccessChecker 设置 JWTAuth 使用的用户状态检查，令牌签发后被封禁或注销的用户也会立即失效
+func SetUserAccessChecker(checker UserAccessChecker) {
+	userAccessChecker = checker
+}
+
+// AdminAccess 管理员账号的当前状态，每次请求时从数据库读取
+type AdminAccess struct {
+	PasswordChangedAt int64 // 最近一次修改或重置密码的时间，早于该时间签发的令牌不再有效
+	TOTPResetAt       int64 // 系统强制两步验证时，被清除两步验证的时间，早于该时间签发的令牌不再有效
+}
+
+// AdminAccessChecker 查询管理员账号的当前状态，管理员不存在时返回错误
+type AdminAccessChecker func(adminID uint) (*AdminAccess, error)
+
+var adminAccessChecker AdminAccessChecker
+
+// SetAdminAccessChecker 设置 JWTAuth 使用的管理员状态检查，修改密码后旧令牌立即失效
+func SetAdminAccessChecker(checker AdminAccessChecker) {
+	adminAccessChecker = checker
+}
+
+// restrictionExemptRoutes 受限制的用户仍可访问的路由。用户依法有权导出个人信息和注销账号，
+// 封禁和只读限制都不能妨碍用户导出数据、申请或撤销注销
+var restrictionExemptRoutes = map[string]bool{
+	"/api/v1/user/me/export":   true,
+	"/api/v1/user/me/deletion": true,
 }
 
 type UserType = string
@@ -24,8 +71,13 @@ type UserType = string
 const (
 	UserTypeUser  UserType = "user"
 	UserTypeAdmin UserType = "admin"
+	// UserTypeAdminMFA 管理员通过密码验证后、完成两步验证前持有的临时令牌，不能访问其他接口
+	UserTypeAdminMFA UserType = "admin_mfa"
 )
 
+// AdminMFATokenTTL 两步验证临时令牌的有效期
+const AdminMFATokenTTL = 5 * time.Minute
+
 type JWTClaims struct {
 	UserID  uint
 	Type    UserType
@@ -33,6 +85,25 @@ type JWTClaims struct {
 	jwt.RegisteredClaims
 }
 
+// Role 根据令牌类型推导角色，小程序用户的 IsAdmin 对应志愿者角色
+func (c *JWTClaims) Role() models.Role {
+	return roleOf(c.Type, c.IsAdmin)
+}
+
+func roleOf(userType UserType, isAdmin bool) models.Role {
+	switch userType {
+	case UserTypeAdmin:
+		return models.RoleAdmin
+	case UserTypeUser:
+		if isAdmin {
+			return models.RoleVolunteer
+		}
+		return models.RoleDonor
+	default:
+		return ""
+	}
+}
+
 func GenerateUserToken(user models.User) (string, error) {
 	claims := JWTClaims{
 		UserID:  user.ID,
@@ -47,9 +118,7 @@ func GenerateUserToken(user models.User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	return token.SignedString(jwtSecret)
+	return jwtKeys.Sign(claims)
 }
 
 func GenerateAdminToken(admin models.Admin) (string, error) {
@@ -66,15 +135,40 @@ func GenerateAdminToken(admin models.Admin) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return jwtKeys.Sign(claims)
+}
 
-	return token.SignedString(jwtSecret)
+// GenerateAdminMFAToken 生成两步验证阶段使用的短期令牌
+func GenerateAdminMFAToken(admin models.Admin) (string, error) {
+	claims := JWTClaims{
+		UserID: admin.ID,
+		Type:   UserTypeAdminMFA,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AdminMFATokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "wishes-api",
+			Subject:   fmt.Sprintf("%d", admin.ID),
+		},
+	}
+
+	return jwtKeys.Sign(claims)
+}
+
+// ParseAdminMFAToken 解析两步验证临时令牌，拒绝其他类型的令牌
+func ParseAdminMFAToken(tokenString string) (*JWTClaims, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != UserTypeAdminMFA {
+		return nil, fmt.Errorf("invalid token type")
+	}
+	return claims, nil
 }
 
 func ParseToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (any, error) {
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, jwtKeys.keyFunc)
 
 	if err != nil {
 		return nil, err
@@ -104,15 +198,88 @@ func JWTAuth() gin.HandlerFunc {
 		}
 
 		claims, err := ParseToken(parts[1])
+		if err == nil && claims.Type != UserTypeUser && claims.Type != UserTypeAdmin {
+			err = fmt.Errorf("invalid token type")
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, "无效的 token"))
 			c.Abort()
 			return
 		}
 
+		// 修改或重置密码、被清除两步验证后，之前签发的管理员令牌全部失效
+		if claims.Type == UserTypeAdmin && adminAccessChecker != nil {
+			access, err := adminAccessChecker(claims.UserID)
+			if err != nil || claims.IssuedAt == nil ||
+				claims.IssuedAt.Unix() < max(access.PasswordChangedAt, access.TOTPResetAt) {
+				c.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, "登录已失效，请重新登录"))
+				c.Abort()
+				return
+			}
+		}
+
+		// 角色以数据库中的当前状态为准，取消志愿者权限后旧令牌立即降为捐赠者
+		isAdmin := claims.IsAdmin
+		if claims.Type == UserTypeUser && userAccessChecker != nil {
+			access, err := userAccessChecker(claims.UserID)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, utils.CreateResponse(nil, "账号不可用，请重新登录"))
+				c.Abort()
+				return
+			}
+			isAdmin = access.IsAdmin
+			if restriction := access.Restriction; restriction != nil && !restrictionExemptRoutes[c.FullPath()] {
+				// 只读限制的用户仍可以浏览，不能提交任何修改
+				if restriction.Level == models.RestrictionBan || c.Request.Method != http.MethodGet {
+					c.JSON(http.StatusForbidden, utils.CreateResponse(RestrictionNotice(restriction), RestrictionMessage(restriction)))
+					c.Abort()
+					return
+				}
+				c.Set("restriction", restriction)
+			}
+		}
+
 		c.Set("userID", claims.UserID)
 		c.Set("userType", claims.Type)
-		c.Set("isAdmin", claims.IsAdmin)
+		c.Set("isAdmin", isAdmin)
+		c.Set("role", roleOf(claims.Type, isAdmin))
+		logging.FromContext(c.Request.Context()).SetUser(claims.UserID, claims.Type)
 		c.Next()
 	}
 }
+
+// OptionalJWTAuth 用于公开接口：没有携带令牌时按未登录处理，携带令牌时与 JWTAuth 相同，令牌无效返回 401
+func OptionalJWTAuth() gin.HandlerFunc {
+	auth := JWTAuth()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
+// RestrictionNoticeResponse 告知被限制的用户限制级别、原因和到期时间
+type RestrictionNoticeResponse struct {
+	Level     models.RestrictionLevel `json:"level"`
+	Reason    string                  `json:"reason"`
+	ExpiresAt int64                   `json:"expiresAt"` // 0 表示永久
+}
+
+// RestrictionNotice 返回给被限制用户的限制信息，不包含操作的管理员
+func RestrictionNotice(restriction *models.UserRestriction) RestrictionNoticeResponse {
+	return RestrictionNoticeResponse{
+		Level:     restriction.Level,
+		Reason:    restriction.Reason,
+		ExpiresAt: restriction.ExpiresAt,
+	}
+}
+
+// RestrictionMessage 被限制用户看到的错误信息
+func RestrictionMessage(restriction *models.UserRestriction) string {
+	if restriction.Level == models.RestrictionBan {
+		return "账号已被封禁：" + restriction.Reason
+	}
+	return "账号已被限制为只能浏览：" + restriction.Reason
+}
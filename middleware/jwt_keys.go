@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWT 密钥目录中的文件以 kid 命名，例如 20261019-3fa2.pem：
+//   - PKCS#8 私钥（RSA 或 Ed25519）可以签发和校验令牌
+//   - PKIX 公钥只用于校验，适合保留已停用密钥直到它签发的令牌全部过期
+//
+// 轮换步骤：
+//  1. 使用 gen-jwt-key 子命令生成新密钥，并分发到所有实例的密钥目录
+//  2. 将 JWT_SIGNING_KID 设为新密钥的 kid（未设置时使用 kid 最大的私钥）并重启
+//  3. 使用 retire-jwt-key 子命令把旧密钥替换为只含公钥的文件
+//  4. 等待最长令牌有效期（7天）后，删除旧密钥文件
+//
+// 旧密钥在第 4 步之前始终可用于校验，轮换期间已登录的用户不受影响。
+
+var ErrNoSigningKey = errors.New("未配置JWT签名密钥")
+
+// minHMACSecretLength HS256 密钥的最短长度，与签名输出等长，避免被离线暴力破解
+const minHMACSecretLength = 32
+
+// JWTKey 一个用于签发或校验令牌的密钥
+type JWTKey struct {
+	KID       string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer // 只有公钥时为 nil
+	PublicKey crypto.PublicKey
+}
+
+// KeySet 当前使用的签名密钥和全部校验密钥
+type KeySet struct {
+	signing *JWTKey
+	keys    map[string]*JWTKey
+	// legacySecret 旧版 HS256 密钥，只用于校验迁移前签发的无 kid 令牌
+	legacySecret []byte
+}
+
+// NewHMACKeySet 使用单个 HS256 密钥签发和校验令牌，仅用于未配置密钥目录的本地开发环境
+func NewHMACKeySet(secret []byte) (*KeySet, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("%w: 请设置 JWT_KEYS_DIR 或 JWT_SECRET", ErrNoSigningKey)
+	}
+	if len(secret) < minHMACSecretLength {
+		return nil, fmt.Errorf("%w: JWT_SECRET 不能少于%d个字节，建议改用 JWT_KEYS_DIR 配置非对称密钥", ErrNoSigningKey, minHMACSecretLength)
+	}
+	return &KeySet{legacySecret: secret}, nil
+}
+
+// LoadKeySet 从目录加载密钥。signingKID 为空时使用 kid 最大的私钥签名。
+// legacySecret 不为空时继续接受旧版 HS256 令牌，便于从对称密钥平滑迁移。
+func LoadKeySet(dir, signingKID string, legacySecret []byte) (*KeySet, error) {
+	// 能通过校验的 HS256 密钥同样可以伪造令牌
+	if len(legacySecret) > 0 && len(legacySecret) < minHMACSecretLength {
+		return nil, fmt.Errorf("JWT_SECRET 不能少于%d个字节，迁移完成后可以不再设置", minHMACSecretLength)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取JWT密钥目录失败: %w", err)
+	}
+
+	set := &KeySet{
+		keys:         make(map[string]*JWTKey),
+		legacySecret: legacySecret,
+	}
+	var privateKIDs []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+		kid := strings.TrimSuffix(entry.Name(), ".pem")
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseJWTKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("解析JWT密钥 %s 失败: %w", entry.Name(), err)
+		}
+		set.keys[kid] = key
+		if key.Private != nil {
+			privateKIDs = append(privateKIDs, kid)
+		}
+	}
+
+	if signingKID == "" {
+		if len(privateKIDs) == 0 {
+			return nil, ErrNoSigningKey
+		}
+		sort.Strings(privateKIDs)
+		signingKID = privateKIDs[len(privateKIDs)-1]
+	}
+	signing, ok := set.keys[signingKID]
+	if !ok || signing.Private == nil {
+		return nil, fmt.Errorf("%w: 找不到 %s 的私钥", ErrNoSigningKey, signingKID)
+	}
+	set.signing = signing
+
+	return set, nil
+}
+
+// ParseJWTKey 解析 PEM 格式的 PKCS#8 私钥或 PKIX 公钥
+func ParseJWTKey(kid string, data []byte) (*JWTKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("不是PEM格式")
+	}
+
+	key := &JWTKey{KID: kid}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("不支持的私钥类型")
+		}
+		key.Private = signer
+		key.PublicKey = signer.Public()
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.PublicKey = parsed
+	default:
+		return nil, fmt.Errorf("不支持的PEM类型 %s", block.Type)
+	}
+
+	switch pub := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA密钥至少需要2048位")
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("只支持RSA和Ed25519密钥")
+	}
+	return key, nil
+}
+
+// SigningKID 返回当前签名密钥的 kid，只使用 HS256 密钥时为空
+func (s *KeySet) SigningKID() string {
+	if s.signing == nil {
+		return ""
+	}
+	return s.signing.KID
+}
+
+// Sign 使用当前签名密钥签发令牌，并在头部写入 kid
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if s.signing == nil {
+		if len(s.legacySecret) == 0 {
+			return "", ErrNoSigningKey
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.legacySecret)
+	}
+
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.KID
+	return token.SignedString(s.signing.Private)
+}
+
+// keyFunc 按 kid 查找校验密钥，并要求令牌的算法与密钥类型一致，防止算法混淆攻击
+func (s *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if len(s.legacySecret) == 0 || token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("令牌缺少kid")
+		}
+		return s.legacySecret, nil
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("未知的kid %s", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("kid %s 不支持算法 %s", kid, token.Method.Alg())
+	}
+	return key.PublicKey, nil
+}
+
+// JWK JSON Web Key 中的公钥字段
+type JWK struct {
+	KTY string `json:"kty"`
+	KID string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA 模数
+	E   string `json:"e,omitempty"`   // RSA 指数
+	Crv string `json:"crv,omitempty"` // OKP 曲线
+	X   string `json:"x,omitempty"`   // OKP 公钥
+}
+
+// JWKS JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 返回全部校验公钥，按 kid 排序
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys {
+		jwk := JWK{KID: key.KID, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KTY = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KTY = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].KID < jwks.Keys[j].KID })
+	return jwks
+}
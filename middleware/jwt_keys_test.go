@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNewHMACKeySetRejectsWeakSecrets(t *testing.T) {
+	for _, secret := range [][]byte{nil, []byte("secret"), bytes.Repeat([]byte("x"), minHMACSecretLength-1)} {
+		if _, err := NewHMACKeySet(secret); !errors.Is(err, ErrNoSigningKey) {
+			t.Errorf("%d 字节的密钥应被拒绝，实际 %v", len(secret), err)
+		}
+	}
+	if _, err := NewHMACKeySet(bytes.Repeat([]byte("x"), minHMACSecretLength)); err != nil {
+		t.Errorf("%d 字节的密钥应可用: %v", minHMACSecretLength, err)
+	}
+}
+
+func TestLoadKeySetRejectsWeakLegacySecret(t *testing.T) {
+	if _, err := LoadKeySet(t.TempDir(), "", []byte("short")); err == nil {
+		t.Error("过短的旧版密钥应被拒绝")
+	}
+}
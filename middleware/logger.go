@@ -1,37 +1,71 @@
 package middleware
 
 import (
-	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"wishes/logging"
+	"wishes/utils"
 )
 
+// Logger 请求结束后输出一行访问日志。路径中的令牌参数和查询参数中的敏感值会被脱敏，
+// 请求ID、路由和用户由 RequestID 和 JWTAuth 放入 context 后自动带上
 func Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		startTime := time.Now()
+		start := time.Now()
 
 		c.Next()
 
-		endTime := time.Now()
-
-		latencyTime := endTime.Sub(startTime)
-
-		reqMethod := c.Request.Method
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
 
-		reqUri := c.Request.RequestURI
-
-		statusCode := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", redactPath(c)),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start))/float64(time.Millisecond)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if query := logging.RedactQuery(c.Request.URL.RawQuery); query != "" {
+			attrs = append(attrs, slog.String("query", query))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "HTTP请求", attrs...)
+	}
+}
 
-		clientIP := c.ClientIP()
+// redactPath 把路径中敏感的路由参数（例如直传地址中的令牌）替换为 [REDACTED]
+func redactPath(c *gin.Context) string {
+	path := c.Request.URL.Path
+	for _, param := range c.Params {
+		if param.Value != "" && logging.IsSensitive(param.Key) {
+			path = strings.Replace(path, param.Value, logging.Redacted, 1)
+		}
+	}
+	return path
+}
 
-		fmt.Printf("[GIN] %v | %3d | %13v | %15s | %s | %s\n",
-			endTime.Format("2006/01/02 - 15:04:05"),
-			statusCode,
-			latencyTime,
-			clientIP,
-			reqMethod,
-			reqUri,
+// Recovery 捕获处理请求时的 panic，记录堆栈后返回 500
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "处理请求时发生panic",
+			slog.Any("panic", err),
+			slog.String("stack", string(debug.Stack())),
 		)
-	}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, utils.CreateResponse(nil, "服务器错误"))
+	})
 }
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wishes/models"
+	"wishes/utils"
+)
+
+type Permission string
+
+const (
+	PermWishWrite        Permission = "wish:write"        // 创建、修改、删除、批量导入心愿
+	PermWishClaim        Permission = "wish:claim"        // 点亮心愿
+	PermWishPhoto        Permission = "wish:photo"        // 查看心愿照片原图，其他人只能看到公开的缩略图
+	PermRecordRead       Permission = "record:read"       // 查看自己的认领记录
+	PermRecordReadAll    Permission = "record:read_all"   // 查看所有认领记录
+	PermRecordShip       Permission = "record:ship"       // 为自己的认领记录填写寄送单号
+	PermRecordTransition Permission = "record:transition" // 任意变更认领记录状态
+	PermRecordEditOwn    Permission = "record:edit_own"   // 修改自己认领记录的收货信息
+	PermRecordEdit       Permission = "record:edit"       // 修改任意认领记录的收货信息
+	PermUserManage       Permission = "user:manage"       // 查看用户、授予或取消管理权限
+	PermUserProfile      Permission = "user:profile"      // 查看和修改自己的小程序资料
+	PermAdminManage      Permission = "admin:manage"      // 管理后台管理员及邀请
+	PermAdminAccount     Permission = "admin:account"     // 管理自己的后台账号
+	PermUploadImage      Permission = "upload:image"      // 上传图片
+	PermAuditRead        Permission = "audit:read"        // 查询和导出审计日志
+)
+
+var rolePermissions = map[models.Role][]Permission{
+	models.RoleDonor: {
+		PermUserProfile,
+		PermWishClaim,
+		PermRecordRead,
+		PermRecordShip,
+		PermRecordEditOwn,
+		PermUploadImage,
+	},
+	models.RoleVolunteer: {
+		PermUserProfile,
+		PermWishClaim,
+		PermRecordRead,
+		PermRecordReadAll,
+		PermRecordShip,
+		PermRecordTransition,
+		PermWishPhoto,
+		PermRecordEditOwn,
+		PermUploadImage,
+	},
+	models.RoleAdmin: {
+		PermWishWrite,
+		PermWishPhoto,
+		PermRecordReadAll,
+		PermRecordTransition,
+		PermRecordEdit,
+		PermUserManage,
+		PermAdminManage,
+		PermAdminAccount,
+		PermUploadImage,
+		PermAuditRead,
+	},
+}
+
+// RoleHasPermission 判断角色是否拥有指定权限
+func RoleHasPermission(role models.Role, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentRole 返回 JWTAuth 写入上下文的角色
+func CurrentRole(c *gin.Context) models.Role {
+	role, _ := c.Get("role")
+	r, _ := role.(models.Role)
+	return r
+}
+
+// HasPermission 判断当前请求的用户是否拥有指定权限
+func HasPermission(c *gin.Context, perm Permission) bool {
+	return RoleHasPermission(CurrentRole(c), perm)
+}
+
+// RequirePermission 要求当前用户拥有全部指定权限，需放在 JWTAuth 之后
+func RequirePermission(perms ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, perm := range perms {
+			if !HasPermission(c, perm) {
+				c.JSON(http.StatusForbidden, utils.CreateResponse(nil, "无权执行此操作"))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireAnyPermission 要求当前用户至少拥有其中一个权限，需放在 JWTAuth 之后，
+// 适用于“所有者或管理员”这类还需在处理函数中进一步检查的场景
+func RequireAnyPermission(perms ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, perm := range perms {
+			if HasPermission(c, perm) {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, utils.CreateResponse(nil, "无权执行此操作"))
+		c.Abort()
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"wishes/logging"
+)
+
+// RequestIDHeader 传递请求ID的请求头，响应中也会带上
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 客户端或网关传入的请求ID的最大长度
+const maxRequestIDLength = 128
+
+// RequestID 沿用请求头中的请求ID，没有或格式不合法时生成新的ID，
+// 并放入请求的 context，请求处理期间输出的日志都会带上它
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Set("requestID", id)
+
+		ctx, _ := logging.WithRequest(c.Request.Context(), id, c.FullPath())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// validRequestID 只接受可以安全写入日志和响应头的字符
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':', r == '/', r == '+', r == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
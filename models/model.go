@@ -1,5 +1,11 @@
 package models
 
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
 // @Description 基础模型结构，包含ID、创建时间、更新时间和删除时间
 type Model struct {
 	ID        uint  `gorm:"primaryKey" json:"id"`
@@ -16,6 +22,29 @@ type User struct {
 	AvatarURL     string `json:"avatarUrl,omitempty" gorm:"column:avatar_url"`
 	Nickname      string `json:"nickname,omitempty"`
 	IsAdmin       bool   `json:"isAdmin" gorm:"default:false"`
+
+	// 最近一次登录获得的 session_key，用于解密 getPhoneNumber 返回的加密数据
+	WechatSessionKey string `json:"-" gorm:"column:wechat_session_key"`
+	// 经微信验证的手机号，认领心愿时作为默认联系电话
+	Phone           string `json:"phone,omitempty"`
+	PhoneVerifiedAt int64  `json:"phoneVerifiedAt,omitempty"`
+}
+
+// @Description 角色，决定可用的权限集合
+type Role string
+
+const (
+	RoleDonor     Role = "donor"     // 普通小程序用户
+	RoleVolunteer Role = "volunteer" // 被授予管理权限的小程序用户
+	RoleAdmin     Role = "admin"     // 后台系统管理员
+)
+
+// Role 小程序用户的角色，由 IsAdmin 决定
+func (u *User) Role() Role {
+	if u.IsAdmin {
+		return RoleVolunteer
+	}
+	return RoleDonor
 }
 
 // @Description 系统管理员信息
@@ -23,6 +52,111 @@ type Admin struct {
 	Model
 	Username string `json:"username" gorm:"uniqueIndex"`
 	Password string `json:"password,omitempty" gorm:"not null"`
+
+	Nickname     string `json:"nickname,omitempty"`
+	AvatarURL    string `json:"avatarUrl,omitempty" gorm:"column:avatar_url"`
+	Department   string `json:"department,omitempty"`
+	ContactPhone string `json:"contactPhone,omitempty"`
+
+	PasswordChangedAt int64 `json:"passwordChangedAt"`
+	LockoutResetAt    int64 `json:"-"` // 在此之前的登录失败不再计入账号锁定
+
+	TOTPEnabled       bool   `json:"totpEnabled" gorm:"column:totp_enabled;default:false"`
+	TOTPSecret        string `json:"-" gorm:"column:totp_secret"`
+	TOTPPendingSecret string `json:"-" gorm:"column:totp_pending_secret"` // 绑定中尚未确认的密钥
+	TOTPLastUsedStep  int64  `json:"-" gorm:"column:totp_last_used_step"` // 最近一次成功使用的时间步，用于拒绝重放
+	TOTPResetAt       int64  `json:"-" gorm:"column:totp_reset_at"`       // 被其他管理员清除两步验证的时间，早于该时间签发的令牌不再有效
+}
+
+// @Description 管理员两步验证恢复码，只保存哈希值
+type AdminRecoveryCode struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	CreatedAt int64  `json:"createdAt" gorm:"autoCreateTime"`
+	AdminID   uint   `json:"adminId" gorm:"index"`
+	CodeHash  string `json:"-" gorm:"not null"`
+	UsedAt    *int64 `json:"usedAt,omitempty"`
+}
+
+// Role 后台管理员的角色
+func (a *Admin) Role() Role {
+	return RoleAdmin
+}
+
+// @Description 管理员注册邀请，令牌只保存哈希值，明文仅在创建时返回一次
+type AdminInvitation struct {
+	Model
+	TokenHash   string `json:"-" gorm:"uniqueIndex;not null"`
+	Note        string `json:"note"`
+	CreatedByID uint   `json:"createdById" gorm:"index"`
+	ExpiresAt   int64  `json:"expiresAt"`
+	UsedAt      *int64 `json:"usedAt,omitempty"`
+	UsedByID    *uint  `json:"usedById,omitempty"`
+	RevokedAt   *int64 `json:"revokedAt,omitempty"`
+	RevokedByID *uint  `json:"revokedById,omitempty"`
+}
+
+// @Description 管理员邀请状态
+type AdminInvitationStatus string
+
+const (
+	InvitationPending AdminInvitationStatus = "pending"
+	InvitationUsed    AdminInvitationStatus = "used"
+	InvitationRevoked AdminInvitationStatus = "revoked"
+	InvitationExpired AdminInvitationStatus = "expired"
+)
+
+// Status 根据使用、撤销和过期时间计算邀请当前的状态
+func (i *AdminInvitation) Status(now int64) AdminInvitationStatus {
+	switch {
+	case i.UsedAt != nil:
+		return InvitationUsed
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case i.ExpiresAt <= now:
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
+
+// @Description 管理员登录尝试记录
+type LoginAttempt struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	CreatedAt int64  `json:"createdAt" gorm:"autoCreateTime;index"`
+	Username  string `json:"username" gorm:"index"`
+	AdminID   *uint  `json:"adminId,omitempty"`
+	IP        string `json:"ip" gorm:"index"`
+	UserAgent string `json:"userAgent"`
+	Success   bool   `json:"success"`
+	Reason    string `json:"reason,omitempty"` // invalid_credentials, invalid_totp, locked
+}
+
+// @Description 审计日志，只允许追加，不允许修改和删除
+type AuditLog struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	CreatedAt  int64  `json:"createdAt" gorm:"autoCreateTime;index"`
+	ActorID    uint   `json:"actorId" gorm:"index:idx_audit_actor"`
+	ActorType  string `json:"actorType" gorm:"index:idx_audit_actor"`
+	Action     string `json:"action" gorm:"index"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	TargetType string `json:"targetType,omitempty" gorm:"index:idx_audit_target"`
+	TargetID   string `json:"targetId,omitempty" gorm:"index:idx_audit_target"`
+	Before     string `json:"before,omitempty"` // 变更前快照（JSON）
+	After      string `json:"after,omitempty"`  // 变更后快照（JSON）
+	StatusCode int    `json:"statusCode"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"userAgent"`
+}
+
+var ErrAuditLogImmutable = errors.New("审计日志不允许修改或删除")
+
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
 }
 
 // @Description 用户性别类型
@@ -103,3 +237,156 @@ type WishRecord struct {
 
 	CancellationTime *int64 `json:"cancellationTime,omitempty"` // 取消时间
 }
+
+// WechatAccessToken 持久化的微信接口调用凭证，服务重启后继续使用
+type WechatAccessToken struct {
+	AppID     string `gorm:"primaryKey;column:app_id"`
+	Token     string
+	ExpiresAt int64
+	UpdatedAt int64 `gorm:"autoUpdateTime"`
+}
+
+// @Description 用户对订阅消息模板的授权。一次性订阅消息每授权一次只能发送一条，
+// @Description Remaining 记录还可以发送的条数
+type SubscriptionConsent struct {
+	Model
+	UserID         uint   `json:"userId" gorm:"uniqueIndex:idx_consent_user_template"`
+	TemplateID     string `json:"templateId" gorm:"uniqueIndex:idx_consent_user_template"`
+	Remaining      int    `json:"remaining"`
+	LastAcceptedAt int64  `json:"lastAcceptedAt,omitempty"`
+	LastRejectedAt int64  `json:"lastRejectedAt,omitempty"`
+}
+
+// @Description 订阅消息投递状态
+type NotificationState string
+
+const (
+	NotificationPending NotificationState = "pending" // 等待发送或等待重试
+	NotificationSent    NotificationState = "sent"
+	NotificationFailed  NotificationState = "failed"  // 重试次数用完或不可重试的错误
+	NotificationSkipped NotificationState = "skipped" // 用户没有剩余授权，未发送
+)
+
+// @Description 订阅消息投递记录
+type NotificationDelivery struct {
+	Model
+	UserID        uint              `json:"userId" gorm:"index"`
+	RecordID      uint              `json:"recordId" gorm:"index"`
+	Event         WishRecordStatus  `json:"event"` // 触发通知的记录状态
+	TemplateID    string            `json:"templateId"`
+	Payload       string            `json:"payload"` // 发送的订阅消息，JSON 格式
+	State         NotificationState `json:"state" gorm:"index"`
+	Attempts      int               `json:"attempts"`
+	LastError     string            `json:"lastError,omitempty"`
+	NextAttemptAt int64             `json:"nextAttemptAt,omitempty" gorm:"index"`
+	SentAt        *int64            `json:"sentAt,omitempty"`
+}
+
+// @Description 注销申请状态
+type AccountDeletionStatus string
+
+const (
+	DeletionPending   AccountDeletionStatus = "pending"   // 冷静期内，可以撤销
+	DeletionCancelled AccountDeletionStatus = "cancelled" // 用户已撤销
+	DeletionCompleted AccountDeletionStatus = "completed" // 个人信息已匿名化
+)
+
+// @Description 小程序用户的注销申请，冷静期结束后匿名化个人信息
+type AccountDeletionRequest struct {
+	Model
+	UserID      uint                  `json:"userId" gorm:"index"`
+	Status      AccountDeletionStatus `json:"status" gorm:"index"`
+	Reason      string                `json:"reason,omitempty"`
+	ScheduledAt int64                 `json:"scheduledAt" gorm:"index"` // 冷静期结束时间
+	CancelledAt *int64                `json:"cancelledAt,omitempty"`
+	CompletedAt *int64                `json:"completedAt,omitempty"`
+}
+
+// @Description 用户限制级别
+type RestrictionLevel string
+
+const (
+	RestrictionBan        RestrictionLevel = "ban"         // 封禁，不能访问任何需要登录的接口
+	RestrictionBrowseOnly RestrictionLevel = "browse_only" // 只能浏览，不能认领心愿或提交修改
+)
+
+// @Description 管理员对小程序用户施加的限制，解除后保留记录
+type UserRestriction struct {
+	Model
+	UserID    uint             `json:"userId" gorm:"index"`
+	Level     RestrictionLevel `json:"level"`
+	Reason    string           `json:"reason"`
+	ExpiresAt int64            `json:"expiresAt"` // 到期时间，0 表示永久
+	CreatedBy uint             `json:"createdBy"` // 施加限制的管理员ID
+	LiftedAt  *int64           `json:"liftedAt,omitempty"`
+	LiftedBy  *uint            `json:"liftedBy,omitempty"` // 提前解除限制的管理员ID
+}
+
+// @Description 上传凭证状态
+type UploadTicketStatus string
+
+const (
+	UploadTicketPending   UploadTicketStatus = "pending"   // 等待客户端上传
+	UploadTicketCompleted UploadTicketStatus = "completed" // 已确认文件存在且符合约束
+	UploadTicketRejected  UploadTicketStatus = "rejected"  // 上传的文件不符合约束，已删除
+)
+
+// @Description 客户端直传文件的上传凭证，约束了文件路径、类型和大小
+type UploadTicket struct {
+	Model
+	UserID      uint               `json:"userId" gorm:"index"`
+	UserType    string             `json:"userType"` // user 或 admin
+	Purpose     string             `json:"purpose"`  // 上传用途
+	Key         string             `json:"key" gorm:"uniqueIndex"`
+	ContentType string             `json:"contentType"`
+	Size        int64              `json:"size"`
+	TokenHash   string             `json:"-" gorm:"index"` // 通过服务中转上传时使用的令牌摘要
+	Status      UploadTicketStatus `json:"status" gorm:"index"`
+	ExpiresAt   int64              `json:"expiresAt"`
+	CompletedAt *int64             `json:"completedAt,omitempty"`
+	// DuplicateOf 内容与同一用途下已有的文件相同时为该文件的 key，直传的副本已删除
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+	// ProcessedKey 重新编码后原图的 key，直传的文件已删除
+	ProcessedKey string `json:"processedKey,omitempty"`
+}
+
+// ResultKey 确认上传后客户端应使用的文件
+func (t *UploadTicket) ResultKey() string {
+	if t.DuplicateOf != "" {
+		return t.DuplicateOf
+	}
+	if t.ProcessedKey != "" {
+		return t.ProcessedKey
+	}
+	return t.Key
+}
+
+// @Description 已上传到存储的文件。同一张图片的原图、展示图和缩略图共用 GroupKey，一起保留或删除
+type Upload struct {
+	Model
+	Key          string `json:"key" gorm:"uniqueIndex"`
+	GroupKey     string `json:"groupKey" gorm:"index"` // 原图的 key
+	UploaderID   uint   `json:"uploaderId" gorm:"index"`
+	UploaderType string `json:"uploaderType"` // user 或 admin
+	Purpose      string `json:"purpose" gorm:"index"`
+	Variant      string `json:"variant"` // original、display 或 thumbnail
+	ContentType  string `json:"contentType"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	// SHA256 上传内容的摘要，同一用途下内容相同的图片只保存一份
+	SHA256 string `json:"sha256" gorm:"column:sha256;index"`
+	// RefCount 引用该组文件的数据条数，为 0 时才可以删除
+	RefCount int `json:"refCount"`
+	// OrphanedAt 不再被任何数据引用的时间，0 表示正在被引用。超过保留期后由清理任务删除
+	OrphanedAt int64 `json:"orphanedAt" gorm:"index"`
+}
+
+// @Description 数据对上传文件的引用，例如心愿照片、认领记录的签收照片和头像
+type UploadReference struct {
+	Model
+	GroupKey string `json:"groupKey" gorm:"uniqueIndex:idx_upload_reference"`
+	RefType  string `json:"refType" gorm:"uniqueIndex:idx_upload_reference;index:idx_upload_reference_owner"` // wish、record、user 或 admin
+	RefID    uint   `json:"refId" gorm:"uniqueIndex:idx_upload_reference;index:idx_upload_reference_owner"`
+	Field    string `json:"field" gorm:"uniqueIndex:idx_upload_reference"` // 引用所在的字段
+}
@@ -1,34 +1,77 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	"wishes/controllers"
+	"wishes/metrics"
 	"wishes/middleware"
+	"wishes/storage"
 )
 
 type SetupRouterOptions struct {
-	UploadController *controllers.UploadController
-	AuthController   *controllers.AuthController
-	WishController   *controllers.WishController
-	RecordController *controllers.RecordController
-	UserController   *controllers.UserController
+	UploadController       *controllers.UploadController
+	AuthController         *controllers.AuthController
+	WishController         *controllers.WishController
+	RecordController       *controllers.RecordController
+	UserController         *controllers.UserController
+	AdminController        *controllers.AdminController
+	AuditController        *controllers.AuditController
+	NotificationController *controllers.NotificationController
+	AccountController      *controllers.AccountController
+	RestrictionController  *controllers.RestrictionController
+	HealthController       *controllers.HealthController
+
+	// LocalStorage 使用本地存储时不为空，由服务自身提供上传文件的访问
+	LocalStorage *storage.LocalStorage
+
+	// AuditRecorder 用于记录所有写操作的审计日志
+	AuditRecorder middleware.AuditRecorder
+
+	// MetricsToken 抓取 /metrics 需要携带的令牌，为空时不校验
+	MetricsToken string
 }
 
 func SetupRouter(options SetupRouterOptions) *gin.Engine {
-	r := gin.Default()
+	r := gin.New()
+
+	// 请求ID、访问日志和 panic 恢复，日志统一通过 slog 输出
+	r.Use(middleware.RequestID(), middleware.Logger(), middleware.Recovery())
+	// Prometheus 指标
+	r.Use(middleware.Metrics())
+	r.GET("/metrics", middleware.MetricsAuth(options.MetricsToken), gin.WrapH(metrics.Handler()))
 
-	// Logger
-	r.Use(middleware.Logger())
+	// 每个受保护的路由都需声明所需权限，角色与权限的对应关系见 middleware.rolePermissions
+	can := middleware.RequirePermission
+	canAny := middleware.RequireAnyPermission
 
 	// Swagger UI
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// 本地存储的上传文件，不列出目录，私有文件需要签名地址
+	if options.LocalStorage != nil {
+		urlPath := options.LocalStorage.URLPath()
+		files := gin.WrapH(http.StripPrefix(urlPath, options.LocalStorage))
+		r.GET(urlPath+"/*key", files)
+		r.HEAD(urlPath+"/*key", files)
+	}
+
+	// 存活、就绪检查和构建信息，供部署工具探测
+	r.GET("/healthz", options.HealthController.Healthz)
+	r.GET("/readyz", options.HealthController.Readyz)
+	r.GET("/version", options.HealthController.Version)
+
+	// 校验令牌使用的公钥
+	r.GET("/.well-known/jwks.json", options.AuthController.JWKS)
+
 	// API 路由
 	api := r.Group("/api")
 	v1 := api.Group("/v1")
+	v1.Use(middleware.Audit(options.AuditRecorder))
 	{
 		user := v1.Group("/user")
 		{
@@ -37,7 +80,16 @@ func SetupRouter(options SetupRouterOptions) *gin.Engine {
 			userProtected := user.Group("/")
 			userProtected.Use(middleware.JWTAuth())
 			{
-				userProtected.GET("/records", options.RecordController.GetWishRecords)
+				userProtected.GET("/me", can(middleware.PermUserProfile), options.UserController.GetMe)
+				userProtected.PUT("/me", can(middleware.PermUserProfile), options.UserController.UpdateMe)
+				userProtected.GET("/me/export", can(middleware.PermUserProfile), options.AccountController.ExportMyData)
+				userProtected.GET("/me/deletion", can(middleware.PermUserProfile), options.AccountController.GetMyDeletion)
+				userProtected.POST("/me/deletion", can(middleware.PermUserProfile), options.AccountController.RequestDeletion)
+				userProtected.DELETE("/me/deletion", can(middleware.PermUserProfile), options.AccountController.CancelDeletion)
+				userProtected.POST("/phone", can(middleware.PermUserProfile), options.AuthController.BindWechatPhone)
+				userProtected.GET("/subscriptions", can(middleware.PermUserProfile), options.NotificationController.GetSubscriptions)
+				userProtected.POST("/subscriptions", can(middleware.PermUserProfile), options.NotificationController.UpdateSubscriptions)
+				userProtected.GET("/records", can(middleware.PermRecordRead), options.RecordController.GetWishRecords)
 			}
 		}
 
@@ -45,35 +97,66 @@ func SetupRouter(options SetupRouterOptions) *gin.Engine {
 		{
 			admin.POST("/register", options.AuthController.AdminRegister)
 			admin.POST("/login", options.AuthController.AdminLogin)
+			admin.POST("/login/totp", options.AuthController.AdminLoginTOTP)
+			admin.POST("/login/totp/setup", options.AuthController.AdminLoginTOTPSetup)
 
 			adminProtected := admin.Group("/")
 			adminProtected.Use(middleware.JWTAuth())
 			{
-				adminProtected.GET("/records", options.RecordController.GetAllRecords)
+				adminProtected.GET("/records", can(middleware.PermRecordReadAll), options.RecordController.GetAllRecords)
+
+				adminProtected.POST("/invitations", can(middleware.PermAdminManage), options.AdminController.CreateInvitation)
+				adminProtected.GET("/invitations", can(middleware.PermAdminManage), options.AdminController.GetInvitations)
+				adminProtected.DELETE("/invitations/:id", can(middleware.PermAdminManage), options.AdminController.RevokeInvitation)
+
+				adminProtected.GET("/me", can(middleware.PermAdminAccount), options.AdminController.GetMe)
+				adminProtected.PUT("/me", can(middleware.PermAdminAccount), options.AdminController.UpdateMe)
+				adminProtected.PUT("/me/password", can(middleware.PermAdminAccount), options.AdminController.ChangePassword)
+				adminProtected.POST("/me/totp/setup", can(middleware.PermAdminAccount), options.AdminController.SetupTOTP)
+				adminProtected.POST("/me/totp/enable", can(middleware.PermAdminAccount), options.AdminController.EnableTOTP)
+				adminProtected.POST("/me/totp/disable", can(middleware.PermAdminAccount), options.AdminController.DisableTOTP)
+				adminProtected.POST("/me/totp/recovery-codes", can(middleware.PermAdminAccount), options.AdminController.RegenerateRecoveryCodes)
+				adminProtected.GET("/admins", can(middleware.PermAdminManage), options.AdminController.GetAdmins)
+				adminProtected.PUT("/admins/:id/password", can(middleware.PermAdminManage), options.AdminController.ResetPassword)
+				adminProtected.DELETE("/admins/:id/totp", can(middleware.PermAdminManage), options.AdminController.ResetTOTP)
+				adminProtected.GET("/login-attempts", can(middleware.PermAdminManage), options.AdminController.GetLoginAttempts)
+
+				adminProtected.GET("/audit-logs", can(middleware.PermAuditRead), options.AuditController.GetAuditLogs)
+				adminProtected.GET("/audit-logs/export", can(middleware.PermAuditRead), options.AuditController.ExportAuditLogs)
+				adminProtected.GET("/deletion-requests", can(middleware.PermUserManage), options.AccountController.GetDeletionRequests)
+				adminProtected.GET("/notifications", can(middleware.PermAuditRead), options.NotificationController.GetNotificationDeliveries)
+				adminProtected.POST("/uploads/gc", can(middleware.PermAdminManage), options.UploadController.CollectGarbage)
 			}
 		}
 
-		v1.GET("/wishes", options.WishController.GetWishes)
+		v1.GET("/wishes", middleware.OptionalJWTAuth(), options.WishController.GetWishes)
+		// 令牌即上传授权，只在存储后端不支持预签名时使用
+		v1.PUT("/upload/direct/:token", options.UploadController.DirectUpload)
 
 		protected := v1.Group("/")
 		protected.Use(middleware.JWTAuth())
 		{
-			protected.POST("/wishes", options.WishController.CreateWish)
-			protected.POST("/wishes/batch", options.WishController.BatchCreateWishes)
-			protected.DELETE("/wishes/:id", options.WishController.DeleteWish)
-			protected.PUT("/wishes/:id", options.WishController.UpdateWish)
-			protected.PUT("/wishes/:id/donor", options.WishController.ClaimWish)
-
-			protected.GET("/records", options.RecordController.GetAllRecords)
-			protected.PUT("/records/:id/status", options.RecordController.UpdateRecordStatus)
-			protected.PUT("/records/:id/shipping-info", options.RecordController.UpdateShippingInfo)
-
-			protected.GET("/users/admin", options.UserController.GetAdminUsers)
-			protected.GET("/users/regular", options.UserController.GetNonAdminUsers)
-			protected.PUT("/users/:id/admin", options.UserController.UpdateUserAdmin)
+			protected.POST("/wishes", can(middleware.PermWishWrite), options.WishController.CreateWish)
+			protected.POST("/wishes/batch", can(middleware.PermWishWrite), options.WishController.BatchCreateWishes)
+			protected.DELETE("/wishes/:id", can(middleware.PermWishWrite), options.WishController.DeleteWish)
+			protected.PUT("/wishes/:id", can(middleware.PermWishWrite), options.WishController.UpdateWish)
+			protected.PUT("/wishes/:id/donor", can(middleware.PermWishClaim), options.WishController.ClaimWish)
+
+			protected.GET("/records", can(middleware.PermRecordReadAll), options.RecordController.GetAllRecords)
+			protected.PUT("/records/:id/status", canAny(middleware.PermRecordTransition, middleware.PermRecordShip), options.RecordController.UpdateRecordStatus)
+			protected.PUT("/records/:id/shipping-info", canAny(middleware.PermRecordEdit, middleware.PermRecordEditOwn), options.RecordController.UpdateShippingInfo)
+
+			protected.GET("/users/admin", can(middleware.PermUserManage), options.UserController.GetAdminUsers)
+			protected.GET("/users/regular", can(middleware.PermUserManage), options.UserController.GetNonAdminUsers)
+			protected.PUT("/users/:id/admin", can(middleware.PermUserManage), options.UserController.UpdateUserAdmin)
+			protected.GET("/users/:id/restrictions", can(middleware.PermUserManage), options.RestrictionController.GetRestrictions)
+			protected.POST("/users/:id/restrictions", can(middleware.PermUserManage), options.RestrictionController.RestrictUser)
+			protected.DELETE("/users/:id/restrictions", can(middleware.PermUserManage), options.RestrictionController.LiftRestriction)
 
 			// 文件上传路由
-			protected.POST("/upload/image", options.UploadController.UploadImage)
+			protected.POST("/upload/image", can(middleware.PermUploadImage), options.UploadController.UploadImage)
+			protected.POST("/upload/tickets", can(middleware.PermUploadImage), options.UploadController.CreateUploadTicket)
+			protected.POST("/upload/tickets/:id/complete", can(middleware.PermUploadImage), options.UploadController.CompleteUploadTicket)
 		}
 	}
 
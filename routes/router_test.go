@@ -0,0 +1,630 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"wishes/config"
+	"wishes/controllers"
+	"wishes/imaging"
+	"wishes/middleware"
+	"wishes/models"
+	"wishes/services"
+	"wishes/storage"
+	"wishes/wechat/wechattest"
+)
+
+// testEnv 使用临时数据库和内存存储的完整路由
+type testEnv struct {
+	router *gin.Engine
+	db     *gorm.DB
+	store  *storage.MemoryStorage
+	wechat *wechattest.Server
+	tokens map[models.Role]string
+	users  map[models.Role]uint
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.LoadConfig()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	db, err := config.InitDB(cfg, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	keys, err := middleware.NewHMACKeySet(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	middleware.InitJWTKeys(keys)
+
+	store := storage.NewMemoryStorage("")
+	fake := wechattest.NewServer("", "")
+	wechatServer := fake.Start()
+	t.Cleanup(wechatServer.Close)
+	wechatClient := fake.Client(wechatServer.URL)
+
+	wechatService := services.NewWechatService(db, wechatClient)
+	uploadTracker := services.NewUploadTracker(db, store, cfg)
+	wishService := services.NewWishService(db, uploadTracker)
+	recordService := services.NewRecordService(db, uploadTracker)
+	userService := services.NewUserService(db, uploadTracker)
+	adminService := services.NewAdminService(db, uploadTracker, cfg)
+	auditService := services.NewAuditService(db)
+	notificationService := services.NewNotificationService(db, wechatService, cfg)
+	accountService := services.NewAccountService(db, uploadTracker, cfg)
+	restrictionService := services.NewRestrictionService(db)
+	storageService := services.NewStorageService(db, store, uploadTracker, cfg)
+	healthService := services.NewHealthService(db, store, services.BuildInfo{})
+
+	middleware.SetUserAccessChecker(restrictionService.CheckUserAccess)
+	middleware.SetAdminAccessChecker(adminService.CheckAdminAccess)
+
+	router := SetupRouter(SetupRouterOptions{
+		AuthController:         controllers.NewAuthController(db, wechatService, adminService),
+		WishController:         controllers.NewWishController(wishService, recordService, userService, restrictionService, storageService),
+		RecordController:       controllers.NewRecordController(recordService, notificationService, storageService),
+		UserController:         controllers.NewUserController(userService),
+		AdminController:        controllers.NewAdminController(adminService),
+		AuditController:        controllers.NewAuditController(auditService),
+		NotificationController: controllers.NewNotificationController(notificationService),
+		AccountController:      controllers.NewAccountController(accountService),
+		RestrictionController:  controllers.NewRestrictionController(restrictionService),
+		UploadController:       controllers.NewUploadController(storageService, uploadTracker),
+		HealthController:       controllers.NewHealthController(healthService),
+		AuditRecorder:          auditService,
+	})
+
+	env := &testEnv{
+		router: router,
+		db:     db,
+		store:  store,
+		wechat: fake,
+		tokens: map[models.Role]string{},
+		users:  map[models.Role]uint{},
+	}
+
+	for _, role := range []models.Role{models.RoleDonor, models.RoleVolunteer} {
+		user := models.User{WechatOpenID: "openid-" + string(role), IsAdmin: role == models.RoleVolunteer}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		token, err := middleware.GenerateUserToken(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.tokens[role] = token
+		env.users[role] = user.ID
+	}
+
+	admin, err := adminService.CreateAdmin("root", "Passw0rd!Passw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := middleware.GenerateAdminToken(*admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.tokens[models.RoleAdmin] = token
+	env.users[models.RoleAdmin] = admin.ID
+
+	return env
+}
+
+// do 以指定角色发送请求，role 为空时不携带令牌
+func (e *testEnv) do(role models.Role, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if role != "" {
+		req.Header.Set("Authorization", "Bearer "+e.tokens[role])
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+const (
+	donor     = models.RoleDonor
+	volunteer = models.RoleVolunteer
+	admin     = models.RoleAdmin
+)
+
+// TestRoutePermissions 每个受保护的路由分别用未登录、捐赠者、志愿者和管理员访问。
+// 未登录返回 401，没有权限的角色返回 403；有权限的角色可能因参数或资源不存在返回 4xx，但不能是 401 或 403
+func TestRoutePermissions(t *testing.T) {
+	routes := []struct {
+		method  string
+		path    string
+		allowed []models.Role
+	}{
+		{"GET", "/api/v1/user/me", []models.Role{donor, volunteer}},
+		{"PUT", "/api/v1/user/me", []models.Role{donor, volunteer}},
+		{"GET", "/api/v1/user/me/export", []models.Role{donor, volunteer}},
+		{"GET", "/api/v1/user/me/deletion", []models.Role{donor, volunteer}},
+		{"POST", "/api/v1/user/me/deletion", []models.Role{donor, volunteer}},
+		{"DELETE", "/api/v1/user/me/deletion", []models.Role{donor, volunteer}},
+		{"POST", "/api/v1/user/phone", []models.Role{donor, volunteer}},
+		{"GET", "/api/v1/user/subscriptions", []models.Role{donor, volunteer}},
+		{"POST", "/api/v1/user/subscriptions", []models.Role{donor, volunteer}},
+		{"GET", "/api/v1/user/records", []models.Role{donor, volunteer}},
+
+		{"GET", "/api/v1/admin/records", []models.Role{volunteer, admin}},
+		{"POST", "/api/v1/admin/invitations", []models.Role{admin}},
+		{"GET", "/api/v1/admin/invitations", []models.Role{admin}},
+		{"DELETE", "/api/v1/admin/invitations/9999", []models.Role{admin}},
+		{"GET", "/api/v1/admin/me", []models.Role{admin}},
+		{"PUT", "/api/v1/admin/me", []models.Role{admin}},
+		{"PUT", "/api/v1/admin/me/password", []models.Role{admin}},
+		{"POST", "/api/v1/admin/me/totp/setup", []models.Role{admin}},
+		{"POST", "/api/v1/admin/me/totp/enable", []models.Role{admin}},
+		{"POST", "/api/v1/admin/me/totp/disable", []models.Role{admin}},
+		{"POST", "/api/v1/admin/me/totp/recovery-codes", []models.Role{admin}},
+		{"GET", "/api/v1/admin/admins", []models.Role{admin}},
+		{"PUT", "/api/v1/admin/admins/9999/password", []models.Role{admin}},
+		{"DELETE", "/api/v1/admin/admins/9999/totp", []models.Role{admin}},
+		{"GET", "/api/v1/admin/login-attempts", []models.Role{admin}},
+		{"GET", "/api/v1/admin/audit-logs", []models.Role{admin}},
+		{"GET", "/api/v1/admin/audit-logs/export", []models.Role{admin}},
+		{"GET", "/api/v1/admin/deletion-requests", []models.Role{admin}},
+		{"GET", "/api/v1/admin/notifications", []models.Role{admin}},
+		{"POST", "/api/v1/admin/uploads/gc", []models.Role{admin}},
+
+		{"POST", "/api/v1/wishes", []models.Role{admin}},
+		{"POST", "/api/v1/wishes/batch", []models.Role{admin}},
+		{"DELETE", "/api/v1/wishes/9999", []models.Role{admin}},
+		{"PUT", "/api/v1/wishes/9999", []models.Role{admin}},
+		{"PUT", "/api/v1/wishes/9999/donor", []models.Role{donor, volunteer}},
+		{"GET", "/api/v1/records", []models.Role{volunteer, admin}},
+		{"PUT", "/api/v1/records/9999/status", []models.Role{donor, volunteer, admin}},
+		{"PUT", "/api/v1/records/9999/shipping-info", []models.Role{donor, volunteer, admin}},
+		{"GET", "/api/v1/users/admin", []models.Role{admin}},
+		{"GET", "/api/v1/users/regular", []models.Role{admin}},
+		{"PUT", "/api/v1/users/9999/admin", []models.Role{admin}},
+		{"GET", "/api/v1/users/9999/restrictions", []models.Role{admin}},
+		{"POST", "/api/v1/users/9999/restrictions", []models.Role{admin}},
+		{"DELETE", "/api/v1/users/9999/restrictions", []models.Role{admin}},
+		{"POST", "/api/v1/upload/image", []models.Role{donor, volunteer, admin}},
+		{"POST", "/api/v1/upload/tickets", []models.Role{donor, volunteer, admin}},
+		{"POST", "/api/v1/upload/tickets/9999/complete", []models.Role{donor, volunteer, admin}},
+	}
+
+	env := newTestEnv(t)
+	for _, route := range routes {
+		allowed := map[models.Role]bool{}
+		for _, role := range route.allowed {
+			allowed[role] = true
+		}
+		for _, role := range []models.Role{"", donor, volunteer, admin} {
+			name := fmt.Sprintf("%s %s as %s", route.method, route.path, role)
+			if role == "" {
+				name = fmt.Sprintf("%s %s without token", route.method, route.path)
+			}
+			t.Run(name, func(t *testing.T) {
+				code := env.do(role, route.method, route.path, "{}").Code
+				switch {
+				case role == "":
+					if code != http.StatusUnauthorized {
+						t.Fatalf("未登录应返回 401，实际 %d", code)
+					}
+				case allowed[role]:
+					if code == http.StatusUnauthorized || code == http.StatusForbidden {
+						t.Fatalf("%s 有权限访问，实际返回 %d", role, code)
+					}
+				default:
+					if code != http.StatusForbidden {
+						t.Fatalf("%s 没有权限，应返回 403，实际 %d", role, code)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestRoleFollowsDatabase 取消志愿者权限后，之前签发的令牌立即失去志愿者权限
+func TestRoleFollowsDatabase(t *testing.T) {
+	env := newTestEnv(t)
+
+	if code := env.do(volunteer, "GET", "/api/v1/records", "").Code; code != http.StatusOK {
+		t.Fatalf("志愿者查看所有记录应返回 200，实际 %d", code)
+	}
+	if err := env.db.Model(&models.User{}).Where("id = ?", env.users[volunteer]).Update("is_admin", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code := env.do(volunteer, "GET", "/api/v1/records", "").Code; code != http.StatusForbidden {
+		t.Fatalf("取消志愿者后应返回 403，实际 %d", code)
+	}
+
+	// 反过来，授予志愿者后旧令牌立即获得权限
+	if err := env.db.Model(&models.User{}).Where("id = ?", env.users[donor]).Update("is_admin", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code := env.do(donor, "GET", "/api/v1/records", "").Code; code != http.StatusOK {
+		t.Fatalf("授予志愿者后应返回 200，实际 %d", code)
+	}
+}
+
+// TestDonorRecordStatus 捐赠者只能把自己待寄出的记录改为待确认，且只能写入寄送信息
+func TestDonorRecordStatus(t *testing.T) {
+	env := newTestEnv(t)
+
+	newRecord := func(donorID uint, status models.WishRecordStatus) uint {
+		wish := models.Wish{ChildName: "小明", Content: "书包", Reason: "上学", IsPublished: true}
+		if err := env.db.Create(&wish).Error; err != nil {
+			t.Fatal(err)
+		}
+		record := models.WishRecord{WishID: wish.ID, DonorID: donorID, Status: status}
+		if err := env.db.Create(&record).Error; err != nil {
+			t.Fatal(err)
+		}
+		return record.ID
+	}
+	ship := `{"status":"pending_confirmation","shippingNumber":"SF123","confirmationMessage":"伪造","confirmationPhotos":"[\"z\"]","receiptMessage":"伪造","receiptPhotos":"[\"x\"]","ownerGiftPhotos":"[\"y\"]"}`
+
+	cases := []struct {
+		name   string
+		donor  uint
+		status models.WishRecordStatus
+		body   string
+		want   int
+	}{
+		{"自己待寄出的记录", env.users[donor], models.StatusPendingShipment, ship, http.StatusOK},
+		{"已完成的记录不能回退", env.users[donor], models.StatusCompleted, ship, http.StatusForbidden},
+		{"已取消的记录不能回退", env.users[donor], models.StatusCancelled, ship, http.StatusForbidden},
+		{"别人的记录", env.users[volunteer], models.StatusPendingShipment, ship, http.StatusForbidden},
+		{"不能直接完成", env.users[donor], models.StatusPendingShipment, `{"status":"completed"}`, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			id := newRecord(tc.donor, tc.status)
+			w := env.do(donor, "PUT", fmt.Sprintf("/api/v1/records/%d/status", id), tc.body)
+			if w.Code != tc.want {
+				t.Fatalf("期望 %d，实际 %d: %s", tc.want, w.Code, w.Body.String())
+			}
+			if tc.want != http.StatusOK {
+				return
+			}
+			var record models.WishRecord
+			if err := env.db.First(&record, id).Error; err != nil {
+				t.Fatal(err)
+			}
+			if record.Status != models.StatusPendingConfirmation || record.ShippingNumber == nil || *record.ShippingNumber != "SF123" {
+				t.Fatalf("寄送信息未保存: %+v", record)
+			}
+			if record.ConfirmationMessage != nil || record.ConfirmationPhotos != nil ||
+				record.ReceiptMessage != nil || record.ReceiptPhotos != nil || record.OwnerGiftPhotos != nil {
+				t.Fatalf("捐赠者只能填写寄送单号: %+v", record)
+			}
+		})
+	}
+}
+
+// TestAdminTokenRevokedByPasswordChange 修改密码之前签发的管理员令牌不再有效
+func TestAdminTokenRevokedByPasswordChange(t *testing.T) {
+	env := newTestEnv(t)
+
+	if code := env.do(admin, "GET", "/api/v1/admin/me", "").Code; code != http.StatusOK {
+		t.Fatalf("修改密码前应返回 200，实际 %d", code)
+	}
+	changedAt := time.Now().Add(time.Second).Unix()
+	if err := env.db.Model(&models.Admin{}).Where("id = ?", env.users[admin]).Update("password_changed_at", changedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code := env.do(admin, "GET", "/api/v1/admin/me", "").Code; code != http.StatusUnauthorized {
+		t.Fatalf("修改密码后旧令牌应返回 401，实际 %d", code)
+	}
+	if err := env.db.Delete(&models.Admin{}, env.users[admin]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code := env.do(admin, "GET", "/api/v1/admin/me", "").Code; code != http.StatusUnauthorized {
+		t.Fatalf("管理员删除后应返回 401，实际 %d", code)
+	}
+}
+
+// TestRestrictedUserCanManageDeletion 被封禁或只读限制的用户仍可以导出数据、申请和撤销注销
+func TestRestrictedUserCanManageDeletion(t *testing.T) {
+	for _, level := range []models.RestrictionLevel{models.RestrictionBan, models.RestrictionBrowseOnly} {
+		t.Run(string(level), func(t *testing.T) {
+			env := newTestEnv(t)
+			restriction := models.UserRestriction{UserID: env.users[donor], Level: level, Reason: "测试", CreatedBy: env.users[admin]}
+			if err := env.db.Create(&restriction).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if code := env.do(donor, "PUT", "/api/v1/user/me", `{"nickname":"新昵称"}`).Code; code != http.StatusForbidden {
+				t.Fatalf("受限用户修改资料应返回 403，实际 %d", code)
+			}
+			for _, route := range []struct{ method, path string }{
+				{"GET", "/api/v1/user/me/export"},
+				{"POST", "/api/v1/user/me/deletion"},
+				{"GET", "/api/v1/user/me/deletion"},
+				{"DELETE", "/api/v1/user/me/deletion"},
+			} {
+				w := env.do(donor, route.method, route.path, "{}")
+				if w.Code != http.StatusOK {
+					t.Fatalf("%s %s 应返回 200，实际 %d: %s", route.method, route.path, w.Code, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+// TestWishListPhotos 未登录用户和捐赠者只能看到心愿照片的公开缩略图，志愿者和管理员可以看到原图
+func TestWishListPhotos(t *testing.T) {
+	env := newTestEnv(t)
+
+	original := storage.PrivatePrefix + "wishes/admin/1/photo.jpg"
+	thumbnail := "wishes/admin/1/photo_thumb.jpg"
+	for _, upload := range []models.Upload{
+		{Key: original, GroupKey: original, UploaderID: 1, UploaderType: "admin", Purpose: services.PurposeWishPhoto, Variant: imaging.VariantOriginal},
+		{Key: thumbnail, GroupKey: original, UploaderID: 1, UploaderType: "admin", Purpose: services.PurposeWishPhoto, Variant: imaging.VariantThumbnail},
+	} {
+		if err := env.db.Create(&upload).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	photos := `["` + env.store.URL(original) + `"]`
+	wish := models.Wish{ChildName: "小明", Content: "书包", Reason: "上学", IsPublished: true, PhotoURL: &photos}
+	if err := env.db.Create(&wish).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, role := range []models.Role{"", donor, volunteer, admin} {
+		w := env.do(role, "GET", "/api/v1/wishes", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: 期望 200，实际 %d: %s", role, w.Code, w.Body.String())
+		}
+		body := w.Body.String()
+		seesOriginal := strings.Contains(body, storage.PrivatePrefix)
+		if canSee := role == volunteer || role == admin; seesOriginal != canSee {
+			t.Errorf("%q: 能否看到原图应为 %v: %s", role, canSee, body)
+		}
+		if !seesOriginal && !strings.Contains(body, env.store.URL(thumbnail)) {
+			t.Errorf("%q: 应返回公开缩略图: %s", role, body)
+		}
+	}
+}
+
+// TestAdminCannotResetOwnPassword 重设接口不校验旧密码，管理员只能通过 /admin/me/password 修改自己的密码
+func TestAdminCannotResetOwnPassword(t *testing.T) {
+	env := newTestEnv(t)
+	other := models.Admin{Username: "other", Password: "-"}
+	if err := env.db.Create(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	body := `{"newPassword":"N3w!Passw0rd-2026"}`
+	var before models.Admin
+	if err := env.db.First(&before, env.users[admin]).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := env.do(admin, "PUT", fmt.Sprintf("/api/v1/admin/admins/%d/password", env.users[admin]), body)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("重设自己的密码应返回 403，实际 %d: %s", w.Code, w.Body.String())
+	}
+	var self models.Admin
+	if err := env.db.First(&self, env.users[admin]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if self.Password != before.Password {
+		t.Fatal("被拒绝的请求不应修改密码")
+	}
+
+	if w := env.do(admin, "PUT", fmt.Sprintf("/api/v1/admin/admins/%d/password", other.ID), body); w.Code != http.StatusOK {
+		t.Fatalf("重设其他管理员的密码应返回 200，实际 %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestAdminCannotResetOwnTOTP 清除两步验证的接口不校验密码，管理员只能通过 /admin/me/totp/disable 关闭自己的两步验证
+func TestAdminCannotResetOwnTOTP(t *testing.T) {
+	env := newTestEnv(t)
+	if err := env.db.Model(&models.Admin{}).Where("id = ?", env.users[admin]).
+		Updates(map[string]any{"totp_enabled": true, "totp_secret": "JBSWY3DPEHPK3PXP"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := env.do(admin, "DELETE", fmt.Sprintf("/api/v1/admin/admins/%d/totp", env.users[admin]), "")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("清除自己的两步验证应返回 403，实际 %d: %s", w.Code, w.Body.String())
+	}
+	var self models.Admin
+	if err := env.db.First(&self, env.users[admin]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !self.TOTPEnabled {
+		t.Fatal("被拒绝的请求不应清除两步验证")
+	}
+}
+
+// TestClaimRejectedWhileDeletionPending 注销冷静期内不能点亮心愿，撤销申请后恢复
+func TestClaimRejectedWhileDeletionPending(t *testing.T) {
+	env := newTestEnv(t)
+	wish := models.Wish{ChildName: "小明", Content: "书包", Reason: "上学", IsPublished: true}
+	if err := env.db.Create(&wish).Error; err != nil {
+		t.Fatal(err)
+	}
+	claim := `{"donorName":"小红","donorMobile":"13800000000","address":"上海市黄浦区"}`
+	path := fmt.Sprintf("/api/v1/wishes/%d/donor", wish.ID)
+
+	if w := env.do(donor, "POST", "/api/v1/user/me/deletion", `{"reason":"不再使用"}`); w.Code != http.StatusOK {
+		t.Fatalf("申请注销应返回 200，实际 %d: %s", w.Code, w.Body.String())
+	}
+	w := env.do(donor, "PUT", path, claim)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "撤销注销申请") {
+		t.Fatalf("冷静期内点亮心愿应返回 403，实际 %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := env.do(donor, "DELETE", "/api/v1/user/me/deletion", ""); w.Code != http.StatusOK {
+		t.Fatalf("撤销注销应返回 200，实际 %d: %s", w.Code, w.Body.String())
+	}
+	if w := env.do(donor, "PUT", path, claim); w.Code != http.StatusOK {
+		t.Fatalf("撤销注销后应可以点亮心愿，实际 %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// loginResponse 小程序登录接口的响应
+type loginResponse struct {
+	Errmsg string `json:"errmsg"`
+	Result struct {
+		Token string      `json:"token"`
+		User  models.User `json:"user"`
+	} `json:"result"`
+}
+
+// wechatLogin 使用 code 登录，返回令牌和用户
+func (e *testEnv) wechatLogin(t *testing.T, code string) (string, models.User) {
+	t.Helper()
+	w := e.do("", "POST", "/api/v1/user/login", fmt.Sprintf(`{"code":%q}`, code))
+	if w.Code != http.StatusOK {
+		t.Fatalf("登录应返回 200，实际 %d: %s", w.Code, w.Body.String())
+	}
+	var resp loginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Token == "" {
+		t.Fatalf("登录应返回令牌: %s", w.Body.String())
+	}
+	return resp.Result.Token, resp.Result.User
+}
+
+// doAs 携带指定令牌发送请求
+func (e *testEnv) doAs(token, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+// TestWechatLogin 首次登录创建用户，再次登录返回同一个用户；无效或已使用的 code 返回 400，微信接口故障返回 500
+func TestWechatLogin(t *testing.T) {
+	env := newTestEnv(t)
+
+	token, user := env.wechatLogin(t, "code-1")
+	if user.ID == 0 || user.WechatOpenID != wechattest.OpenID("code-1") {
+		t.Fatalf("应创建 openid 对应的用户: %+v", user)
+	}
+	if w := env.doAs(token, "GET", "/api/v1/user/me", ""); w.Code != http.StatusOK {
+		t.Fatalf("登录返回的令牌应可以访问个人信息，实际 %d: %s", w.Code, w.Body.String())
+	}
+	var saved models.User
+	if err := env.db.First(&saved, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.WechatSessionKey == "" {
+		t.Error("应保存登录时的 session_key")
+	}
+
+	for _, tc := range []struct {
+		name   string
+		code   string
+		status int
+	}{
+		{"无效的code", "invalid", http.StatusBadRequest},
+		{"已使用的code", "code-1", http.StatusBadRequest},
+		{"缺少code", "", http.StatusBadRequest},
+	} {
+		w := env.do("", "POST", "/api/v1/user/login", fmt.Sprintf(`{"code":%q}`, tc.code))
+		if w.Code != tc.status {
+			t.Errorf("%s: 应返回 %d，实际 %d: %s", tc.name, tc.status, w.Code, w.Body.String())
+		}
+	}
+
+	env.wechat.FailNext("/sns/jscode2session", http.StatusBadGateway)
+	w := env.do("", "POST", "/api/v1/user/login", `{"code":"code-2"}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("微信接口故障应返回 500，实际 %d: %s", w.Code, w.Body.String())
+	}
+
+	var count int64
+	if err := env.db.Model(&models.User{}).Where("wechat_openid LIKE ?", "o_fake_%").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("失败的登录不应创建用户，实际共 %d 个微信用户", count)
+	}
+}
+
+// TestBindWechatPhone 分别通过 code 和加密数据绑定手机号，授权数据无效时返回 400，会话失效时返回 401
+func TestBindWechatPhone(t *testing.T) {
+	env := newTestEnv(t)
+	token, user := env.wechatLogin(t, "code-1")
+
+	phoneOf := func() string {
+		t.Helper()
+		var saved models.User
+		if err := env.db.First(&saved, user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return saved.Phone
+	}
+
+	env.wechat.AddPhoneCode("phone-code", "13700000000")
+	w := env.doAs(token, "POST", "/api/v1/user/phone", `{"code":"phone-code"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "13700000000") {
+		t.Fatalf("通过 code 绑定手机号应返回 200，实际 %d: %s", w.Code, w.Body.String())
+	}
+	if got := phoneOf(); got != "13700000000" {
+		t.Fatalf("应保存手机号，实际 %q", got)
+	}
+
+	// 无效或已使用的 code 不修改已绑定的手机号
+	for _, code := range []string{"invalid", "phone-code"} {
+		w := env.doAs(token, "POST", "/api/v1/user/phone", fmt.Sprintf(`{"code":%q}`, code))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: 应返回 400，实际 %d: %s", code, w.Code, w.Body.String())
+		}
+	}
+	if got := phoneOf(); got != "13700000000" {
+		t.Fatalf("失败的绑定不应修改手机号，实际 %q", got)
+	}
+
+	// access_token 失效时刷新后重试
+	env.wechat.ExpireAccessTokens()
+	if w := env.doAs(token, "POST", "/api/v1/user/phone", `{"code":"phone-code-2"}`); w.Code != http.StatusOK {
+		t.Fatalf("access_token 过期后应刷新并重试，实际 %d: %s", w.Code, w.Body.String())
+	}
+	if got := phoneOf(); got != wechattest.DefaultPhone {
+		t.Fatalf("应保存新的手机号，实际 %q", got)
+	}
+
+	// 使用登录时的 session_key 解密
+	encryptedData, iv, err := env.wechat.EncryptPhone(user.WechatOpenID, "13600000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(map[string]string{"encryptedData": encryptedData, "iv": iv})
+	if w := env.doAs(token, "POST", "/api/v1/user/phone", string(body)); w.Code != http.StatusOK {
+		t.Fatalf("通过加密数据绑定手机号应返回 200，实际 %d: %s", w.Code, w.Body.String())
+	}
+	if got := phoneOf(); got != "13600000000" {
+		t.Fatalf("应保存解密得到的手机号，实际 %q", got)
+	}
+
+	// 没有保存 session_key 时无法解密，需要重新登录
+	if err := env.db.Model(&models.User{}).Where("id = ?", user.ID).Update("wechat_session_key", "").Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := env.doAs(token, "POST", "/api/v1/user/phone", string(body)); w.Code != http.StatusUnauthorized {
+		t.Errorf("会话失效应返回 401，实际 %d: %s", w.Code, w.Body.String())
+	}
+}
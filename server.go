@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// serve 在 ln 上提供服务，直到 ctx 被取消或服务出错。ctx 取消后不再接受新连接，
+// 等待处理中的请求完成，最多等待 shutdownTimeout，超时后强制关闭剩余连接
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		// 没有调用 Shutdown 时 Serve 不会返回 ErrServerClosed
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("等待处理中的请求超时: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// drainFirst 返回在 ctx 取消 delay 之后才取消的 context。ctx 取消时先调用 notify，
+// 让就绪检查失败，负载均衡有时间停止转发新请求后再关闭服务
+func drainFirst(ctx context.Context, delay time.Duration, notify func()) context.Context {
+	drained, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+		notify()
+		if delay > 0 {
+			slog.Info("收到退出信号，稍后停止服务", slog.String("delay", delay.String()))
+			time.Sleep(delay)
+		}
+		cancel()
+	}()
+	return drained
+}
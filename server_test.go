@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startServer 在随机端口上启动 serve，返回地址和 serve 的结果
+func startServer(t *testing.T, ctx context.Context, handler http.Handler, shutdownTimeout time.Duration) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, &http.Server{Handler: handler}, ln, shutdownTimeout)
+	}()
+	return "http://" + ln.Addr().String(), done
+}
+
+// newClient 每个请求使用新连接，以便观察监听是否已关闭
+func newClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+}
+
+func TestServeFinishesInFlightRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	addr, done := startServer(t, ctx, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("ok"))
+	}), 5*time.Second)
+
+	result := make(chan error, 1)
+	go func() {
+		resp, err := newClient().Get(addr)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.New(resp.Status)
+			}
+		}
+		result <- err
+	}()
+	<-started
+
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("处理中的请求未完成时 serve 不应返回: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// 停止期间不再接受新连接
+	if _, err := newClient().Get(addr); err == nil {
+		t.Fatal("停止期间不应接受新连接")
+	}
+
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("处理中的请求应正常完成: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("正常停止时不应返回错误: %v", err)
+	}
+}
+
+func TestServeForcesCloseAfterTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	addr, done := startServer(t, ctx, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}), 50*time.Millisecond)
+
+	go newClient().Get(addr)
+	<-started
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("等待超时应返回错误")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("超过 shutdownTimeout 后 serve 应返回")
+	}
+}
+
+// TestDrainFirstFailsReadinessBeforeClosing 收到退出信号后就绪检查先失败，延迟期间仍正常提供服务
+func TestDrainFirstFailsReadinessBeforeClosing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var shuttingDown atomic.Bool
+	serveCtx := drainFirst(ctx, 300*time.Millisecond, func() { shuttingDown.Store(true) })
+	addr, done := startServer(t, serveCtx, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}), 5*time.Second)
+
+	ready := func() (int, error) {
+		resp, err := newClient().Get(addr + "/health/ready")
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	if code, err := ready(); err != nil || code != http.StatusOK {
+		t.Fatalf("停止前应就绪: %d %v", code, err)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for !shuttingDown.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("收到退出信号后应调用 notify")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// notify 之后监听仍然打开，负载均衡能看到就绪检查失败
+	if code, err := ready(); err != nil || code != http.StatusServiceUnavailable {
+		t.Fatalf("延迟期间就绪检查应返回 503: %d %v", code, err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("延迟结束后 serve 应返回")
+	}
+	if _, err := ready(); err == nil {
+		t.Fatal("停止后不应再接受连接")
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wishes/config"
+	"wishes/models"
+)
+
+var (
+	ErrDeletionPending       = errors.New("已有待处理的注销申请")
+	ErrDeletionNotFound      = errors.New("没有可撤销的注销申请")
+	ErrDeletionActiveRecords = errors.New("还有进行中的认领记录，请在认领完成或取消后再申请注销")
+	ErrClaimDeletionPending  = errors.New("账号正在注销，冷静期内不能点亮心愿，如需继续使用请先撤销注销申请")
+)
+
+// deletionPostponeDelay 冷静期结束时仍有进行中的认领记录，注销延后的时间
+const deletionPostponeDelay = 24 * time.Hour
+
+// AnonymizedDonorName 匿名化后认领记录中显示的捐赠者姓名
+const AnonymizedDonorName = "已注销用户"
+
+// finishedRecordStatuses 认领记录已结束的状态，其余状态仍需要捐赠者的联系方式
+var finishedRecordStatuses = []models.WishRecordStatus{
+	models.StatusCompleted,
+	models.StatusGiftReturned,
+	models.StatusCancelled,
+}
+
+type AccountService struct {
+	db         *gorm.DB
+	tracker    *UploadTracker
+	coolingOff time.Duration
+	now        func() time.Time
+}
+
+func NewAccountService(db *gorm.DB, tracker *UploadTracker, cfg *config.Config) *AccountService {
+	return &AccountService{
+		db:         db,
+		tracker:    tracker,
+		coolingOff: cfg.AccountDeletionCoolingOff,
+		now:        time.Now,
+	}
+}
+
+// SetClock 替换当前时间的来源，便于模拟冷静期结束
+func (s *AccountService) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// ExportUserData 将用户的个人资料、认领记录、订阅授权和注销申请写入 zip 压缩包
+func (s *AccountService) ExportUserData(userID uint, w io.Writer) error {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	var records []models.WishRecord
+	if err := s.db.Preload("Wish", func(db *gorm.DB) *gorm.DB {
+		return db.Omit("ActiveRecord")
+	}).Where("donor_id = ?", userID).Order("id").Find(&records).Error; err != nil {
+		return err
+	}
+
+	var consents []models.SubscriptionConsent
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&consents).Error; err != nil {
+		return err
+	}
+
+	var deletions []models.AccountDeletionRequest
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&deletions).Error; err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", user},
+		{"records.json", records},
+		{"subscriptions.json", consents},
+		{"deletion_requests.json", deletions},
+	}
+	for _, file := range files {
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: s.now(),
+		})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// RequestDeletion 提交注销申请，冷静期结束后由后台任务匿名化个人信息
+func (s *AccountService) RequestDeletion(userID uint, reason string) (*models.AccountDeletionRequest, error) {
+	var request models.AccountDeletionRequest
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var pending int64
+		if err := tx.Model(&models.AccountDeletionRequest{}).
+			Where("user_id = ? AND status = ?", userID, models.DeletionPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrDeletionPending
+		}
+
+		var active int64
+		if err := tx.Model(&models.WishRecord{}).
+			Where("donor_id = ? AND status NOT IN ?", userID, finishedRecordStatuses).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrDeletionActiveRecords
+		}
+
+		request = models.AccountDeletionRequest{
+			UserID:      userID,
+			Status:      models.DeletionPending,
+			Reason:      reason,
+			ScheduledAt: s.now().Add(s.coolingOff).Unix(),
+		}
+		return tx.Create(&request).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetLatestDeletion 获取用户最近一次注销申请，没有时返回 nil
+func (s *AccountService) GetLatestDeletion(userID uint) (*models.AccountDeletionRequest, error) {
+	var request models.AccountDeletionRequest
+	if err := s.db.Where("user_id = ?", userID).Order("id DESC").First(&request).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// CancelDeletion 在冷静期内撤销注销申请
+func (s *AccountService) CancelDeletion(userID uint) (*models.AccountDeletionRequest, error) {
+	now := s.now().Unix()
+	result := s.db.Model(&models.AccountDeletionRequest{}).
+		Where("user_id = ? AND status = ?", userID, models.DeletionPending).
+		Updates(map[string]any{
+			"status":       models.DeletionCancelled,
+			"cancelled_at": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrDeletionNotFound
+	}
+	return s.GetLatestDeletion(userID)
+}
+
+// DeletionRequestFilter 注销申请查询条件，零值表示不过滤
+type DeletionRequestFilter struct {
+	UserID uint
+	Status models.AccountDeletionStatus
+}
+
+func (s *AccountService) GetDeletionRequests(filter DeletionRequestFilter, pageIndex, pageSize int) ([]models.AccountDeletionRequest, int64, error) {
+	query := s.db.Model(&models.AccountDeletionRequest{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (pageIndex - 1) * pageSize
+
+	var requests []models.AccountDeletionRequest
+	if err := query.Order("id DESC").Limit(pageSize).Offset(offset).Find(&requests).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return requests, total, nil
+}
+
+// Start 启动后台任务，定期处理冷静期已结束的注销申请，直到 ctx 被取消。
+// 返回的通道在任务退出后关闭，正在处理的申请会先处理完
+func (s *AccountService) Start(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.ProcessDueDeletions(); err != nil {
+				slog.ErrorContext(ctx, "处理注销申请失败", slog.String("error", err.Error()))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// ProcessDueDeletions 匿名化所有冷静期已结束的注销申请，返回处理的数量（包括延后的申请）
+func (s *AccountService) ProcessDueDeletions() (int, error) {
+	var requests []models.AccountDeletionRequest
+	if err := s.db.Where("status = ? AND scheduled_at <= ?", models.DeletionPending, s.now().Unix()).
+		Order("scheduled_at").Find(&requests).Error; err != nil {
+		return 0, err
+	}
+
+	for i, request := range requests {
+		if err := s.anonymize(&request); err != nil {
+			return i, fmt.Errorf("匿名化用户 %d 失败: %w", request.UserID, err)
+		}
+	}
+	return len(requests), nil
+}
+
+// anonymize 清除用户的个人信息。用户和认领记录本身保留，心愿、状态和时间等统计数据不受影响；
+// 审计日志按规定不可修改，继续保留到期限届满，写入时快照中的个人信息已经脱敏，不需要在这里清除。
+// 仍有进行中的认领记录时（例如管理员重新打开了记录），礼物还需要寄送，注销延后 deletionPostponeDelay 再处理
+func (s *AccountService) anonymize(request *models.AccountDeletionRequest) error {
+	now := s.now().Unix()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var active int64
+		if err := tx.Model(&models.WishRecord{}).
+			Where("donor_id = ? AND status NOT IN ?", request.UserID, finishedRecordStatuses).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			slog.Info("用户还有进行中的认领记录，注销延后处理",
+				slog.Uint64("user_id", uint64(request.UserID)), slog.Int64("active_records", active))
+			return tx.Model(&models.AccountDeletionRequest{}).
+				Where("id = ? AND status = ?", request.ID, models.DeletionPending).
+				Update("scheduled_at", s.now().Add(deletionPostponeDelay).Unix()).Error
+		}
+
+		// 条件更新，避免与用户撤销申请同时发生
+		result := tx.Model(&models.AccountDeletionRequest{}).
+			Where("id = ? AND status = ?", request.ID, models.DeletionPending).
+			Updates(map[string]any{
+				"status":       models.DeletionCompleted,
+				"completed_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", request.UserID).Updates(map[string]any{
+			// openid 有唯一索引，替换为不会与微信 openid 冲突的占位值，用户再次登录时会创建新账号
+			"wechat_openid":      fmt.Sprintf("deleted:%d", request.UserID),
+			"wechat_unionid":     "",
+			"wechat_session_key": "",
+			"nickname":           AnonymizedDonorName,
+			"avatar_url":         "",
+			"phone":              "",
+			"phone_verified_at":  0,
+			"is_admin":           false,
+			"deleted_at":         now,
+		}).Error; err != nil {
+			return err
+		}
+		// 头像已清空，不再保留头像文件
+		if err := s.tracker.SyncReferences(tx, RefTypeUser, request.UserID, UploadOwner{}, nil); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.WishRecord{}).Where("donor_id = ?", request.UserID).Updates(map[string]any{
+			"donor_name":      AnonymizedDonorName,
+			"donor_mobile":    "",
+			"donor_address":   "",
+			"donor_comment":   "",
+			"shipping_number": nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", request.UserID).Delete(&models.SubscriptionConsent{}).Error; err != nil {
+			return err
+		}
+		// 投递记录中的消息内容包含 openid，尚未发送的消息不再发送
+		if err := tx.Model(&models.NotificationDelivery{}).
+			Where("user_id = ? AND state = ?", request.UserID, models.NotificationPending).
+			Updates(map[string]any{
+				"state":           models.NotificationSkipped,
+				"last_error":      "用户已注销",
+				"next_attempt_at": 0,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.NotificationDelivery{}).Where("user_id = ?", request.UserID).
+			Update("payload", "").Error
+	})
+}
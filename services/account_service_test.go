@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wishes/models"
+	"wishes/storage"
+)
+
+type accountTestEnv struct {
+	db      *gorm.DB
+	account *AccountService
+	records *RecordService
+	donor   models.User
+	now     time.Time
+}
+
+func newAccountTestEnv(t *testing.T) *accountTestEnv {
+	t.Helper()
+	db, cfg := newTestDB(t)
+	cfg.AccountDeletionCoolingOff = 7 * 24 * time.Hour
+	tracker := NewUploadTracker(db, storage.NewMemoryStorage(""), cfg)
+
+	env := &accountTestEnv{
+		db:      db,
+		account: NewAccountService(db, tracker, cfg),
+		records: NewRecordService(db, tracker),
+		donor:   models.User{WechatOpenID: "openid-donor", Nickname: "小红", Phone: "13800000000"},
+		now:     time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+	}
+	env.account.SetClock(func() time.Time { return env.now })
+	if err := db.Create(&env.donor).Error; err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// claim 以捐赠者身份点亮一个新心愿
+func (e *accountTestEnv) claim(t *testing.T) (*models.WishRecord, error) {
+	t.Helper()
+	wish := models.Wish{ChildName: "小明", Content: "书包", Reason: "上学", IsPublished: true}
+	if err := e.db.Create(&wish).Error; err != nil {
+		t.Fatal(err)
+	}
+	record := models.WishRecord{
+		WishID:       wish.ID,
+		DonorID:      e.donor.ID,
+		DonorName:    "小红",
+		DonorMobile:  "13800000000",
+		DonorAddress: "上海市黄浦区",
+		Status:       models.StatusPendingShipment,
+	}
+	return &record, e.records.CreateRecordWithWish(&record, &wish)
+}
+
+func (e *accountTestEnv) reloadRecord(t *testing.T, record *models.WishRecord) models.WishRecord {
+	t.Helper()
+	var reloaded models.WishRecord
+	if err := e.db.First(&reloaded, record.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return reloaded
+}
+
+func TestDeletionLifecycle(t *testing.T) {
+	env := newAccountTestEnv(t)
+
+	record, err := env.claim(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.account.RequestDeletion(env.donor.ID, "不再使用"); !errors.Is(err, ErrDeletionActiveRecords) {
+		t.Fatalf("有进行中的认领记录时不能申请注销，实际 %v", err)
+	}
+	if err := env.db.Model(record).Update("status", models.StatusCompleted).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := env.account.RequestDeletion(env.donor.ID, "不再使用")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 冷静期内不能点亮心愿，否则注销后礼物无法寄送
+	if _, err := env.claim(t); !errors.Is(err, ErrClaimDeletionPending) {
+		t.Fatalf("冷静期内点亮心愿应返回 ErrClaimDeletionPending，实际 %v", err)
+	}
+
+	// 冷静期未结束时不处理
+	if _, err := env.account.ProcessDueDeletions(); err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := env.account.GetLatestDeletion(env.donor.ID); latest.Status != models.DeletionPending {
+		t.Fatalf("冷静期内不应注销: %+v", latest)
+	}
+
+	env.now = env.now.Add(7 * 24 * time.Hour)
+	if _, err := env.account.ProcessDueDeletions(); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := env.account.GetLatestDeletion(env.donor.ID)
+	if err != nil || latest.ID != request.ID || latest.Status != models.DeletionCompleted {
+		t.Fatalf("冷静期结束后应完成注销: %v %+v", err, latest)
+	}
+	var user models.User
+	if err := env.db.Unscoped().First(&user, env.donor.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Phone != "" || user.Nickname != AnonymizedDonorName {
+		t.Fatalf("用户信息应已匿名化: %+v", user)
+	}
+	if finished := env.reloadRecord(t, record); finished.DonorAddress != "" || finished.DonorMobile != "" || finished.DonorName != AnonymizedDonorName {
+		t.Fatalf("已完成的认领记录应已匿名化: %+v", finished)
+	}
+}
+
+func TestDeletionPostponedWhileRecordInProgress(t *testing.T) {
+	env := newAccountTestEnv(t)
+	if _, err := env.account.RequestDeletion(env.donor.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// 申请之后出现了进行中的记录，例如管理员重新打开了已取消的记录
+	record := models.WishRecord{DonorID: env.donor.ID, DonorAddress: "上海市黄浦区", DonorMobile: "13800000000", Status: models.StatusPendingShipment}
+	if err := env.db.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	env.now = env.now.Add(7 * 24 * time.Hour)
+	if _, err := env.account.ProcessDueDeletions(); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := env.account.GetLatestDeletion(env.donor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Status != models.DeletionPending || latest.ScheduledAt != env.now.Add(deletionPostponeDelay).Unix() {
+		t.Fatalf("有进行中的记录时注销应延后: %+v", latest)
+	}
+	if inProgress := env.reloadRecord(t, &record); inProgress.DonorAddress == "" || inProgress.DonorMobile == "" {
+		t.Fatalf("进行中的记录不应清除收货信息: %+v", inProgress)
+	}
+
+	if err := env.db.Model(&record).Update("status", models.StatusCompleted).Error; err != nil {
+		t.Fatal(err)
+	}
+	env.now = env.now.Add(deletionPostponeDelay)
+	if _, err := env.account.ProcessDueDeletions(); err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := env.account.GetLatestDeletion(env.donor.ID); latest.Status != models.DeletionCompleted {
+		t.Fatalf("记录结束后应完成注销: %+v", latest)
+	}
+}
@@ -0,0 +1,489 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"wishes/config"
+	"wishes/middleware"
+	"wishes/models"
+)
+
+var (
+	ErrAdminExists        = errors.New("管理员用户名已存在")
+	ErrAdminNotFound      = errors.New("管理员不存在")
+	ErrInvitationInvalid  = errors.New("邀请码无效、已使用、已撤销或已过期")
+	ErrInvitationNotFound = errors.New("邀请不存在")
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrAccountLocked      = errors.New("登录失败次数过多，请稍后再试")
+	// ErrSelfReset 管理员不能通过重设接口操作自己的账号，否则可以绕过旧密码或两步验证的校验
+	ErrSelfReset = errors.New("不能重设自己的账号")
+)
+
+// 登录尝试失败原因
+const (
+	LoginFailureInvalidCredentials = "invalid_credentials"
+	LoginFailureInvalidTOTP        = "invalid_totp"
+	LoginFailureLocked             = "locked"
+	// 密码正确但仍需完成两步验证，不算作成功登录
+	LoginPendingSecondFactor = "second_factor_pending"
+)
+
+// DefaultInvitationTTL 邀请默认有效期
+const DefaultInvitationTTL = 72 * time.Hour
+
+type AdminService struct {
+	db      *gorm.DB
+	tracker *UploadTracker
+
+	lockoutMaxFailures   int
+	lockoutIPMaxFailures int
+	lockoutWindow        time.Duration
+
+	totpRequired bool
+	totpIssuer   string
+
+	now func() time.Time
+}
+
+func NewAdminService(db *gorm.DB, tracker *UploadTracker, cfg *config.Config) *AdminService {
+	return &AdminService{
+		db:                   db,
+		tracker:              tracker,
+		lockoutMaxFailures:   cfg.AdminLockoutMaxFailures,
+		lockoutIPMaxFailures: cfg.AdminLockoutIPMaxFailures,
+		lockoutWindow:        cfg.AdminLockoutWindow,
+		totpRequired:         cfg.AdminTOTPRequired,
+		totpIssuer:           cfg.AdminTOTPIssuer,
+		now:                  time.Now,
+	}
+}
+
+// SetClock 替换服务使用的时钟，便于在测试中固定时间校验 TOTP 和登录锁定
+func (s *AdminService) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+func (s *AdminService) GetAdminByID(id uint) (*models.Admin, error) {
+	var admin models.Admin
+	if err := s.db.First(&admin, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdminNotFound
+		}
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// CheckAdminAccess 每次请求时读取管理员的密码修改和两步验证清除时间，管理员不存在时返回 ErrAdminNotFound
+func (s *AdminService) CheckAdminAccess(adminID uint) (*middleware.AdminAccess, error) {
+	var admin models.Admin
+	if err := s.db.Select("id", "password_changed_at", "totp_reset_at").First(&admin, adminID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdminNotFound
+		}
+		return nil, err
+	}
+	return &middleware.AdminAccess{PasswordChangedAt: admin.PasswordChangedAt, TOTPResetAt: admin.TOTPResetAt}, nil
+}
+
+// UpdateProfile 更新管理员的昵称、头像、部门和联系电话
+func (s *AdminService) UpdateProfile(adminID uint, update ProfileUpdate) (*models.Admin, error) {
+	updates, err := update.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(updates) > 0 {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.Admin{}).Where("id = ?", adminID).Updates(updates).Error; err != nil {
+				return err
+			}
+			return syncAvatar(tx, s.tracker, RefTypeAdmin, adminID, UploadOwner{UserID: adminID, UserType: "admin", Role: models.RoleAdmin}, updates)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetAdminByID(adminID)
+}
+
+func (s *AdminService) GetAdmins(pageIndex, pageSize int) ([]models.Admin, int64, error) {
+	query := s.db.Model(&models.Admin{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (pageIndex - 1) * pageSize
+
+	var admins []models.Admin
+	if err := query.Omit("password").Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&admins).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return admins, total, nil
+}
+
+// Login 校验管理员用户名和密码，并记录本次登录尝试。
+// 账号或来源IP在窗口期内失败次数达到上限时返回 ErrAccountLocked，此时不再校验密码。
+// 需要两步验证的账号在此只算完成第一步，需再调用 VerifySecondFactor。
+func (s *AdminService) Login(username, password, ip, userAgent string) (*models.Admin, error) {
+	attempt := models.LoginAttempt{
+		Username:  username,
+		IP:        ip,
+		UserAgent: userAgent,
+	}
+
+	var admin models.Admin
+	found := true
+	if err := s.db.Where("username = ?", username).First(&admin).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		found = false
+	} else {
+		attempt.AdminID = &admin.ID
+	}
+
+	locked, err := s.isLocked(username, admin.LockoutResetAt, ip)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		attempt.Reason = LoginFailureLocked
+		s.recordLoginAttempt(&attempt)
+		return nil, ErrAccountLocked
+	}
+
+	if !found || bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(password)) != nil {
+		attempt.Reason = LoginFailureInvalidCredentials
+		s.recordLoginAttempt(&attempt)
+		return nil, ErrInvalidCredentials
+	}
+
+	if s.NeedsSecondFactor(&admin) {
+		attempt.Reason = LoginPendingSecondFactor
+	} else {
+		attempt.Success = true
+	}
+	s.recordLoginAttempt(&attempt)
+	return &admin, nil
+}
+
+// isLocked 统计窗口期内的密码错误次数。账号维度只统计最近一次成功登录或解锁之后的失败。
+func (s *AdminService) isLocked(username string, lockoutResetAt int64, ip string) (bool, error) {
+	windowStart := s.now().Add(-s.lockoutWindow).Unix()
+
+	if s.lockoutMaxFailures > 0 {
+		since := max(windowStart, lockoutResetAt)
+
+		var lastSuccess models.LoginAttempt
+		err := s.db.Where("username = ? AND success = ? AND created_at >= ?", username, true, since).
+			Order("id DESC").First(&lastSuccess).Error
+		if err == nil {
+			since = lastSuccess.CreatedAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, err
+		}
+
+		var failures int64
+		if err := s.db.Model(&models.LoginAttempt{}).
+			Where("username = ? AND reason IN ? AND created_at >= ?", username, countedLoginFailures, since).
+			Count(&failures).Error; err != nil {
+			return false, err
+		}
+		if failures >= int64(s.lockoutMaxFailures) {
+			return true, nil
+		}
+	}
+
+	if s.lockoutIPMaxFailures > 0 && ip != "" {
+		var failures int64
+		if err := s.db.Model(&models.LoginAttempt{}).
+			Where("ip = ? AND reason IN ? AND created_at >= ?", ip, countedLoginFailures, windowStart).
+			Count(&failures).Error; err != nil {
+			return false, err
+		}
+		if failures >= int64(s.lockoutIPMaxFailures) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// 计入锁定次数的失败原因，被锁定期间的尝试不计入，避免锁定被无限延长
+var countedLoginFailures = []string{LoginFailureInvalidCredentials, LoginFailureInvalidTOTP}
+
+func (s *AdminService) recordLoginAttempt(attempt *models.LoginAttempt) {
+	attempt.CreatedAt = s.now().Unix()
+	if err := s.db.Create(attempt).Error; err != nil {
+		slog.Error("记录登录尝试失败", slog.String("error", err.Error()))
+	}
+}
+
+// LoginAttemptFilter 登录尝试查询条件，零值表示不过滤
+type LoginAttemptFilter struct {
+	Username string
+	IP       string
+	Success  *bool
+}
+
+func (s *AdminService) GetLoginAttempts(filter LoginAttemptFilter, pageIndex, pageSize int) ([]models.LoginAttempt, int64, error) {
+	query := s.db.Model(&models.LoginAttempt{})
+
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.Success != nil {
+		query = query.Where("success = ?", *filter.Success)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (pageIndex - 1) * pageSize
+
+	var attempts []models.LoginAttempt
+	if err := query.Order("id DESC").Limit(pageSize).Offset(offset).Find(&attempts).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return attempts, total, nil
+}
+
+// ChangePassword 管理员修改自己的密码，需要验证旧密码
+func (s *AdminService) ChangePassword(adminID uint, oldPassword, newPassword string) error {
+	admin, err := s.GetAdminByID(adminID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(oldPassword)); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	return s.setPassword(admin, newPassword, false)
+}
+
+// ResetPassword 由其他管理员为指定账号重设密码，同时解除该账号的登录锁定。
+// 不校验旧密码，因此 actorID 不能是该账号本身，修改自己的密码应使用 ChangePassword
+func (s *AdminService) ResetPassword(actorID, adminID uint, newPassword string) error {
+	if actorID == adminID {
+		return ErrSelfReset
+	}
+	admin, err := s.GetAdminByID(adminID)
+	if err != nil {
+		return err
+	}
+
+	return s.setPassword(admin, newPassword, true)
+}
+
+func (s *AdminService) setPassword(admin *models.Admin, password string, resetLockout bool) error {
+	if err := ValidatePassword(admin.Username, password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	now := s.now().Unix()
+	updates := map[string]any{
+		"password":            string(hashedPassword),
+		"password_changed_at": now,
+	}
+	if resetLockout {
+		updates["lockout_reset_at"] = now
+	}
+
+	return s.db.Model(&models.Admin{}).Where("id = ?", admin.ID).Updates(updates).Error
+}
+
+// CreateAdmin 直接创建管理员账号，仅供服务端命令行和邀请注册使用
+func (s *AdminService) CreateAdmin(username, password string) (*models.Admin, error) {
+	var admin *models.Admin
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		admin, err = s.createAdmin(tx, username, password)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// CountAdmins 返回现有管理员数量
+func (s *AdminService) CountAdmins() (int64, error) {
+	var count int64
+	if err := s.db.Model(&models.Admin{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CreateInvitation 生成一次性邀请令牌，返回的明文令牌只在此处出现一次
+func (s *AdminService) CreateInvitation(createdByID uint, ttl time.Duration, note string) (string, *models.AdminInvitation, error) {
+	if ttl <= 0 {
+		ttl = DefaultInvitationTTL
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(buf)
+
+	invitation := models.AdminInvitation{
+		TokenHash:   hashInvitationToken(token),
+		Note:        note,
+		CreatedByID: createdByID,
+		ExpiresAt:   s.now().Add(ttl).Unix(),
+	}
+	if err := s.db.Create(&invitation).Error; err != nil {
+		return "", nil, err
+	}
+
+	return token, &invitation, nil
+}
+
+func (s *AdminService) GetInvitations(pageIndex, pageSize int) ([]models.AdminInvitation, int64, error) {
+	query := s.db.Model(&models.AdminInvitation{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (pageIndex - 1) * pageSize
+
+	var invitations []models.AdminInvitation
+	if err := query.Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&invitations).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return invitations, total, nil
+}
+
+// RevokeInvitation 撤销尚未使用的邀请
+func (s *AdminService) RevokeInvitation(id, revokedByID uint) (*models.AdminInvitation, error) {
+	var invitation models.AdminInvitation
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&invitation, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationNotFound
+			}
+			return err
+		}
+
+		if invitation.Status(s.now().Unix()) != models.InvitationPending {
+			return ErrInvitationInvalid
+		}
+
+		now := s.now().Unix()
+		invitation.RevokedAt = &now
+		invitation.RevokedByID = &revokedByID
+		return tx.Save(&invitation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// RegisterWithInvitation 校验邀请令牌并创建管理员，令牌在同一事务中被标记为已使用
+func (s *AdminService) RegisterWithInvitation(token, username, password string) (*models.Admin, error) {
+	var admin *models.Admin
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var invitation models.AdminInvitation
+		if err := tx.Where("token_hash = ?", hashInvitationToken(token)).First(&invitation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationInvalid
+			}
+			return err
+		}
+
+		now := s.now().Unix()
+		if invitation.Status(now) != models.InvitationPending {
+			return ErrInvitationInvalid
+		}
+
+		var err error
+		admin, err = s.createAdmin(tx, username, password)
+		if err != nil {
+			return err
+		}
+
+		// 仅当邀请仍未被使用时才更新，防止并发请求重复使用同一令牌
+		result := tx.Model(&models.AdminInvitation{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", invitation.ID).
+			Updates(map[string]any{
+				"used_at":    now,
+				"used_by_id": admin.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+func (s *AdminService) createAdmin(tx *gorm.DB, username, password string) (*models.Admin, error) {
+	if err := ValidatePassword(username, password); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := tx.Model(&models.Admin{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAdminExists
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	admin := models.Admin{
+		Username:          username,
+		Password:          string(hashedPassword),
+		PasswordChangedAt: s.now().Unix(),
+	}
+	if err := tx.Create(&admin).Error; err != nil {
+		return nil, err
+	}
+
+	return &admin, nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"wishes/models"
+	"wishes/utils"
+)
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("已启用两步验证")
+	ErrTOTPNotEnabled     = errors.New("未启用两步验证")
+	ErrTOTPNotPending     = errors.New("请先获取两步验证密钥")
+	ErrTOTPRequired       = errors.New("系统要求管理员必须启用两步验证")
+	ErrInvalidTOTPCode    = errors.New("验证码或恢复码错误")
+)
+
+const (
+	recoveryCodeCount = 10
+	// 校验验证码时允许前后各一个时间步（30秒）的时钟偏差
+	totpSkew = 1
+)
+
+// TOTPEnrollment 两步验证绑定信息
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"` // otpauth:// 链接，可渲染为二维码
+}
+
+// NeedsSecondFactor 判断管理员登录是否需要两步验证
+func (s *AdminService) NeedsSecondFactor(admin *models.Admin) bool {
+	return admin.TOTPEnabled || s.totpRequired
+}
+
+// BeginTOTPEnrollment 生成待确认的 TOTP 密钥，确认前不会生效
+func (s *AdminService) BeginTOTPEnrollment(adminID uint) (*TOTPEnrollment, error) {
+	admin, err := s.GetAdminByID(adminID)
+	if err != nil {
+		return nil, err
+	}
+	if admin.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&models.Admin{}).Where("id = ?", adminID).
+		Update("totp_pending_secret", secret).Error; err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.totpIssuer, admin.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment 使用验证器生成的验证码确认绑定，成功后返回一组新的恢复码
+func (s *AdminService) ConfirmTOTPEnrollment(adminID uint, code string) ([]string, error) {
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var admin models.Admin
+		if err := tx.First(&admin, adminID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAdminNotFound
+			}
+			return err
+		}
+		if admin.TOTPEnabled {
+			return ErrTOTPAlreadyEnabled
+		}
+		if admin.TOTPPendingSecret == "" {
+			return ErrTOTPNotPending
+		}
+
+		step, ok := utils.VerifyTOTP(admin.TOTPPendingSecret, code, s.now(), totpSkew)
+		if !ok {
+			return ErrInvalidTOTPCode
+		}
+
+		if err := tx.Model(&models.Admin{}).Where("id = ?", adminID).Updates(map[string]any{
+			"totp_enabled":        true,
+			"totp_secret":         admin.TOTPPendingSecret,
+			"totp_pending_secret": "",
+			"totp_last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, adminID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// CompleteEnrollmentLogin 在登录过程中确认两步验证绑定，并记录登录尝试
+func (s *AdminService) CompleteEnrollmentLogin(adminID uint, code, ip, userAgent string) (*models.Admin, []string, error) {
+	admin, err := s.GetAdminByID(adminID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attempt := models.LoginAttempt{
+		Username:  admin.Username,
+		AdminID:   &admin.ID,
+		IP:        ip,
+		UserAgent: userAgent,
+	}
+
+	locked, err := s.isLocked(admin.Username, admin.LockoutResetAt, ip)
+	if err != nil {
+		return nil, nil, err
+	}
+	if locked {
+		attempt.Reason = LoginFailureLocked
+		s.recordLoginAttempt(&attempt)
+		return nil, nil, ErrAccountLocked
+	}
+
+	codes, err := s.ConfirmTOTPEnrollment(adminID, code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTOTPCode) {
+			attempt.Reason = LoginFailureInvalidTOTP
+			s.recordLoginAttempt(&attempt)
+		}
+		return nil, nil, err
+	}
+
+	attempt.Success = true
+	s.recordLoginAttempt(&attempt)
+
+	admin.TOTPEnabled = true
+	return admin, codes, nil
+}
+
+// VerifySecondFactor 校验登录第二步的验证码或恢复码，并记录登录尝试
+func (s *AdminService) VerifySecondFactor(adminID uint, code, recoveryCode, ip, userAgent string) (*models.Admin, error) {
+	admin, err := s.GetAdminByID(adminID)
+	if err != nil {
+		return nil, err
+	}
+	if !admin.TOTPEnabled {
+		return nil, ErrTOTPNotEnabled
+	}
+
+	attempt := models.LoginAttempt{
+		Username:  admin.Username,
+		AdminID:   &admin.ID,
+		IP:        ip,
+		UserAgent: userAgent,
+	}
+
+	locked, err := s.isLocked(admin.Username, admin.LockoutResetAt, ip)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		attempt.Reason = LoginFailureLocked
+		s.recordLoginAttempt(&attempt)
+		return nil, ErrAccountLocked
+	}
+
+	var verified bool
+	if code != "" {
+		verified, err = s.useTOTPCode(admin, code)
+	} else if recoveryCode != "" {
+		verified, err = s.useRecoveryCode(admin.ID, recoveryCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		attempt.Reason = LoginFailureInvalidTOTP
+		s.recordLoginAttempt(&attempt)
+		return nil, ErrInvalidTOTPCode
+	}
+
+	attempt.Success = true
+	s.recordLoginAttempt(&attempt)
+	return admin, nil
+}
+
+// useTOTPCode 校验验证码，同一时间步内的验证码只能使用一次
+func (s *AdminService) useTOTPCode(admin *models.Admin, code string) (bool, error) {
+	step, ok := utils.VerifyTOTP(admin.TOTPSecret, code, s.now(), totpSkew)
+	if !ok || step <= admin.TOTPLastUsedStep {
+		return false, nil
+	}
+
+	result := s.db.Model(&models.Admin{}).
+		Where("id = ? AND totp_last_used_step < ?", admin.ID, step).
+		Update("totp_last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *AdminService) useRecoveryCode(adminID uint, recoveryCode string) (bool, error) {
+	result := s.db.Model(&models.AdminRecoveryCode{}).
+		Where("admin_id = ? AND code_hash = ? AND used_at IS NULL", adminID, hashRecoveryCode(recoveryCode)).
+		Update("used_at", s.now().Unix())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DisableTOTP 管理员关闭自己的两步验证，需要验证密码；系统强制启用时不允许关闭
+func (s *AdminService) DisableTOTP(adminID uint, password string) error {
+	if s.totpRequired {
+		return ErrTOTPRequired
+	}
+
+	admin, err := s.GetAdminByID(adminID)
+	if err != nil {
+		return err
+	}
+	if !admin.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	return s.clearTOTP(adminID, nil)
+}
+
+// ResetTOTP 由其他管理员清除指定账号的两步验证（例如丢失手机）。不校验密码，因此 actorID 不能是该账号本身，
+// 关闭自己的两步验证应使用 DisableTOTP。
+// 系统强制启用时该账号不能在没有两步验证的情况下继续使用：之前签发的令牌全部失效，下次登录时需重新绑定
+func (s *AdminService) ResetTOTP(actorID, adminID uint) error {
+	if actorID == adminID {
+		return ErrSelfReset
+	}
+	if _, err := s.GetAdminByID(adminID); err != nil {
+		return err
+	}
+	updates := map[string]any{}
+	if s.totpRequired {
+		updates["totp_reset_at"] = s.now().Unix()
+	}
+	return s.clearTOTP(adminID, updates)
+}
+
+// clearTOTP 清除两步验证和恢复码，updates 为需要同时更新的其他字段
+func (s *AdminService) clearTOTP(adminID uint, updates map[string]any) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		fields := map[string]any{
+			"totp_enabled":        false,
+			"totp_secret":         "",
+			"totp_pending_secret": "",
+			"totp_last_used_step": 0,
+		}
+		maps.Copy(fields, updates)
+		if err := tx.Model(&models.Admin{}).Where("id = ?", adminID).Updates(fields).Error; err != nil {
+			return err
+		}
+		return tx.Where("admin_id = ?", adminID).Delete(&models.AdminRecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes 使旧恢复码全部失效并生成一组新的恢复码，需要提供当前验证码
+func (s *AdminService) RegenerateRecoveryCodes(adminID uint, code string) ([]string, error) {
+	admin, err := s.GetAdminByID(adminID)
+	if err != nil {
+		return nil, err
+	}
+	if !admin.TOTPEnabled {
+		return nil, ErrTOTPNotEnabled
+	}
+
+	ok, err := s.useTOTPCode(admin, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, adminID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *AdminService) replaceRecoveryCodes(tx *gorm.DB, adminID uint) ([]string, error) {
+	if err := tx.Where("admin_id = ?", adminID).Delete(&models.AdminRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.AdminRecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+		codes[i] = raw[:4] + "-" + raw[4:]
+		records[i] = models.AdminRecoveryCode{
+			AdminID:  adminID,
+			CodeHash: hashRecoveryCode(codes[i]),
+		}
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode 忽略大小写、空格和连字符后计算哈希
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"wishes/utils"
+)
+
+type totpTestEnv struct {
+	service *AdminService
+	adminID uint
+	secret  string
+	now     time.Time
+}
+
+// newTOTPTestEnv 创建一个已绑定两步验证的管理员，时钟固定在某个时间步的开头
+func newTOTPTestEnv(t *testing.T) (*totpTestEnv, []string) {
+	t.Helper()
+	db, cfg := newTestDB(t)
+	// 失败次数不影响本文件的测试
+	cfg.AdminLockoutMaxFailures = 100
+	cfg.AdminLockoutIPMaxFailures = 100
+
+	env := &totpTestEnv{
+		service: NewAdminService(db, nil, cfg),
+		now:     time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+	}
+	env.service.SetClock(func() time.Time { return env.now })
+
+	admin, err := env.service.CreateAdmin("root", "Passw0rd!Passw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.adminID = admin.ID
+	enrollment, err := env.service.BeginTOTPEnrollment(admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.secret = enrollment.Secret
+
+	codes, err := env.service.ConfirmTOTPEnrollment(admin.ID, env.code(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env, codes
+}
+
+// code 返回当前时间偏移 offset 个时间步的验证码
+func (e *totpTestEnv) code(t *testing.T, offset int64) string {
+	t.Helper()
+	code, err := utils.TOTPCode(e.secret, utils.TOTPStep(e.now)+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func (e *totpTestEnv) verify(code, recoveryCode string) error {
+	_, err := e.service.VerifySecondFactor(e.adminID, code, recoveryCode, "127.0.0.1", "test")
+	return err
+}
+
+func TestTOTPAcceptsOneStepOfClockSkew(t *testing.T) {
+	env, _ := newTOTPTestEnv(t)
+	// 绑定时使用了当前时间步，之后的验证在三个时间步以后进行，窗口内的时间步都未使用过
+	env.now = env.now.Add(3 * utils.TOTPPeriod * time.Second)
+
+	if err := env.verify(env.code(t, -2), ""); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("相差两个时间步的验证码应被拒绝，实际 %v", err)
+	}
+	if err := env.verify(env.code(t, 2), ""); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("超前两个时间步的验证码应被拒绝，实际 %v", err)
+	}
+	if err := env.verify(env.code(t, -1), ""); err != nil {
+		t.Errorf("落后一个时间步的验证码应通过: %v", err)
+	}
+	if err := env.verify(env.code(t, 1), ""); err != nil {
+		t.Errorf("超前一个时间步的验证码应通过: %v", err)
+	}
+}
+
+func TestTOTPRejectsReplay(t *testing.T) {
+	env, _ := newTOTPTestEnv(t)
+
+	// 绑定时使用过的验证码不能再用于登录
+	if err := env.verify(env.code(t, 0), ""); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("绑定时使用过的验证码应被拒绝，实际 %v", err)
+	}
+
+	env.now = env.now.Add(utils.TOTPPeriod * time.Second)
+	code := env.code(t, 0)
+	if err := env.verify(code, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.verify(code, ""); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("同一验证码不能使用两次，实际 %v", err)
+	}
+	// 已使用时间步之前的验证码仍在偏差范围内，也不能再使用
+	if err := env.verify(env.code(t, -1), ""); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("早于已使用时间步的验证码应被拒绝，实际 %v", err)
+	}
+	if _, err := env.service.RegenerateRecoveryCodes(env.adminID, code); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("重新生成恢复码时也不能重放验证码，实际 %v", err)
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	env, codes := newTOTPTestEnv(t)
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("应生成 %d 个恢复码，实际 %d", recoveryCodeCount, len(codes))
+	}
+
+	if err := env.verify("", codes[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.verify("", codes[0]); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("恢复码只能使用一次，实际 %v", err)
+	}
+	// 输入时忽略大小写和连字符
+	if err := env.verify("", strings.ToUpper(strings.ReplaceAll(codes[1], "-", ""))); err != nil {
+		t.Fatalf("恢复码应忽略大小写和连字符: %v", err)
+	}
+
+	// 重新生成后旧恢复码全部失效
+	env.now = env.now.Add(utils.TOTPPeriod * time.Second)
+	fresh, err := env.service.RegenerateRecoveryCodes(env.adminID, env.code(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.verify("", codes[2]); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("重新生成后旧恢复码应失效，实际 %v", err)
+	}
+	if err := env.verify("", fresh[0]); err != nil {
+		t.Fatalf("新恢复码应可用: %v", err)
+	}
+}
+
+func TestResetTOTP(t *testing.T) {
+	for _, required := range []bool{false, true} {
+		env, _ := newTOTPTestEnv(t)
+		env.service.totpRequired = required
+		other, err := env.service.CreateAdmin("other", "Passw0rd!Passw0rd")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 重设接口不校验密码，不能用于自己的账号
+		if err := env.service.ResetTOTP(env.adminID, env.adminID); !errors.Is(err, ErrSelfReset) {
+			t.Fatalf("清除自己的两步验证应返回 ErrSelfReset，实际 %v", err)
+		}
+		if err := env.service.ResetTOTP(other.ID, env.adminID); err != nil {
+			t.Fatal(err)
+		}
+
+		admin, err := env.service.GetAdminByID(env.adminID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if admin.TOTPEnabled || admin.TOTPSecret != "" {
+			t.Fatalf("两步验证应已清除: %+v", admin)
+		}
+		if env.service.NeedsSecondFactor(admin) != required {
+			t.Fatalf("强制启用为 %v 时，下次登录是否需要重新绑定应一致", required)
+		}
+		// 系统强制启用时，没有两步验证的旧令牌不能继续使用
+		access, err := env.service.CheckAdminAccess(env.adminID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked := access.TOTPResetAt == env.now.Unix(); revoked != required {
+			t.Fatalf("强制启用为 %v 时旧令牌是否失效应一致，TOTPResetAt=%d", required, access.TOTPResetAt)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"gorm.io/gorm"
+
+	"wishes/models"
+)
+
+// MaxAuditExportRows 单次导出的最大行数
+const MaxAuditExportRows = 50000
+
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{
+		db: db,
+	}
+}
+
+// AuditLogFilter 审计日志查询条件，零值表示不过滤
+type AuditLogFilter struct {
+	ActorType  string
+	ActorID    uint
+	Action     string // 前缀匹配
+	TargetType string
+	TargetID   string
+	IP         string
+	From       int64 // 起始时间（含）
+	To         int64 // 截止时间（含）
+}
+
+// Record 追加一条审计日志
+func (s *AuditService) Record(entry *models.AuditLog) error {
+	return s.db.Create(entry).Error
+}
+
+func (s *AuditService) applyFilter(filter AuditLogFilter) *gorm.DB {
+	query := s.db.Model(&models.AuditLog{})
+
+	if filter.ActorType != "" {
+		query = query.Where("actor_type = ?", filter.ActorType)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action LIKE ?", filter.Action+"%")
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.From > 0 {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if filter.To > 0 {
+		query = query.Where("created_at <= ?", filter.To)
+	}
+
+	return query
+}
+
+func (s *AuditService) GetAuditLogs(filter AuditLogFilter, pageIndex, pageSize int) ([]models.AuditLog, int64, error) {
+	query := s.applyFilter(filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (pageIndex - 1) * pageSize
+
+	var logs []models.AuditLog
+	if err := query.Order("id DESC").Limit(pageSize).Offset(offset).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+// ExportAuditLogs 按条件导出审计日志，最多 MaxAuditExportRows 行
+func (s *AuditService) ExportAuditLogs(filter AuditLogFilter) ([]models.AuditLog, error) {
+	var logs []models.AuditLog
+	if err := s.applyFilter(filter).Order("id DESC").Limit(MaxAuditExportRows).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
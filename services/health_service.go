@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wishes/config"
+	"wishes/storage"
+)
+
+// readinessTimeout 单次就绪检查的最长时间，超时的检查项视为失败
+const readinessTimeout = 5 * time.Second
+
+// errProbeRollback 用于回滚写入检查的事务
+var errProbeRollback = errors.New("rollback")
+
+// BuildInfo 构建信息，提交和构建时间在编译时注入
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// ReadinessCheck 一项就绪检查的结果
+type ReadinessCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"durationMs"`
+}
+
+// Readiness 就绪检查的结果，所有检查项通过时 Ready 为 true
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// HealthService 检查服务依赖的数据库和存储后端是否可用
+type HealthService struct {
+	db           *gorm.DB
+	storage      storage.Storage
+	build        BuildInfo
+	shuttingDown atomic.Bool
+	// 写入检查会占用数据库写锁，同一时间只做一次
+	probeMu sync.Mutex
+}
+
+// NewHealthService 创建健康检查服务实例
+func NewHealthService(db *gorm.DB, store storage.Storage, build BuildInfo) *HealthService {
+	return &HealthService{
+		db:      db,
+		storage: store,
+		build:   build,
+	}
+}
+
+// BuildInfo 返回构建信息
+func (s *HealthService) BuildInfo() BuildInfo {
+	return s.build
+}
+
+// SetShuttingDown 标记服务正在停止，此后就绪检查始终失败，让负载均衡不再转发新请求
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Readiness 依次检查服务是否正在停止、数据库能否读写、数据表是否已迁移到当前版本以及存储后端能否访问
+func (s *HealthService) Readiness(ctx context.Context) *Readiness {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	result := &Readiness{Ready: true}
+	check := func(name string, fn func(context.Context) error) {
+		start := time.Now()
+		err := fn(ctx)
+		item := ReadinessCheck{
+			Name:     name,
+			OK:       err == nil,
+			Duration: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			item.Error = err.Error()
+			result.Ready = false
+		}
+		result.Checks = append(result.Checks, item)
+	}
+
+	check("shutdown", func(context.Context) error {
+		if s.shuttingDown.Load() {
+			return errors.New("服务正在停止")
+		}
+		return nil
+	})
+	check("database", s.checkDatabase)
+	check("migrations", func(ctx context.Context) error {
+		return config.CheckMigrations(s.db.WithContext(ctx))
+	})
+	check("storage", func(ctx context.Context) error {
+		if checker, ok := s.storage.(storage.Checker); ok {
+			return checker.Check(ctx)
+		}
+		return nil
+	})
+	return result
+}
+
+// checkDatabase 确认数据库连接可用且可以写入。写入检查在事务中建表后回滚，不留下数据
+func (s *HealthService) checkDatabase(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return err
+	}
+
+	s.probeMu.Lock()
+	defer s.probeMu.Unlock()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE TABLE readiness_probe (id INTEGER)").Error; err != nil {
+			return err
+		}
+		return errProbeRollback
+	})
+	if !errors.Is(err, errProbeRollback) {
+		return err
+	}
+	return nil
+}
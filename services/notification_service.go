@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wishes/config"
+	"wishes/models"
+	"wishes/wechat"
+)
+
+// 订阅消息授权结果，与小程序 wx.requestSubscribeMessage 返回的值一致
+const (
+	SubscribeAccept = "accept"
+	SubscribeReject = "reject"
+	SubscribeBan    = "ban"
+	SubscribeFilter = "filter"
+)
+
+const (
+	// notificationBatchSize 每轮最多处理的待发送消息数
+	notificationBatchSize = 20
+	// notificationLease 发送前先占用消息，避免多个实例重复发送
+	notificationLease = 2 * time.Minute
+)
+
+// notificationBackoff 第 N 次发送失败后等待的时间，超出部分使用最后一项
+var notificationBackoff = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+}
+
+// recordStatusNotice 各状态通知的文案。订阅消息模板需要包含以下字段：
+// thing1 心愿内容、phrase2 进度（不超过5个汉字）、time3 更新时间、thing4 温馨提示
+var recordStatusNotices = map[models.WishRecordStatus]struct {
+	Phrase string
+	Tip    string
+}{
+	models.StatusConfirmed:       {"平台已签收", "您的礼物已送达平台，我们会尽快转交"},
+	models.StatusAwaitingReceipt: {"已寄往学校", "礼物正在送往孩子身边，请耐心等待"},
+	models.StatusCompleted:       {"孩子已签收", "孩子已收到您的礼物，感谢您的爱心"},
+	models.StatusGiftReturned:    {"已回礼", "孩子为您准备了回礼，快去看看吧"},
+}
+
+type NotificationService struct {
+	db          *gorm.DB
+	wechat      *WechatService
+	templates   map[models.WishRecordStatus]string
+	page        string
+	state       string
+	maxAttempts int
+	interval    time.Duration
+	now         func() time.Time
+	wake        chan struct{}
+}
+
+func NewNotificationService(db *gorm.DB, wechatService *WechatService, cfg *config.Config) *NotificationService {
+	templates := make(map[models.WishRecordStatus]string)
+	for status, templateID := range cfg.WechatMessageTemplates {
+		if templateID != "" {
+			templates[models.WishRecordStatus(status)] = templateID
+		}
+	}
+
+	maxAttempts := cfg.NotificationMaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	interval := cfg.NotificationPollInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	return &NotificationService{
+		db:          db,
+		wechat:      wechatService,
+		templates:   templates,
+		page:        cfg.WechatMessagePage,
+		state:       cfg.WechatMiniprogramState,
+		maxAttempts: maxAttempts,
+		interval:    interval,
+		now:         time.Now,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// SetClock 替换当前时间的来源，便于模拟重试等待
+func (s *NotificationService) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Templates 返回已配置的记录状态和订阅消息模板，小程序据此调用 wx.requestSubscribeMessage
+func (s *NotificationService) Templates() map[models.WishRecordStatus]string {
+	return s.templates
+}
+
+// GetConsents 获取用户对各模板的授权情况
+func (s *NotificationService) GetConsents(userID uint) ([]models.SubscriptionConsent, error) {
+	var consents []models.SubscriptionConsent
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// RecordConsent 保存 wx.requestSubscribeMessage 的授权结果。
+// 每次 accept 增加一条可发送额度，reject、ban 清空额度；未配置的模板ID会被忽略。
+func (s *NotificationService) RecordConsent(userID uint, results map[string]string) error {
+	known := make(map[string]bool, len(s.templates))
+	for _, templateID := range s.templates {
+		known[templateID] = true
+	}
+
+	now := s.now().Unix()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for templateID, result := range results {
+			if !known[templateID] {
+				continue
+			}
+
+			var consent models.SubscriptionConsent
+			err := tx.Where("user_id = ? AND template_id = ?", userID, templateID).First(&consent).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				consent = models.SubscriptionConsent{UserID: userID, TemplateID: templateID}
+			} else if err != nil {
+				return err
+			}
+
+			switch result {
+			case SubscribeAccept:
+				consent.Remaining++
+				consent.LastAcceptedAt = now
+			case SubscribeReject, SubscribeBan:
+				consent.Remaining = 0
+				consent.LastRejectedAt = now
+			default:
+				// filter 表示模板被后台过滤，不改变授权
+				continue
+			}
+
+			if err := tx.Save(&consent).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// NotifyRecordStatus 在记录进入需要通知的状态时为捐赠者创建一条订阅消息，由后台任务异步发送。
+// record 需要预加载 Wish 和 Donor。没有配置模板的状态直接忽略，没有剩余授权时记为 skipped。
+func (s *NotificationService) NotifyRecordStatus(record *models.WishRecord) error {
+	templateID, ok := s.templates[record.Status]
+	if !ok || record.Donor == nil || record.Donor.WechatOpenID == "" {
+		return nil
+	}
+
+	msg := s.recordStatusMessage(record, templateID)
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	delivery := models.NotificationDelivery{
+		UserID:        record.DonorID,
+		RecordID:      record.ID,
+		Event:         record.Status,
+		TemplateID:    templateID,
+		Payload:       string(payload),
+		State:         models.NotificationPending,
+		NextAttemptAt: s.now().Unix(),
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		consumed, err := s.consumeConsent(tx, record.DonorID, templateID)
+		if err != nil {
+			return err
+		}
+		if !consumed {
+			delivery.State = models.NotificationSkipped
+			delivery.LastError = "用户没有剩余的订阅授权"
+			delivery.NextAttemptAt = 0
+		}
+		return tx.Create(&delivery).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if delivery.State == models.NotificationPending {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *NotificationService) consumeConsent(tx *gorm.DB, userID uint, templateID string) (bool, error) {
+	result := tx.Model(&models.SubscriptionConsent{}).
+		Where("user_id = ? AND template_id = ? AND remaining > 0", userID, templateID).
+		Update("remaining", gorm.Expr("remaining - 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *NotificationService) recordStatusMessage(record *models.WishRecord, templateID string) *wechat.SubscribeMessage {
+	notice := recordStatusNotices[record.Status]
+
+	content := "心愿"
+	if record.Wish != nil && record.Wish.Content != "" {
+		content = record.Wish.Content
+	}
+
+	return &wechat.SubscribeMessage{
+		ToUser:           record.Donor.WechatOpenID,
+		TemplateID:       templateID,
+		Page:             fmt.Sprintf("%s?id=%d", s.page, record.ID),
+		MiniprogramState: s.state,
+		Lang:             "zh_CN",
+		Data: map[string]wechat.MessageValue{
+			"thing1":  {Value: truncateRunes(content, 20)},
+			"phrase2": {Value: notice.Phrase},
+			"time3":   {Value: s.now().Format("2006-01-02 15:04")},
+			"thing4":  {Value: truncateRunes(notice.Tip, 20)},
+		},
+	}
+}
+
+// Start 启动后台发送任务，直到 ctx 被取消。返回的通道在任务退出后关闭，
+// 正在发送的一批消息会先处理完
+func (s *NotificationService) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.ProcessDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+	return done
+}
+
+// ProcessDue 发送所有到期的待发送消息，返回本轮处理的条数
+func (s *NotificationService) ProcessDue(ctx context.Context) int {
+	processed := 0
+	for ctx.Err() == nil {
+		var deliveries []models.NotificationDelivery
+		if err := s.db.Where("state = ? AND next_attempt_at <= ?", models.NotificationPending, s.now().Unix()).
+			Order("next_attempt_at").Limit(notificationBatchSize).Find(&deliveries).Error; err != nil {
+			slog.ErrorContext(ctx, "查询待发送的订阅消息失败", slog.String("error", err.Error()))
+			return processed
+		}
+		if len(deliveries) == 0 {
+			return processed
+		}
+
+		for i := range deliveries {
+			claimed, err := s.claim(&deliveries[i])
+			if err != nil {
+				// 数据库出错时消息仍是到期状态，继续循环只会反复选中它们，留到下一轮再处理
+				slog.ErrorContext(ctx, "占用待发送的订阅消息失败", slog.Uint64("delivery_id", uint64(deliveries[i].ID)), slog.String("error", err.Error()))
+				return processed
+			}
+			if claimed {
+				s.deliver(ctx, &deliveries[i])
+				processed++
+			}
+		}
+	}
+	return processed
+}
+
+// claim 通过推迟 next_attempt_at 占用消息，其他实例不会再选中它。
+// 消息已被其他实例占用时返回 false，数据库出错时返回错误
+func (s *NotificationService) claim(delivery *models.NotificationDelivery) (bool, error) {
+	result := s.db.Model(&models.NotificationDelivery{}).
+		Where("id = ? AND state = ? AND next_attempt_at = ?", delivery.ID, models.NotificationPending, delivery.NextAttemptAt).
+		Update("next_attempt_at", s.now().Add(notificationLease).Unix())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *NotificationService) deliver(ctx context.Context, delivery *models.NotificationDelivery) {
+	var msg wechat.SubscribeMessage
+	err := json.Unmarshal([]byte(delivery.Payload), &msg)
+	retryable := false
+	if err == nil {
+		err = s.wechat.Tokens.Do(ctx, func(accessToken string) error {
+			return s.wechat.Client.SendSubscribeMessage(ctx, accessToken, &msg)
+		})
+		retryable = retryableNotificationError(err)
+	}
+
+	now := s.now()
+	attempts := delivery.Attempts + 1
+	updates := map[string]any{"attempts": attempts}
+
+	switch {
+	case err == nil:
+		updates["state"] = models.NotificationSent
+		updates["sent_at"] = now.Unix()
+		updates["last_error"] = ""
+		updates["next_attempt_at"] = 0
+	case !retryable || attempts >= s.maxAttempts:
+		updates["state"] = models.NotificationFailed
+		updates["last_error"] = err.Error()
+		updates["next_attempt_at"] = 0
+	default:
+		wait := notificationBackoff[min(attempts, len(notificationBackoff))-1]
+		updates["last_error"] = err.Error()
+		updates["next_attempt_at"] = now.Add(wait).Unix()
+	}
+
+	if errors.Is(err, wechat.ErrSubscribeRefused) {
+		// 用户已在微信中关闭或授权已用完，本地额度不再可信
+		s.db.Model(&models.SubscriptionConsent{}).
+			Where("user_id = ? AND template_id = ?", delivery.UserID, delivery.TemplateID).
+			Update("remaining", 0)
+	}
+
+	if err := s.db.Model(&models.NotificationDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error; err != nil {
+		slog.ErrorContext(ctx, "更新订阅消息的发送状态失败", slog.Uint64("delivery_id", uint64(delivery.ID)), slog.String("error", err.Error()))
+	}
+}
+
+// retryableNotificationError 网络错误、系统繁忙、频率限制和 access_token 问题可以稍后重试，
+// 其余微信业务错误（如用户拒收、模板参数错误）重试也不会成功
+func retryableNotificationError(err error) bool {
+	switch wechat.ErrorCode(err) {
+	case 0, wechat.ErrSystemBusy.Code, wechat.ErrRateLimited.Code,
+		wechat.ErrInvalidCredential.Code, wechat.ErrAccessTokenExpired.Code:
+		return true
+	default:
+		return false
+	}
+}
+
+// NotificationDeliveryFilter 投递记录查询条件，零值表示不过滤
+type NotificationDeliveryFilter struct {
+	UserID   uint
+	RecordID uint
+	State    models.NotificationState
+}
+
+func (s *NotificationService) GetDeliveries(filter NotificationDeliveryFilter, pageIndex, pageSize int) ([]models.NotificationDelivery, int64, error) {
+	query := s.db.Model(&models.NotificationDelivery{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.RecordID != 0 {
+		query = query.Where("record_id = ?", filter.RecordID)
+	}
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (pageIndex - 1) * pageSize
+
+	var deliveries []models.NotificationDelivery
+	if err := query.Order("id DESC").Limit(pageSize).Offset(offset).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
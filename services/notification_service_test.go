@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wishes/models"
+	"wishes/wechat"
+	"wishes/wechat/wechattest"
+)
+
+const (
+	testTemplateID = "tpl-completed"
+	sendPath       = "/cgi-bin/message/subscribe/send"
+)
+
+type notificationTestEnv struct {
+	db      *gorm.DB
+	fake    *wechattest.Server
+	service *NotificationService
+	now     time.Time
+}
+
+func newNotificationTestEnv(t *testing.T) *notificationTestEnv {
+	t.Helper()
+	db, cfg := newTestDB(t)
+	cfg.WechatMessageTemplates = map[string]string{string(models.StatusCompleted): testTemplateID}
+	cfg.NotificationMaxAttempts = 3
+
+	fake := wechattest.NewServer("", "")
+	server := fake.Start()
+	t.Cleanup(server.Close)
+
+	env := &notificationTestEnv{
+		db:   db,
+		fake: fake,
+		now:  time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+	}
+	env.service = NewNotificationService(db, NewWechatService(db, fake.Client(server.URL)), cfg)
+	env.service.SetClock(func() time.Time { return env.now })
+	return env
+}
+
+// completeRecord 为新的捐赠者创建一条已完成的记录并触发通知，consents 为捐赠者事先授权的次数
+func (e *notificationTestEnv) completeRecord(t *testing.T, consents int) models.NotificationDelivery {
+	t.Helper()
+	donor := models.User{WechatOpenID: wechattest.OpenID(time.Now().String())}
+	if err := e.db.Create(&donor).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < consents; i++ {
+		if err := e.service.RecordConsent(donor.ID, map[string]string{testTemplateID: SubscribeAccept}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wish := models.Wish{ChildName: "小明", Content: "一套绘本", Reason: "喜欢看书"}
+	if err := e.db.Create(&wish).Error; err != nil {
+		t.Fatal(err)
+	}
+	record := models.WishRecord{WishID: wish.ID, Wish: &wish, DonorID: donor.ID, Donor: &donor, Status: models.StatusCompleted}
+	if err := e.db.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := e.service.NotifyRecordStatus(&record); err != nil {
+		t.Fatal(err)
+	}
+
+	var delivery models.NotificationDelivery
+	if err := e.db.Where("record_id = ?", record.ID).First(&delivery).Error; err != nil {
+		t.Fatal(err)
+	}
+	return delivery
+}
+
+func (e *notificationTestEnv) reload(t *testing.T, delivery models.NotificationDelivery) models.NotificationDelivery {
+	t.Helper()
+	if err := e.db.First(&delivery, delivery.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return delivery
+}
+
+func TestProcessDueSendsMessage(t *testing.T) {
+	env := newNotificationTestEnv(t)
+	delivery := env.completeRecord(t, 1)
+
+	if processed := env.service.ProcessDue(context.Background()); processed != 1 {
+		t.Fatalf("应处理 1 条，实际 %d", processed)
+	}
+	delivery = env.reload(t, delivery)
+	if delivery.State != models.NotificationSent || delivery.Attempts != 1 || delivery.SentAt == nil {
+		t.Fatalf("消息应已发送: %+v", delivery)
+	}
+	messages := env.fake.Messages()
+	if len(messages) != 1 || messages[0].TemplateID != testTemplateID || messages[0].Data["phrase2"].Value != "孩子已签收" {
+		t.Fatalf("模拟服务收到的消息不正确: %+v", messages)
+	}
+
+	// 已发送的消息不会再次发送
+	if processed := env.service.ProcessDue(context.Background()); processed != 0 {
+		t.Fatalf("不应重复发送，处理了 %d 条", processed)
+	}
+}
+
+func TestNotifyWithoutConsentIsSkipped(t *testing.T) {
+	env := newNotificationTestEnv(t)
+	delivery := env.completeRecord(t, 0)
+
+	if delivery.State != models.NotificationSkipped {
+		t.Fatalf("没有授权时应记为 skipped，实际 %s", delivery.State)
+	}
+	env.service.ProcessDue(context.Background())
+	if len(env.fake.Messages()) != 0 {
+		t.Fatal("没有授权时不应发送")
+	}
+}
+
+func TestProcessDueRetriesTemporaryErrors(t *testing.T) {
+	env := newNotificationTestEnv(t)
+	delivery := env.completeRecord(t, 1)
+
+	env.fake.FailNext(sendPath, wechat.ErrSystemBusy.Code)
+	env.service.ProcessDue(context.Background())
+	delivery = env.reload(t, delivery)
+	if delivery.State != models.NotificationPending || delivery.Attempts != 1 {
+		t.Fatalf("临时错误后应等待重试: %+v", delivery)
+	}
+	if want := env.now.Add(notificationBackoff[0]).Unix(); delivery.NextAttemptAt != want {
+		t.Fatalf("下次发送时间应为 %d，实际 %d", want, delivery.NextAttemptAt)
+	}
+
+	// 未到重试时间时不发送
+	if processed := env.service.ProcessDue(context.Background()); processed != 0 {
+		t.Fatalf("未到重试时间，不应处理，实际 %d 条", processed)
+	}
+
+	env.now = env.now.Add(notificationBackoff[0])
+	env.service.ProcessDue(context.Background())
+	delivery = env.reload(t, delivery)
+	if delivery.State != models.NotificationSent || delivery.Attempts != 2 {
+		t.Fatalf("重试后应发送成功: %+v", delivery)
+	}
+}
+
+func TestProcessDueStopsRetryingAfterMaxAttempts(t *testing.T) {
+	env := newNotificationTestEnv(t)
+	delivery := env.completeRecord(t, 1)
+
+	env.fake.FailNext(sendPath, wechat.ErrSystemBusy.Code, wechat.ErrSystemBusy.Code, wechat.ErrSystemBusy.Code)
+	for i := 0; i < 3; i++ {
+		env.service.ProcessDue(context.Background())
+		env.now = env.now.Add(time.Hour)
+	}
+	delivery = env.reload(t, delivery)
+	if delivery.State != models.NotificationFailed || delivery.Attempts != 3 {
+		t.Fatalf("重试次数用完后应记为 failed: %+v", delivery)
+	}
+}
+
+func TestProcessDueRefusedClearsConsent(t *testing.T) {
+	env := newNotificationTestEnv(t)
+	delivery := env.completeRecord(t, 3)
+
+	env.fake.FailNext(sendPath, wechat.ErrSubscribeRefused.Code)
+	env.service.ProcessDue(context.Background())
+	delivery = env.reload(t, delivery)
+	if delivery.State != models.NotificationFailed || delivery.Attempts != 1 {
+		t.Fatalf("用户拒收不应重试: %+v", delivery)
+	}
+	var consent models.SubscriptionConsent
+	if err := env.db.Where("user_id = ?", delivery.UserID).First(&consent).Error; err != nil {
+		t.Fatal(err)
+	}
+	if consent.Remaining != 0 {
+		t.Fatalf("用户拒收后应清空剩余授权，实际 %d", consent.Remaining)
+	}
+}
+
+func TestProcessDueRefreshesExpiredAccessToken(t *testing.T) {
+	env := newNotificationTestEnv(t)
+	first := env.completeRecord(t, 1)
+	env.service.ProcessDue(context.Background())
+
+	env.fake.ExpireAccessTokens()
+	second := env.completeRecord(t, 1)
+	env.service.ProcessDue(context.Background())
+
+	for _, delivery := range []models.NotificationDelivery{first, second} {
+		if delivery = env.reload(t, delivery); delivery.State != models.NotificationSent {
+			t.Fatalf("消息应已发送: %+v", delivery)
+		}
+	}
+	if got := env.fake.Requests("/cgi-bin/token"); got != 2 {
+		t.Fatalf("access_token 过期后应重新获取一次，共获取 %d 次", got)
+	}
+}
+
+func TestProcessDueStopsOnClaimError(t *testing.T) {
+	env := newNotificationTestEnv(t)
+	env.completeRecord(t, 1)
+
+	// 查询正常但无法更新，模拟数据库只读或锁等待超时
+	err := env.db.Callback().Update().Before("gorm:update").Register("test:fail_update", func(tx *gorm.DB) {
+		if tx.Statement.Table == "notification_deliveries" {
+			tx.AddError(errors.New("database is locked"))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan int, 1)
+	go func() {
+		done <- env.service.ProcessDue(context.Background())
+	}()
+	select {
+	case processed := <-done:
+		if processed != 0 {
+			t.Fatalf("占用失败时不应处理消息，实际 %d 条", processed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("占用消息持续失败时 ProcessDue 没有返回")
+	}
+	if len(env.fake.Messages()) != 0 {
+		t.Fatal("占用失败时不应发送")
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const (
+	MinPasswordLength = 10
+	// bcrypt 只使用前 72 个字节，更长的部分会被静默忽略
+	MaxPasswordBytes = 72
+)
+
+var ErrWeakPassword = errors.New("密码不符合安全要求")
+
+// PasswordPolicyError 描述密码不满足的具体规则
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return ErrWeakPassword.Error() + ": " + e.Reason
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// ValidatePassword 检查管理员密码是否满足最低安全要求：
+// 至少 10 个字符、不超过 72 字节、同时包含字母和数字、不包含用户名
+func ValidatePassword(username, password string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return &PasswordPolicyError{Reason: "长度不能少于10个字符"}
+	}
+	if len(password) > MaxPasswordBytes {
+		return &PasswordPolicyError{Reason: "长度不能超过72个字节"}
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return &PasswordPolicyError{Reason: "必须同时包含字母和数字"}
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return &PasswordPolicyError{Reason: "不能包含用户名"}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidProfile = errors.New("个人资料不符合要求")
+
+// ProfileValidationError 描述不符合要求的资料字段
+type ProfileValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ProfileValidationError) Error() string {
+	return ErrInvalidProfile.Error() + ": " + e.Field + e.Reason
+}
+
+func (e *ProfileValidationError) Unwrap() error {
+	return ErrInvalidProfile
+}
+
+const (
+	maxNicknameLength   = 32
+	maxDepartmentLength = 64
+	maxAvatarURLLength  = 512
+)
+
+// 中国大陆手机号或带区号的固定电话
+var contactPhonePattern = regexp.MustCompile(`^(?:(?:\+?86)?1[3-9]\d{9}|0\d{2,3}-?\d{7,8})$`)
+
+// ProfileUpdate 个人资料更新内容，nil 表示不修改该字段
+type ProfileUpdate struct {
+	Nickname     *string
+	AvatarURL    *string
+	Department   *string
+	ContactPhone *string
+}
+
+// normalize 去除首尾空白并校验各字段，返回需要写入数据库的列
+func (u ProfileUpdate) normalize() (map[string]any, error) {
+	updates := map[string]any{}
+
+	if u.Nickname != nil {
+		nickname := strings.TrimSpace(*u.Nickname)
+		if utf8.RuneCountInString(nickname) > maxNicknameLength {
+			return nil, &ProfileValidationError{Field: "昵称", Reason: "不能超过32个字符"}
+		}
+		updates["nickname"] = nickname
+	}
+
+	if u.AvatarURL != nil {
+		avatarURL := strings.TrimSpace(*u.AvatarURL)
+		if avatarURL != "" {
+			parsed, err := url.Parse(avatarURL)
+			if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+				return nil, &ProfileValidationError{Field: "头像", Reason: "必须是有效的http或https链接"}
+			}
+			if len(avatarURL) > maxAvatarURLLength {
+				return nil, &ProfileValidationError{Field: "头像", Reason: "链接过长"}
+			}
+		}
+		updates["avatar_url"] = avatarURL
+	}
+
+	if u.Department != nil {
+		department := strings.TrimSpace(*u.Department)
+		if utf8.RuneCountInString(department) > maxDepartmentLength {
+			return nil, &ProfileValidationError{Field: "部门", Reason: "不能超过64个字符"}
+		}
+		updates["department"] = department
+	}
+
+	if u.ContactPhone != nil {
+		phone := strings.TrimSpace(*u.ContactPhone)
+		if phone != "" && !contactPhonePattern.MatchString(phone) {
+			return nil, &ProfileValidationError{Field: "联系电话", Reason: "格式不正确"}
+		}
+		updates["contact_phone"] = phone
+	}
+
+	return updates, nil
+}
+
+// syncAvatar 头像有变化时更新对上传文件的引用
+func syncAvatar(tx *gorm.DB, tracker *UploadTracker, refType string, refID uint, actor UploadOwner, updates map[string]any) error {
+	avatarURL, ok := updates["avatar_url"].(string)
+	if !ok {
+		return nil
+	}
+	return tracker.SyncReferences(tx, refType, refID, actor, map[string][]string{
+		"avatarUrl": {avatarURL},
+	})
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+
+	"wishes/models"
+)
+
+// recordStatuses 输出指标的记录状态，没有记录的状态也输出 0，便于告警规则引用
+var recordStatuses = []models.WishRecordStatus{
+	models.StatusPendingShipment,
+	models.StatusPendingConfirmation,
+	models.StatusConfirmed,
+	models.StatusAwaitingReceipt,
+	models.StatusCompleted,
+	models.StatusGiftReturned,
+	models.StatusCancelled,
+}
+
+// RecordStatusCollector 在抓取指标时统计各状态的认领记录数
+type RecordStatusCollector struct {
+	db   *gorm.DB
+	desc *prometheus.Desc
+}
+
+// NewRecordStatusCollector 创建认领记录状态指标，需注册到 metrics.Registry
+func NewRecordStatusCollector(db *gorm.DB) *RecordStatusCollector {
+	return &RecordStatusCollector{
+		db: db,
+		desc: prometheus.NewDesc(
+			"wishes_records",
+			"按状态统计的认领记录数",
+			[]string{"status"}, nil,
+		),
+	}
+}
+
+func (c *RecordStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *RecordStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var rows []struct {
+		Status models.WishRecordStatus
+		Count  int64
+	}
+	err := c.db.WithContext(ctx).Model(&models.WishRecord{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	counts := make(map[models.WishRecordStatus]int64, len(recordStatuses))
+	for _, status := range recordStatuses {
+		counts[status] = 0
+	}
+	for _, row := range rows {
+		counts[row.Status] += row.Count
+	}
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), string(status))
+	}
+}
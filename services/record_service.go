@@ -1,20 +1,27 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"time"
+	"wishes/metrics"
 	"wishes/models"
 
 	"gorm.io/gorm"
 )
 
+// ErrRecordNotShippable 记录不属于该捐赠者或已不是待寄出状态
+var ErrRecordNotShippable = errors.New("只能为自己待寄出的认领记录填写寄送单号")
+
 type RecordService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	tracker *UploadTracker
 }
 
-func NewRecordService(db *gorm.DB) *RecordService {
+func NewRecordService(db *gorm.DB, tracker *UploadTracker) *RecordService {
 	return &RecordService{
-		db: db,
+		db:      db,
+		tracker: tracker,
 	}
 }
 
@@ -72,7 +79,18 @@ func (s *RecordService) GetRecordsByUserID(userID uint, pageIndex, pageSize int,
 }
 
 func (s *RecordService) CreateRecordWithWish(record *models.WishRecord, wish *models.Wish) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 冷静期结束后会清除捐赠者的联系方式，礼物将无法寄送
+		var pending int64
+		if err := tx.Model(&models.AccountDeletionRequest{}).
+			Where("user_id = ? AND status = ?", record.DonorID, models.DeletionPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrClaimDeletionPending
+		}
+
 		if err := tx.Create(record).Error; err != nil {
 			return err
 		}
@@ -82,6 +100,10 @@ func (s *RecordService) CreateRecordWithWish(record *models.WishRecord, wish *mo
 		}
 		return nil
 	})
+	if err == nil {
+		metrics.WishesClaimed.Inc()
+	}
+	return err
 }
 
 func (s *RecordService) GetRecordByIDWithoutRecursion(id uint) (*models.WishRecord, error) {
@@ -95,12 +117,14 @@ func (s *RecordService) GetRecordByIDWithoutRecursion(id uint) (*models.WishReco
 	return &record, nil
 }
 
-func (s *RecordService) UpdateRecordStatus(recordID uint, newStatus models.WishRecordStatus, params map[string]any) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
+func (s *RecordService) UpdateRecordStatus(recordID uint, newStatus models.WishRecordStatus, params map[string]any, actor UploadOwner) error {
+	var oldStatus models.WishRecordStatus
+	err := s.db.Transaction(func(tx *gorm.DB) error {
 		var record models.WishRecord
 		if err := tx.First(&record, recordID).Error; err != nil {
 			return err
 		}
+		oldStatus = record.Status
 
 		// // 检查状态转换是否合法
 		// if !isValidStatusTransition(record.Status, newStatus) {
@@ -183,8 +207,44 @@ func (s *RecordService) UpdateRecordStatus(recordID uint, newStatus models.WishR
 			record.CancellationTime = &now
 		}
 
-		return tx.Save(&record).Error
+		if err := tx.Save(&record).Error; err != nil {
+			return err
+		}
+		// 记录引用的照片，被替换的照片由清理任务删除
+		return s.tracker.SyncReferences(tx, RefTypeRecord, record.ID, actor, map[string][]string{
+			"confirmationPhotos": photoURLs(record.ConfirmationPhotos),
+			"receiptPhotos":      photoURLs(record.ReceiptPhotos),
+			"platformGiftPhotos": photoURLs(record.PlatformGiftPhotos),
+			"ownerGiftPhotos":    photoURLs(record.OwnerGiftPhotos),
+		})
 	})
+	if err == nil && newStatus == models.StatusCompleted && oldStatus != models.StatusCompleted {
+		metrics.RecordsCompleted.Inc()
+	}
+	return err
+}
+
+// ShipRecord 捐赠者为自己待寄出的记录填写寄送单号，记录改为待确认。
+// 归属和状态在同一条更新语句中检查，记录已被改为其他状态或不属于该捐赠者时返回 ErrRecordNotShippable
+func (s *RecordService) ShipRecord(recordID, donorID uint, shippingNumber string) error {
+	if shippingNumber == "" {
+		return fmt.Errorf("转换为待确认状态需要提供寄送单号")
+	}
+	now := time.Now().Unix()
+	result := s.db.Model(&models.WishRecord{}).
+		Where("id = ? AND donor_id = ? AND status = ?", recordID, donorID, models.StatusPendingShipment).
+		Updates(map[string]any{
+			"status":          models.StatusPendingConfirmation,
+			"shipping_number": shippingNumber,
+			"shipping_time":   now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotShippable
+	}
+	return nil
 }
 
 // UpdateShippingInfo 更新收货信息
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"wishes/models"
+	"wishes/storage"
+)
+
+func TestShipRecordChecksOwnerAndStatusAtomically(t *testing.T) {
+	db, cfg := newTestDB(t)
+	service := NewRecordService(db, NewUploadTracker(db, storage.NewMemoryStorage(""), cfg))
+	record := models.WishRecord{DonorID: 1, Status: models.StatusPendingShipment}
+	if err := db.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.ShipRecord(record.ID, 2, "SF000"); !errors.Is(err, ErrRecordNotShippable) {
+		t.Fatalf("其他捐赠者的记录应返回 ErrRecordNotShippable，实际 %v", err)
+	}
+
+	// 同时提交两次，只有一次能从待寄出改为待确认
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, number := range []string{"SF001", "SF002"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = service.ShipRecord(record.ID, 1, number)
+		}()
+	}
+	wg.Wait()
+	if (errs[0] == nil) == (errs[1] == nil) {
+		t.Fatalf("应恰好一次成功: %v", errs)
+	}
+
+	if err := db.First(&record, record.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != models.StatusPendingConfirmation || record.ShippingNumber == nil || record.ShippingTime == nil {
+		t.Fatalf("寄送信息未保存: %+v", record)
+	}
+	if err := service.ShipRecord(record.ID, 1, "SF003"); !errors.Is(err, ErrRecordNotShippable) {
+		t.Fatalf("已寄出的记录不能再次填写，实际 %v", err)
+	}
+}
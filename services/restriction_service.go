@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wishes/middleware"
+	"wishes/models"
+)
+
+var (
+	ErrInvalidRestriction  = errors.New("无效的限制")
+	ErrRestrictionNotFound = errors.New("该用户没有生效中的限制")
+	// ErrUserDeactivated 用户已注销或不存在，旧令牌不再可用
+	ErrUserDeactivated = errors.New("账号已注销")
+)
+
+type RestrictionService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewRestrictionService(db *gorm.DB) *RestrictionService {
+	return &RestrictionService{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// SetClock 替换当前时间的来源，便于模拟限制到期
+func (s *RestrictionService) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// active 未解除且未到期的限制
+func (s *RestrictionService) active(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.UserRestriction{}).
+		Where("user_id = ? AND lifted_at IS NULL", userID).
+		Where("expires_at = 0 OR expires_at > ?", s.now().Unix())
+}
+
+// GetActiveRestriction 获取用户当前生效的限制，没有时返回 nil
+func (s *RestrictionService) GetActiveRestriction(userID uint) (*models.UserRestriction, error) {
+	var restriction models.UserRestriction
+	if err := s.active(s.db, userID).Order("id DESC").First(&restriction).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &restriction, nil
+}
+
+// CheckUserAccess 每次请求时校验小程序用户的状态，已注销的用户返回 ErrUserDeactivated，
+// 否则返回是否为志愿者和当前生效的限制
+func (s *RestrictionService) CheckUserAccess(userID uint) (*middleware.UserAccess, error) {
+	var user models.User
+	if err := s.db.Select("id", "is_admin", "deleted_at").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserDeactivated
+		}
+		return nil, err
+	}
+	if user.DeletedAt != 0 {
+		return nil, ErrUserDeactivated
+	}
+	restriction, err := s.GetActiveRestriction(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &middleware.UserAccess{IsAdmin: user.IsAdmin, Restriction: restriction}, nil
+}
+
+// Restrict 限制用户，替换该用户已有的限制。expiresAt 为 0 表示永久
+func (s *RestrictionService) Restrict(userID uint, level models.RestrictionLevel, reason string, expiresAt int64, adminID uint) (*models.UserRestriction, error) {
+	if level != models.RestrictionBan && level != models.RestrictionBrowseOnly {
+		return nil, ErrInvalidRestriction
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("%w: 请填写限制原因", ErrInvalidRestriction)
+	}
+	if expiresAt != 0 && expiresAt <= s.now().Unix() {
+		return nil, fmt.Errorf("%w: 到期时间必须晚于当前时间", ErrInvalidRestriction)
+	}
+
+	restriction := models.UserRestriction{
+		UserID:    userID,
+		Level:     level,
+		Reason:    reason,
+		ExpiresAt: expiresAt,
+		CreatedBy: adminID,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.User{}, userID).Error; err != nil {
+			return err
+		}
+		if err := s.lift(tx, userID, adminID); err != nil {
+			return err
+		}
+		return tx.Create(&restriction).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &restriction, nil
+}
+
+// Lift 提前解除用户当前生效的限制
+func (s *RestrictionService) Lift(userID uint, adminID uint) error {
+	var lifted int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.active(tx, userID).Count(&lifted).Error; err != nil {
+			return err
+		}
+		return s.lift(tx, userID, adminID)
+	})
+	if err != nil {
+		return err
+	}
+	if lifted == 0 {
+		return ErrRestrictionNotFound
+	}
+	return nil
+}
+
+func (s *RestrictionService) lift(tx *gorm.DB, userID uint, adminID uint) error {
+	return s.active(tx, userID).Updates(map[string]any{
+		"lifted_at": s.now().Unix(),
+		"lifted_by": adminID,
+	}).Error
+}
+
+// GetRestrictions 获取用户的全部限制记录，包括已解除和已到期的
+func (s *RestrictionService) GetRestrictions(userID uint) ([]models.UserRestriction, error) {
+	var restrictions []models.UserRestriction
+	if err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&restrictions).Error; err != nil {
+		return nil, err
+	}
+	return restrictions, nil
+}
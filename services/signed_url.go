@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"wishes/imaging"
+	"wishes/models"
+	"wishes/storage"
+)
+
+// SignURL 把私有文件的地址替换为有时效的签名地址，公开文件和外部地址原样返回。
+// 客户端提交的照片地址可能带有已过期的签名，每次返回给客户端前都按文件路径重新签名
+func (s *StorageService) SignURL(ctx context.Context, fileURL string) string {
+	key, ok := storage.KeyFromURL(s.storage, fileURL)
+	if !ok || !storage.IsPrivateKey(key) {
+		return fileURL
+	}
+	signer, ok := s.storage.(storage.ReadSigner)
+	if !ok {
+		return s.storage.URL(key)
+	}
+	signed, err := signer.SignURL(ctx, key, s.signedURLTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "签发文件的访问地址失败", slog.String("key", key), slog.String("error", err.Error()))
+		return s.storage.URL(key)
+	}
+	return signed
+}
+
+// signPhotos 为照片字段中的每个地址签名，保持原来的 JSON 数组或逗号分隔格式
+func (s *StorageService) signPhotos(ctx context.Context, value *string) *string {
+	return mapPhotos(value, func(fileURL string) string {
+		return s.SignURL(ctx, fileURL)
+	})
+}
+
+// mapPhotos 替换照片字段中的每个地址，fn 返回空字符串时去掉该地址，保持原来的 JSON 数组或逗号分隔格式
+func mapPhotos(value *string, fn func(fileURL string) string) *string {
+	all := photoURLs(value)
+	if len(all) == 0 {
+		return value
+	}
+	urls := make([]string, 0, len(all))
+	for _, fileURL := range all {
+		if mapped := fn(fileURL); mapped != "" {
+			urls = append(urls, mapped)
+		}
+	}
+
+	var result string
+	if strings.HasPrefix(strings.TrimSpace(*value), "[") {
+		data, _ := json.Marshal(urls)
+		result = string(data)
+	} else {
+		result = strings.Join(urls, ",")
+	}
+	return &result
+}
+
+// SignWish 为心愿照片以及当前认领记录中的照片签名
+func (s *StorageService) SignWish(ctx context.Context, wish *models.Wish) {
+	if wish == nil {
+		return
+	}
+	wish.PhotoURL = s.signPhotos(ctx, wish.PhotoURL)
+	if wish.ActiveRecord != nil {
+		s.signRecordPhotos(ctx, wish.ActiveRecord)
+	}
+}
+
+// PublicWish 把心愿中的私有照片替换为可公开访问的缩略图，用于未登录或无权查看原图的调用方。
+// 没有公开缩略图的私有照片不返回，公开照片和外部地址原样返回
+func (s *StorageService) PublicWish(wish *models.Wish) {
+	if wish == nil {
+		return
+	}
+	wish.PhotoURL = mapPhotos(wish.PhotoURL, func(fileURL string) string {
+		key, ok := storage.KeyFromURL(s.storage, fileURL)
+		if !ok || !storage.IsPrivateKey(key) {
+			return fileURL
+		}
+		var thumbnail models.Upload
+		err := s.db.Where("group_key = (?) AND variant = ?",
+			s.db.Model(&models.Upload{}).Select("group_key").Where("key = ?", key), imaging.VariantThumbnail).
+			Limit(1).Find(&thumbnail).Error
+		if err != nil || thumbnail.ID == 0 || storage.IsPrivateKey(thumbnail.Key) {
+			return ""
+		}
+		return s.storage.URL(thumbnail.Key)
+	})
+}
+
+// SignRecord 为认领记录中的照片以及所属心愿的照片签名
+func (s *StorageService) SignRecord(ctx context.Context, record *models.WishRecord) {
+	if record == nil {
+		return
+	}
+	s.signRecordPhotos(ctx, record)
+	if record.Wish != nil {
+		record.Wish.PhotoURL = s.signPhotos(ctx, record.Wish.PhotoURL)
+	}
+}
+
+func (s *StorageService) signRecordPhotos(ctx context.Context, record *models.WishRecord) {
+	record.ConfirmationPhotos = s.signPhotos(ctx, record.ConfirmationPhotos)
+	record.ReceiptPhotos = s.signPhotos(ctx, record.ReceiptPhotos)
+	record.PlatformGiftPhotos = s.signPhotos(ctx, record.PlatformGiftPhotos)
+	record.OwnerGiftPhotos = s.signPhotos(ctx, record.OwnerGiftPhotos)
+}
+
+// SignPhotos 为照片字段签名，用于组装后的响应数据
+func (s *StorageService) SignPhotos(ctx context.Context, value string) string {
+	return *s.signPhotos(ctx, &value)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wishes/models"
+	"wishes/storage"
+)
+
+func newLocalStorageService(t *testing.T) (*StorageService, http.Handler) {
+	t.Helper()
+	local, err := storage.NewLocalStorage(t.TempDir(), "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local.SetSigningKey([]byte("0123456789abcdef0123456789abcdef"))
+	service, _ := newTestStorageService(t, local)
+	return service, http.StripPrefix("/uploads", local)
+}
+
+// fetch 通过本地存储的文件服务访问地址，返回状态码
+func fetch(handler http.Handler, fileURL string) int {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", fileURL, nil))
+	return w.Code
+}
+
+func TestWishPhotoURLs(t *testing.T) {
+	service, handler := newLocalStorageService(t)
+	admin := UploadOwner{UserID: 1, UserType: "admin", Role: models.RoleAdmin}
+	images, err := service.UploadImage(admin, multipartFile(t, "photo.png", "image/png", testPNG(t, 64, 48, 1)), PurposeWishPhoto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, thumbnail := images[0], images[2]
+	if !storage.IsPrivateKey(original.Key) || storage.IsPrivateKey(thumbnail.Key) {
+		t.Fatalf("心愿照片的原图应为私有、缩略图应公开: %s, %s", original.Key, thumbnail.Key)
+	}
+
+	// 数据库中保存的是不带签名的地址
+	stored := service.storage.URL(original.Key)
+	if code := fetch(handler, stored); code != http.StatusForbidden {
+		t.Fatalf("未签名的私有地址应拒绝访问，实际 %d", code)
+	}
+
+	// 有权查看原图的调用方拿到可访问的签名地址
+	wish := &models.Wish{PhotoURL: &stored}
+	service.SignWish(context.Background(), wish)
+	if *wish.PhotoURL == stored || fetch(handler, *wish.PhotoURL) != http.StatusOK {
+		t.Fatalf("签名后的原图地址应可访问: %s", *wish.PhotoURL)
+	}
+
+	// 匿名调用方只拿到公开缩略图
+	wish = &models.Wish{PhotoURL: &stored}
+	service.PublicWish(wish)
+	if *wish.PhotoURL != thumbnail.URL {
+		t.Fatalf("应替换为公开缩略图 %s，实际 %s", thumbnail.URL, *wish.PhotoURL)
+	}
+	if strings.Contains(*wish.PhotoURL, "?") || fetch(handler, *wish.PhotoURL) != http.StatusOK {
+		t.Fatalf("公开缩略图应无需签名即可访问: %s", *wish.PhotoURL)
+	}
+}
+
+func TestPublicWishDropsPrivatePhotosWithoutPublicThumbnail(t *testing.T) {
+	service, _ := newLocalStorageService(t)
+	donor := UploadOwner{UserID: 1, UserType: "user", Role: models.RoleDonor}
+	images, err := service.UploadImage(donor, multipartFile(t, "proof.png", "image/png", testPNG(t, 64, 48, 2)), PurposeShippingProof)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	photos := `["https://example.com/child.jpg","` + service.storage.URL(images[0].Key) + `","` +
+		service.storage.URL(storage.PrivatePrefix+"wishes/admin/1/unknown.jpg") + `"]`
+	wish := &models.Wish{PhotoURL: &photos}
+	service.PublicWish(wish)
+	// 外部地址原样返回，没有公开缩略图或未登记的私有文件不返回
+	if want := `["https://example.com/child.jpg"]`; *wish.PhotoURL != want {
+		t.Fatalf("应为 %s，实际 %s", want, *wish.PhotoURL)
+	}
+}
@@ -1,109 +1,292 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
-	"net/http"
-	"net/url"
-	"path/filepath"
 	"time"
-	"wishes/config"
 
-	"github.com/google/uuid"
-	"github.com/tencentyun/cos-go-sdk-v5"
+	"gorm.io/gorm"
+
+	"wishes/config"
+	"wishes/imaging"
+	"wishes/metrics"
+	"wishes/models"
+	"wishes/storage"
 )
 
-// StorageService 封装了对腾讯云对象存储的操作
-type StorageService struct {
-	client  *cos.Client
-	config  *config.Config
-	baseURL string
-	bucket  string
+var ErrInvalidUpload = errors.New("不支持的文件")
+
+// imageExtensions 允许上传的图片类型及直传时使用的扩展名
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
 }
 
-// NewStorageService 创建腾讯云存储服务实例
-func NewStorageService(config *config.Config) *StorageService {
-	u, _ := url.Parse(fmt.Sprintf("https://%s.cos.%s.myqcloud.com", config.COSBucketName, config.COSRegion))
-	b := &cos.BaseURL{BucketURL: u}
+// StorageService 负责上传文件的命名和保存，具体存储位置由 storage.Storage 决定
+type StorageService struct {
+	db            *gorm.DB
+	storage       storage.Storage
+	tracker       *UploadTracker
+	imageOptions  imaging.Options
+	defaultLimits imaging.Limits
+	uploadLimits  map[string]config.UploadLimit
+	ticketTTL     time.Duration
+	signedURLTTL  time.Duration
+	now           func() time.Time
+}
 
-	client := cos.NewClient(b, &http.Client{
-		Transport: &cos.AuthorizationTransport{
-			SecretID:  config.COSSecretID,
-			SecretKey: config.COSSecretKey,
+// NewStorageService 创建存储服务实例，tracker 为 nil 时不登记上传的文件
+func NewStorageService(db *gorm.DB, store storage.Storage, tracker *UploadTracker, cfg *config.Config) *StorageService {
+	return &StorageService{
+		db:      db,
+		storage: store,
+		tracker: tracker,
+		imageOptions: imaging.Options{
+			DisplayMaxSize: cfg.ImageDisplayMaxSize,
+			ThumbnailSize:  cfg.ImageThumbnailSize,
+			JPEGQuality:    cfg.ImageJPEGQuality,
+		},
+		defaultLimits: imaging.Limits{
+			MaxBytes:     cfg.UploadMaxImageSize,
+			MaxDimension: cfg.ImageMaxDimension,
+			MaxPixels:    cfg.ImageMaxPixels,
 		},
-	})
+		uploadLimits: cfg.UploadLimits,
+		ticketTTL:    cfg.UploadTicketTTL,
+		signedURLTTL: cfg.StorageSignedURLTTL,
+		now:          time.Now,
+	}
+}
 
-	return &StorageService{
-		client:  client,
-		config:  config,
-		baseURL: config.COSBaseURL,
-		bucket:  config.COSBucketName,
+// SetClock 替换当前时间的来源，便于模拟上传凭证过期
+func (s *StorageService) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// limitsFor 返回上传用途的限制，优先使用 UPLOAD_LIMITS 中的配置，其次是用途自身的限制，最后是全局默认值
+func (s *StorageService) limitsFor(purpose UploadPurpose) imaging.Limits {
+	limits := s.defaultLimits
+	if purpose.MaxBytes > 0 {
+		limits.MaxBytes = purpose.MaxBytes
 	}
+	if purpose.MaxDimension > 0 {
+		limits.MaxDimension = purpose.MaxDimension
+	}
+	if override, ok := s.uploadLimits[purpose.Name]; ok {
+		if override.MaxBytes > 0 {
+			limits.MaxBytes = override.MaxBytes
+		}
+		if override.MaxDimension > 0 {
+			limits.MaxDimension = override.MaxDimension
+		}
+	}
+	return limits
+}
+
+// ValidateImage 检查客户端声明的图片类型和大小，只用于提前拒绝，文件内容仍需检查
+func (s *StorageService) ValidateImage(purpose UploadPurpose, contentType string, size int64) error {
+	if _, ok := imageExtensions[contentType]; !ok {
+		return fmt.Errorf("%w: 只支持上传JPG、PNG、GIF或WEBP格式的图片", ErrInvalidUpload)
+	}
+	if size <= 0 {
+		return fmt.Errorf("%w: 图片不能为空", ErrInvalidUpload)
+	}
+	if limits := s.limitsFor(purpose); size > limits.MaxBytes {
+		return fmt.Errorf("%w: 图片大小不能超过%dMB", ErrInvalidUpload, limits.MaxBytes/1024/1024)
+	}
+	return nil
+}
+
+// inspectImage 根据文件内容检查图片，不信任客户端声明的类型
+func (s *StorageService) inspectImage(purpose UploadPurpose, data []byte) (*imaging.Info, error) {
+	info, err := imaging.Inspect(data, s.limitsFor(purpose))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+	}
+	return info, nil
+}
+
+// UploadedImage 上传后的一种图片规格
+type UploadedImage struct {
+	Name        string `json:"-"`
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
 }
 
-// UploadImage 上传图片到腾讯云COS
+// UploadImage 根据文件内容识别并检查图片，重新编码以去除 EXIF 等元数据并自动旋转，
+// 然后把原图、展示图和缩略图上传到存储后端。文件类型和扩展名都由内容决定，与客户端声明无关。
+// 同一用途下已有内容相同的图片时直接返回已有的文件，不再保存副本，私有用途只复用同一上传者的文件
 // 参数:
+// - owner: 上传者，角色必须允许上传该用途的文件
 // - file: 要上传的文件
-// - directory: 存储目录（例如：'images/avatar'）
+// - purposeName: 上传用途（例如：'avatar'），决定存储路径和限制
 // 返回:
-// - fileURL: 上传成功后的文件访问URL
+// - images: 依次为原图、展示图和缩略图
 // - error: 错误信息
-func (s *StorageService) UploadImage(file *multipart.FileHeader, directory string) (string, error) {
+func (s *StorageService) UploadImage(owner UploadOwner, file *multipart.FileHeader, purposeName string) ([]UploadedImage, error) {
+	purpose, err := LookupUploadPurpose(purposeName)
+	if err != nil {
+		return nil, err
+	}
+	if err := purpose.Authorize(owner); err != nil {
+		return nil, err
+	}
+
 	// 打开文件
 	src, err := file.Open()
 	if err != nil {
-		return "", fmt.Errorf("无法打开文件: %w", err)
+		return nil, fmt.Errorf("无法打开文件: %w", err)
 	}
 	defer src.Close()
 
-	// 生成唯一文件名
-	ext := filepath.Ext(file.Filename)
-	uniqueID := uuid.New().String()
-	fileName := fmt.Sprintf("%s%s", uniqueID, ext)
-
-	// 组合存储路径
-	objectKey := filepath.Join(directory, fileName)
+	limits := s.limitsFor(purpose)
 
-	// 设置上传选项
-	opt := &cos.ObjectPutOptions{
-		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
-			ContentType: file.Header.Get("Content-Type"),
-		},
+	// 多读一个字节，用于发现超过限制的文件
+	data, err := io.ReadAll(io.LimitReader(src, limits.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取文件: %w", err)
 	}
 
-	// 执行上传
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if int64(len(data)) <= limits.MaxBytes {
+		images, err := s.findDuplicateImages(purpose, owner, hash)
+		if err != nil {
+			return nil, err
+		}
+		if images != nil {
+			metrics.Uploads.WithLabelValues(purpose.Name, "true").Inc()
+			return images, nil
+		}
+	}
 
-	_, err = s.client.Object.Put(ctx, objectKey, src, opt)
+	variants, err := s.processImage(purpose, data)
 	if err != nil {
-		return "", fmt.Errorf("上传文件到COS失败: %w", err)
+		return nil, err
 	}
-
-	// 如果设置了自定义域名（通过baseURL），则使用自定义域名构建URL
-	var fileURL string
-	if s.baseURL != "" {
-		fileURL = fmt.Sprintf("%s/%s", s.baseURL, objectKey)
-	} else {
-		fileURL = fmt.Sprintf("https://%s.cos.%s.myqcloud.com/%s",
-			s.config.COSBucketName,
-			s.config.COSRegion,
-			objectKey)
+	images, err := s.storeVariants(context.Background(), purpose, owner, variants, hash)
+	if err != nil {
+		return nil, err
 	}
 
-	return fileURL, nil
+	metrics.Uploads.WithLabelValues(purpose.Name, "false").Inc()
+	return images, nil
 }
 
-// DeleteImage 从腾讯云COS删除图片
-func (s *StorageService) DeleteImage(objectKey string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// findDuplicateImages 查找同一用途下内容相同的已有图片，没有或者规格不全时返回 nil
+func (s *StorageService) findDuplicateImages(purpose UploadPurpose, owner UploadOwner, hash string) ([]UploadedImage, error) {
+	group, err := s.tracker.FindDuplicate(purpose, owner, hash)
+	if err != nil {
+		return nil, fmt.Errorf("查找重复图片失败: %w", err)
+	}
+	// 早期直传的图片只有原图，不能用于需要各个规格的上传
+	if images := s.uploadedImages(group); len(images) == len(variantSuffix) {
+		return images, nil
+	}
+	return nil, nil
+}
 
-	_, err := s.client.Object.Delete(ctx, objectKey)
+// processImage 检查图片并重新编码为原图、展示图和缩略图，去除 EXIF 等元数据
+func (s *StorageService) processImage(purpose UploadPurpose, data []byte) ([]imaging.Variant, error) {
+	opts := s.imageOptions
+	opts.Limits = s.limitsFor(purpose)
+	variants, err := imaging.Process(data, opts)
 	if err != nil {
-		return fmt.Errorf("删除文件失败: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
 	}
+	return variants, nil
+}
 
-	return nil
+// storeVariants 把处理后的各个规格上传到存储并登记，hash 为上传内容的摘要，用于去重
+func (s *StorageService) storeVariants(ctx context.Context, purpose UploadPurpose, owner UploadOwner, variants []imaging.Variant, hash string) ([]UploadedImage, error) {
+	// 同一张图片的各个规格共用文件名前缀
+	base := purpose.NewKey(owner)
+
+	images := make([]UploadedImage, 0, len(variants))
+	for _, variant := range variants {
+		objectKey := purpose.VariantKey(base, variant.Name, variant.Ext)
+		err := s.storage.Put(ctx, objectKey, bytes.NewReader(variant.Data), storage.PutOptions{
+			ContentType: variant.ContentType,
+			Size:        int64(len(variant.Data)),
+		})
+		if err != nil {
+			// 已上传的规格不再有用，尽量清理
+			for _, uploaded := range images {
+				_ = s.storage.Delete(ctx, uploaded.Key)
+			}
+			return nil, err
+		}
+		images = append(images, UploadedImage{
+			Name:        variant.Name,
+			Key:         objectKey,
+			URL:         s.SignURL(ctx, s.storage.URL(objectKey)),
+			ContentType: variant.ContentType,
+			Width:       variant.Width,
+			Height:      variant.Height,
+			Size:        int64(len(variant.Data)),
+		})
+	}
+
+	// 登记后如果一直没有数据引用这张图片，保留期过后会被清理
+	uploads := make([]models.Upload, 0, len(images))
+	for _, image := range images {
+		uploads = append(uploads, models.Upload{
+			Key:          image.Key,
+			GroupKey:     images[0].Key,
+			UploaderID:   owner.UserID,
+			UploaderType: owner.UserType,
+			Purpose:      purpose.Name,
+			Variant:      image.Name,
+			ContentType:  image.ContentType,
+			Size:         image.Size,
+			Width:        image.Width,
+			Height:       image.Height,
+			SHA256:       hash,
+		})
+	}
+	if err := s.tracker.Register(uploads); err != nil {
+		return nil, fmt.Errorf("登记上传文件失败: %w", err)
+	}
+	return images, nil
+}
+
+// uploadedImages 把已登记的同组文件按原图、展示图、缩略图的顺序转换为上传结果
+func (s *StorageService) uploadedImages(group []models.Upload) []UploadedImage {
+	images := make([]UploadedImage, 0, len(group))
+	for _, name := range []string{imaging.VariantOriginal, imaging.VariantDisplay, imaging.VariantThumbnail} {
+		for _, upload := range group {
+			if upload.Variant != name {
+				continue
+			}
+			images = append(images, UploadedImage{
+				Name:        upload.Variant,
+				Key:         upload.Key,
+				URL:         s.SignURL(context.Background(), s.storage.URL(upload.Key)),
+				ContentType: upload.ContentType,
+				Width:       upload.Width,
+				Height:      upload.Height,
+				Size:        upload.Size,
+			})
+		}
+	}
+	return images
+}
+
+// variantSuffix 各图片规格的文件名后缀
+var variantSuffix = map[string]string{
+	imaging.VariantOriginal:  "",
+	imaging.VariantDisplay:   "_display",
+	imaging.VariantThumbnail: "_thumb",
 }
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wishes/config"
+	"wishes/models"
+	"wishes/storage"
+)
+
+func newTestDB(t *testing.T) (*gorm.DB, *config.Config) {
+	t.Helper()
+	cfg := config.LoadConfig()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	db, err := config.InitDB(cfg, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db, cfg
+}
+
+func newTestStorageService(t *testing.T, store storage.Storage) (*StorageService, *gorm.DB) {
+	t.Helper()
+	db, cfg := newTestDB(t)
+	return NewStorageService(db, store, NewUploadTracker(db, store, cfg), cfg), db
+}
+
+// testPNG 生成指定尺寸的 PNG 图片，seed 不同时内容不同
+func testPNG(t *testing.T, width, height int, seed uint8) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x) + seed, uint8(y), seed, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// multipartFile 构造客户端上传的文件，filename 和 contentType 由客户端声明
+func multipartFile(t *testing.T, filename, contentType string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(map[string][]string)
+	header["Content-Disposition"] = []string{`form-data; name="file"; filename="` + filename + `"`}
+	header["Content-Type"] = []string{contentType}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(32 << 20); err != nil {
+		t.Fatal(err)
+	}
+	return req.MultipartForm.File["file"][0]
+}
+
+func TestUploadImageDeduplicatesPrivatePurposesPerUploader(t *testing.T) {
+	service, _ := newTestStorageService(t, storage.NewMemoryStorage(""))
+	data := testPNG(t, 64, 48, 1)
+	alice := UploadOwner{UserID: 1, UserType: "user", Role: models.RoleDonor}
+	bob := UploadOwner{UserID: 2, UserType: "user", Role: models.RoleDonor}
+
+	upload := func(owner UploadOwner, purpose string) string {
+		t.Helper()
+		images, err := service.UploadImage(owner, multipartFile(t, "photo.png", "image/png", data), purpose)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return images[0].Key
+	}
+
+	// 公开的头像在所有上传者之间去重
+	if first, second := upload(alice, PurposeAvatar), upload(bob, PurposeAvatar); first != second {
+		t.Errorf("公开用途应复用已有文件: %s, %s", first, second)
+	}
+
+	// 私有的寄送凭证只在同一上传者内去重
+	first := upload(alice, PurposeShippingProof)
+	if again := upload(alice, PurposeShippingProof); again != first {
+		t.Errorf("同一上传者应复用自己的文件: %s, %s", first, again)
+	}
+	if other := upload(bob, PurposeShippingProof); other == first {
+		t.Errorf("不应复用其他上传者的私有文件 %s", first)
+	}
+}
+
+// bombGIF 画布声明为 65535x65535 的 GIF，文件只有几十字节，解码后需要十几GB内存
+func bombGIF(t *testing.T) []byte {
+	t.Helper()
+	frame := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White})
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:  []*image.Paletted{frame},
+		Delay:  []int{0},
+		Config: image.Config{ColorModel: frame.Palette, Width: 65535, Height: 65535},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadImageRejectsMaliciousFiles(t *testing.T) {
+	store := storage.NewMemoryStorage("")
+	service, _ := newTestStorageService(t, store)
+	owner := UploadOwner{UserID: 1, UserType: "user", Role: models.RoleDonor}
+
+	cases := map[string][]byte{
+		"末尾追加压缩包":  append(testPNG(t, 32, 32, 1), "PK\x03\x04\x14\x00\x00\x00\x08\x00shell.php"...),
+		"解压炸弹":     bombGIF(t),
+		"伪装成图片的网页": []byte("<html><script>alert(1)</script></html>"),
+	}
+	for name, data := range cases {
+		_, err := service.UploadImage(owner, multipartFile(t, "photo.jpg", "image/jpeg", data), PurposeShippingProof)
+		if !errors.Is(err, ErrInvalidUpload) {
+			t.Errorf("%s: 应返回 ErrInvalidUpload，实际 %v", name, err)
+		}
+	}
+	if keys := store.Keys(); len(keys) != 0 {
+		t.Fatalf("被拒绝的文件不应保存: %v", keys)
+	}
+}
+
+func TestUploadImageIgnoresDeclaredType(t *testing.T) {
+	service, _ := newTestStorageService(t, storage.NewMemoryStorage(""))
+	owner := UploadOwner{UserID: 1, UserType: "user", Role: models.RoleDonor}
+
+	// 客户端声明为 JPEG 的 PNG 按内容保存为 PNG
+	images, err := service.UploadImage(owner, multipartFile(t, "photo.jpg", "image/jpeg", testPNG(t, 32, 32, 1)), PurposeAvatar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original := images[0]; original.ContentType != "image/png" || filepath.Ext(original.Key) != ".png" {
+		t.Fatalf("类型和扩展名应由内容决定: %+v", original)
+	}
+}
+
+func TestUploadImagePurposeLimits(t *testing.T) {
+	service, _ := newTestStorageService(t, storage.NewMemoryStorage(""))
+	owner := UploadOwner{UserID: 1, UserType: "user", Role: models.RoleDonor}
+	wide := testPNG(t, 2100, 4, 1)
+
+	upload := func(purpose string) error {
+		_, err := service.UploadImage(owner, multipartFile(t, "wide.png", "image/png", wide), purpose)
+		return err
+	}
+
+	// 头像的最长边不能超过 2048，寄送凭证使用全局限制
+	if err := upload(PurposeAvatar); !errors.Is(err, ErrInvalidUpload) {
+		t.Errorf("头像超出最长边限制应被拒绝，实际 %v", err)
+	}
+	if err := upload(PurposeShippingProof); err != nil {
+		t.Errorf("寄送凭证应使用全局限制: %v", err)
+	}
+
+	// UPLOAD_LIMITS 中的配置优先于用途自身的限制
+	service.uploadLimits = map[string]config.UploadLimit{PurposeAvatar: {MaxDimension: 4096}}
+	if err := upload(PurposeAvatar); err != nil {
+		t.Errorf("放宽后的头像限制应生效: %v", err)
+	}
+	service.uploadLimits = map[string]config.UploadLimit{PurposeShippingProof: {MaxBytes: 64}}
+	if err := upload(PurposeShippingProof); !errors.Is(err, ErrInvalidUpload) {
+		t.Errorf("超出配置的大小限制应被拒绝，实际 %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"wishes/imaging"
+	"wishes/models"
+	"wishes/storage"
+)
+
+var (
+	ErrUploadForbidden = errors.New("没有权限上传该用途的文件")
+	// ErrUploadNotOwned 引用了其他人上传的私有文件
+	ErrUploadNotOwned = errors.New("不能使用其他人上传的文件")
+)
+
+// 上传用途，客户端上传时必须指定其中之一
+const (
+	PurposeAvatar        = "avatar"         // 用户和管理员头像
+	PurposeWishPhoto     = "wish_photo"     // 心愿照片
+	PurposeShippingProof = "shipping_proof" // 爱心人士寄出礼物的凭证
+	PurposeReceiptPhoto  = "receipt_photo"  // 孩子签收礼物的照片
+	PurposeGiftPhoto     = "gift_photo"     // 回礼照片
+)
+
+// UploadPurpose 上传用途。每种用途的文件保存在单独的前缀下，只允许指定角色上传
+type UploadPurpose struct {
+	Name   string
+	Prefix string        // 存储路径前缀
+	Roles  []models.Role // 允许上传的角色
+	// 大小和尺寸上限，0 表示使用全局配置，UPLOAD_LIMITS 中的配置优先
+	MaxBytes     int64
+	MaxDimension int
+	// Retention 文件不再被任何数据引用后保留的时间，超过后由清理任务删除
+	Retention time.Duration
+	// Private 文件保存为私有文件，接口返回有时效的签名地址，例如孩子的照片
+	Private bool
+	// PublicThumbnail 私有用途的缩略图仍可公开访问，由 CDN 提供
+	PublicThumbnail bool
+}
+
+var uploadPurposes = map[string]UploadPurpose{
+	PurposeAvatar: {
+		Name:         PurposeAvatar,
+		Prefix:       "avatars",
+		Roles:        []models.Role{models.RoleDonor, models.RoleVolunteer, models.RoleAdmin},
+		MaxBytes:     2 * 1024 * 1024,
+		MaxDimension: 2048,
+		Retention:    24 * time.Hour,
+	},
+	PurposeWishPhoto: {
+		Name:      PurposeWishPhoto,
+		Prefix:    "wishes",
+		Roles:     []models.Role{models.RoleAdmin},
+		Retention: 7 * 24 * time.Hour, // 批量录入心愿前可能提前上传照片
+		Private:   true,
+		// 心愿登记时已取得监护人同意在心愿墙展示缩略图
+		PublicThumbnail: true,
+	},
+	PurposeShippingProof: {
+		Name:      PurposeShippingProof,
+		Prefix:    "shipping",
+		Roles:     []models.Role{models.RoleDonor, models.RoleVolunteer, models.RoleAdmin},
+		Retention: 3 * 24 * time.Hour,
+		Private:   true, // 快递单上有收件人姓名和地址
+	},
+	PurposeReceiptPhoto: {
+		Name:      PurposeReceiptPhoto,
+		Prefix:    "receipts",
+		Roles:     []models.Role{models.RoleVolunteer, models.RoleAdmin},
+		Retention: 3 * 24 * time.Hour,
+		Private:   true,
+	},
+	PurposeGiftPhoto: {
+		Name:      PurposeGiftPhoto,
+		Prefix:    "gifts",
+		Roles:     []models.Role{models.RoleVolunteer, models.RoleAdmin},
+		Retention: 3 * 24 * time.Hour,
+		Private:   true,
+	},
+}
+
+// LookupUploadPurpose 按名称查找上传用途
+func LookupUploadPurpose(name string) (UploadPurpose, error) {
+	purpose, ok := uploadPurposes[name]
+	if !ok {
+		return UploadPurpose{}, fmt.Errorf("%w: 未知的上传用途 %q", ErrInvalidUpload, name)
+	}
+	return purpose, nil
+}
+
+// Authorize 检查上传者的角色能否上传该用途的文件
+func (p UploadPurpose) Authorize(owner UploadOwner) error {
+	for _, role := range p.Roles {
+		if role == owner.Role {
+			return nil
+		}
+	}
+	return ErrUploadForbidden
+}
+
+// NewKey 生成文件的存储路径（不含扩展名）：前缀/上传者类型/上传者ID/随机文件名，
+// 同一张图片的各个规格由 VariantKey 在此基础上生成
+func (p UploadPurpose) NewKey(owner UploadOwner) string {
+	return path.Join(p.Prefix, owner.UserType, strconv.FormatUint(uint64(owner.UserID), 10), uuid.New().String())
+}
+
+// VariantKey 返回图片某个规格的存储路径，私有的规格保存在 storage.PrivatePrefix 下
+func (p UploadPurpose) VariantKey(base, variant, ext string) string {
+	key := base + variantSuffix[variant] + ext
+	if p.Private && !(p.PublicThumbnail && variant == imaging.VariantThumbnail) {
+		key = storage.PrivatePrefix + key
+	}
+	return key
+}
+
+// canReference 判断上传者能否在数据中引用该文件。公开文件任何人都可以引用，
+// 私有文件只能由上传者本人引用，管理员可以引用所有文件
+func (o UploadOwner) canReference(key string) bool {
+	purpose, uploader, ok := ParseUploadKey(key)
+	if !ok || !purpose.Private || o.Role == models.RoleAdmin {
+		return true
+	}
+	return uploader.UserID == o.UserID && uploader.UserType == o.UserType
+}
+
+// ParseUploadKey 从存储路径中解析上传用途和上传者，不是按用途生成的路径时返回 false
+func ParseUploadKey(key string) (UploadPurpose, UploadOwner, bool) {
+	parts := strings.Split(strings.TrimPrefix(key, storage.PrivatePrefix), "/")
+	if len(parts) != 4 {
+		return UploadPurpose{}, UploadOwner{}, false
+	}
+	userID, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil || (parts[1] != "user" && parts[1] != "admin") {
+		return UploadPurpose{}, UploadOwner{}, false
+	}
+	for _, purpose := range uploadPurposes {
+		if purpose.Prefix == parts[0] {
+			return purpose, UploadOwner{UserID: uint(userID), UserType: parts[1]}, true
+		}
+	}
+	return UploadPurpose{}, UploadOwner{}, false
+}
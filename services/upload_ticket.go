@@ -0,0 +1,293 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"wishes/imaging"
+	"wishes/metrics"
+	"wishes/models"
+	"wishes/storage"
+)
+
+var (
+	ErrUploadTicketNotFound = errors.New("上传凭证不存在")
+	ErrUploadTicketExpired  = errors.New("上传凭证已过期，请重新申请")
+	ErrUploadTicketUsed     = errors.New("上传凭证已使用")
+	ErrUploadNotReceived    = errors.New("文件尚未上传")
+	ErrUploadMismatch       = errors.New("上传的文件与申请时的类型或大小不一致")
+)
+
+// DirectUploadPath 存储后端不支持预签名时，客户端通过服务中转上传的地址前缀
+const DirectUploadPath = "/api/v1/upload/direct/"
+
+// UploadOwner 申请上传凭证的用户，小程序用户和管理员的ID相互独立
+type UploadOwner struct {
+	UserID   uint
+	UserType string
+	Role     models.Role
+}
+
+// CreateUploadTicket 为客户端直传签发上传凭证。文件路径由服务端按用途和上传者生成；
+// 支持预签名的存储后端直接上传到存储，否则返回中转上传的地址
+func (s *StorageService) CreateUploadTicket(ctx context.Context, owner UploadOwner, purposeName, contentType string, size int64) (*models.UploadTicket, *storage.PresignedRequest, error) {
+	purpose, err := LookupUploadPurpose(purposeName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := purpose.Authorize(owner); err != nil {
+		return nil, nil, err
+	}
+	if err := s.ValidateImage(purpose, contentType, size); err != nil {
+		return nil, nil, err
+	}
+	key := purpose.VariantKey(purpose.NewKey(owner), imaging.VariantOriginal, imageExtensions[contentType])
+
+	ticket := models.UploadTicket{
+		UserID:      owner.UserID,
+		UserType:    owner.UserType,
+		Purpose:     purpose.Name,
+		Key:         key,
+		ContentType: contentType,
+		Size:        size,
+		Status:      models.UploadTicketPending,
+		ExpiresAt:   s.now().Add(s.ticketTTL).Unix(),
+	}
+
+	var request *storage.PresignedRequest
+	if presigner, ok := s.storage.(storage.Presigner); ok {
+		request, err = presigner.PresignPut(ctx, key, storage.PresignOptions{
+			ContentType: contentType,
+			Size:        size,
+			Expires:     s.ticketTTL,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		token := hex.EncodeToString(buf)
+		ticket.TokenHash = hashUploadToken(token)
+		request = &storage.PresignedRequest{
+			Method: http.MethodPut,
+			URL:    DirectUploadPath + token,
+			Headers: map[string]string{
+				"Content-Type":   contentType,
+				"Content-Length": strconv.FormatInt(size, 10),
+			},
+			ExpiresAt: ticket.ExpiresAt,
+		}
+	}
+
+	if err := s.db.Create(&ticket).Error; err != nil {
+		return nil, nil, err
+	}
+	return &ticket, request, nil
+}
+
+// ReceiveDirectUpload 接收中转上传的文件，请求的类型和大小必须与凭证一致
+func (s *StorageService) ReceiveDirectUpload(ctx context.Context, token, contentType string, size int64, body io.Reader) (*models.UploadTicket, error) {
+	var ticket models.UploadTicket
+	if err := s.db.Where("token_hash = ?", hashUploadToken(token)).First(&ticket).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadTicketNotFound
+		}
+		return nil, err
+	}
+	if ticket.Status != models.UploadTicketPending {
+		return nil, ErrUploadTicketUsed
+	}
+	if s.now().Unix() >= ticket.ExpiresAt {
+		return nil, ErrUploadTicketExpired
+	}
+	if contentType != ticket.ContentType || size != ticket.Size {
+		return nil, ErrUploadMismatch
+	}
+
+	// 多读一个字节，用于发现实际内容超过声明的大小
+	data, err := io.ReadAll(io.LimitReader(body, ticket.Size+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != ticket.Size {
+		return nil, ErrUploadMismatch
+	}
+
+	if err := s.storage.Put(ctx, ticket.Key, bytes.NewReader(data), storage.PutOptions{
+		ContentType: ticket.ContentType,
+		Size:        ticket.Size,
+	}); err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// CompleteUploadTicket 确认文件已上传到存储并符合凭证的约束，返回文件的访问地址。
+// 与 UploadImage 一样重新编码为原图、展示图和缩略图，去除 EXIF 等元数据，客户端上传的文件随后删除。
+// 不符合约束的文件会被删除；与同一用途下已有文件内容相同时返回已有文件的地址
+func (s *StorageService) CompleteUploadTicket(ctx context.Context, owner UploadOwner, ticketID uint) (*models.UploadTicket, string, error) {
+	var ticket models.UploadTicket
+	err := s.db.Where("id = ? AND user_id = ? AND user_type = ?", ticketID, owner.UserID, owner.UserType).
+		First(&ticket).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrUploadTicketNotFound
+		}
+		return nil, "", err
+	}
+
+	switch ticket.Status {
+	case models.UploadTicketCompleted:
+		// 重复确认时直接返回，便于客户端重试
+		return &ticket, s.SignURL(ctx, s.storage.URL(ticket.ResultKey())), nil
+	case models.UploadTicketRejected:
+		return nil, "", ErrUploadTicketUsed
+	}
+
+	info, err := s.storage.Stat(ctx, ticket.Key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			if s.now().Unix() >= ticket.ExpiresAt {
+				return nil, "", ErrUploadTicketExpired
+			}
+			return nil, "", ErrUploadNotReceived
+		}
+		return nil, "", err
+	}
+
+	status := models.UploadTicketCompleted
+	var upload *directUpload
+	if info.Size != ticket.Size || (info.ContentType != "" && info.ContentType != ticket.ContentType) {
+		status = models.UploadTicketRejected
+	} else if upload, err = s.prepareDirectUpload(ctx, &ticket); err != nil {
+		if !errors.Is(err, ErrInvalidUpload) {
+			return nil, "", err
+		}
+		slog.WarnContext(ctx, "直传文件未通过检查", slog.String("key", ticket.Key), slog.String("error", err.Error()))
+		status = models.UploadTicketRejected
+	}
+	if status == models.UploadTicketRejected {
+		if err := s.storage.Delete(ctx, ticket.Key); err != nil {
+			slog.ErrorContext(ctx, "删除不符合约束的上传文件失败", slog.String("key", ticket.Key), slog.String("error", err.Error()))
+		}
+	}
+
+	now := s.now().Unix()
+	result := s.db.Model(&models.UploadTicket{}).
+		Where("id = ? AND status = ?", ticket.ID, models.UploadTicketPending).
+		Updates(map[string]any{"status": status, "completed_at": now})
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	if status == models.UploadTicketRejected {
+		return nil, "", ErrUploadMismatch
+	}
+	if result.RowsAffected == 0 {
+		// 并发的确认请求已经处理完成，返回它的结果
+		if err := s.db.First(&ticket, ticket.ID).Error; err != nil {
+			return nil, "", err
+		}
+		return &ticket, s.SignURL(ctx, s.storage.URL(ticket.ResultKey())), nil
+	}
+
+	ticket.Status = status
+	ticket.CompletedAt = &now
+	if err := s.registerDirectUpload(ctx, &ticket, owner, upload); err != nil {
+		return nil, "", err
+	}
+	return &ticket, s.SignURL(ctx, s.storage.URL(ticket.ResultKey())), nil
+}
+
+// directUpload 检查并重新编码后的直传文件
+type directUpload struct {
+	hash     string
+	variants []imaging.Variant
+}
+
+// registerDirectUpload 保存重新编码后的各个规格并删除客户端上传的文件，凭证指向新的原图。
+// 同一用途下已有内容相同的图片时不再保存，凭证指向已有文件；私有用途只复用同一上传者的文件
+func (s *StorageService) registerDirectUpload(ctx context.Context, ticket *models.UploadTicket, owner UploadOwner, upload *directUpload) error {
+	purpose, err := LookupUploadPurpose(ticket.Purpose)
+	if err != nil {
+		return err
+	}
+
+	images, err := s.findDuplicateImages(purpose, owner, upload.hash)
+	if err != nil {
+		return err
+	}
+	field, deduplicated := "processed_key", images != nil
+	if deduplicated {
+		field = "duplicate_of"
+	} else if images, err = s.storeVariants(ctx, purpose, owner, upload.variants, upload.hash); err != nil {
+		return err
+	}
+
+	if err := s.db.Model(ticket).Update(field, images[0].Key).Error; err != nil {
+		return err
+	}
+	if deduplicated {
+		ticket.DuplicateOf = images[0].Key
+	} else {
+		ticket.ProcessedKey = images[0].Key
+	}
+
+	// 客户端上传的文件可能带有 EXIF 等元数据，不再保留
+	if err := s.storage.Delete(ctx, ticket.Key); err != nil {
+		slog.ErrorContext(ctx, "删除直传的原始文件失败", slog.String("key", ticket.Key), slog.String("error", err.Error()))
+	}
+	metrics.Uploads.WithLabelValues(ticket.Purpose, strconv.FormatBool(deduplicated)).Inc()
+	return nil
+}
+
+// prepareDirectUpload 读取直传的文件，按内容检查类型和限制后重新编码。
+// 文件必须确实是申请时声明的图片类型，且没有夹带其他数据
+func (s *StorageService) prepareDirectUpload(ctx context.Context, ticket *models.UploadTicket) (*directUpload, error) {
+	body, err := s.storage.Open(ctx, ticket.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, ticket.Size+1))
+	if err != nil {
+		return nil, err
+	}
+	purpose, err := LookupUploadPurpose(ticket.Purpose)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.inspectImage(purpose, data)
+	if err != nil {
+		return nil, err
+	}
+	if info.ContentType != ticket.ContentType {
+		return nil, fmt.Errorf("%w: 文件内容是 %s，与申请时的 %s 不一致", ErrInvalidUpload, info.ContentType, ticket.ContentType)
+	}
+	variants, err := s.processImage(purpose, data)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return &directUpload{hash: hex.EncodeToString(sum[:]), variants: variants}, nil
+}
+
+func hashUploadToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
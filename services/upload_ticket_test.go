@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"wishes/models"
+	"wishes/storage"
+)
+
+// TestDirectUploadRejectsMismatchedContent 直传的文件不重新编码，内容必须与申请时声明的类型一致且没有夹带数据
+func TestDirectUploadRejectsMismatchedContent(t *testing.T) {
+	store := storage.NewMemoryStorage("")
+	service, _ := newTestStorageService(t, store)
+	owner := UploadOwner{UserID: 1, UserType: "user", Role: models.RoleDonor}
+	ctx := context.Background()
+
+	upload := func(contentType string, data []byte) error {
+		t.Helper()
+		_, _, err := service.CompleteUploadTicket(ctx, owner, directUploadFile(t, service, owner, PurposeShippingProof, contentType, data))
+		return err
+	}
+
+	cases := map[string]struct {
+		contentType string
+		data        []byte
+	}{
+		"声明为JPEG的PNG": {"image/jpeg", testPNG(t, 32, 32, 1)},
+		"末尾追加压缩包":     {"image/png", append(testPNG(t, 32, 32, 2), "PK\x03\x04payload"...)},
+		"解压炸弹":        {"image/gif", bombGIF(t)},
+	}
+	for name, tc := range cases {
+		if err := upload(tc.contentType, tc.data); !errors.Is(err, ErrUploadMismatch) {
+			t.Errorf("%s: 应返回 ErrUploadMismatch，实际 %v", name, err)
+		}
+	}
+	if keys := store.Keys(); len(keys) != 0 {
+		t.Fatalf("不符合约束的文件应被删除: %v", keys)
+	}
+
+	if err := upload("image/png", testPNG(t, 32, 32, 3)); err != nil {
+		t.Fatalf("符合约束的文件应确认成功: %v", err)
+	}
+}
+
+// testJPEGWithEXIF 生成带 EXIF（含 GPS 信息标记）的 JPEG
+func testJPEGWithEXIF(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 48)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00GPSLatitude 31.2304N")
+	segment := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(exif)+2))
+	segment = append(segment, exif...)
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+// directUploadFile 申请凭证并通过服务中转上传，返回凭证ID
+func directUploadFile(t *testing.T, service *StorageService, owner UploadOwner, purpose, contentType string, data []byte) uint {
+	t.Helper()
+	ctx := context.Background()
+	ticket, request, err := service.CreateUploadTicket(ctx, owner, purpose, contentType, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := strings.TrimPrefix(request.URL, DirectUploadPath)
+	if _, err := service.ReceiveDirectUpload(ctx, token, contentType, int64(len(data)), bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	return ticket.ID
+}
+
+func TestDirectUploadStripsMetadata(t *testing.T) {
+	store := storage.NewMemoryStorage("")
+	service, db := newTestStorageService(t, store)
+	admin := UploadOwner{UserID: 1, UserType: "admin", Role: models.RoleAdmin}
+	ctx := context.Background()
+	data := testJPEGWithEXIF(t)
+	if !bytes.Contains(data, []byte("GPSLatitude")) {
+		t.Fatal("测试图片应带有 EXIF")
+	}
+
+	ticketID := directUploadFile(t, service, admin, PurposeWishPhoto, "image/jpeg", data)
+	ticket, _, err := service.CompleteUploadTicket(ctx, admin, ticketID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.ProcessedKey == "" || ticket.ResultKey() == ticket.Key {
+		t.Fatalf("凭证应指向重新编码后的原图: %+v", ticket)
+	}
+	if _, ok := store.Get(ticket.Key); ok {
+		t.Fatal("客户端上传的原始文件应已删除")
+	}
+
+	// 与 UploadImage 一样生成三种规格，且都不含 EXIF
+	var uploads []models.Upload
+	if err := db.Where("group_key = ?", ticket.ResultKey()).Find(&uploads).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != len(variantSuffix) {
+		t.Fatalf("应登记 %d 种规格，实际 %d", len(variantSuffix), len(uploads))
+	}
+	for _, upload := range uploads {
+		stored, ok := store.Get(upload.Key)
+		if !ok {
+			t.Fatalf("%s 未保存", upload.Key)
+		}
+		if bytes.Contains(stored, []byte("Exif")) || bytes.Contains(stored, []byte("GPSLatitude")) {
+			t.Fatalf("%s 仍带有 EXIF", upload.Key)
+		}
+	}
+
+	// 重复确认返回相同结果
+	again, _, err := service.CompleteUploadTicket(ctx, admin, ticketID)
+	if err != nil || again.ResultKey() != ticket.ResultKey() {
+		t.Fatalf("重复确认应返回相同文件: %v %+v", err, again)
+	}
+
+	// 内容相同的直传和表单上传都复用已有的图片
+	duplicate, _, err := service.CompleteUploadTicket(ctx, admin, directUploadFile(t, service, admin, PurposeWishPhoto, "image/jpeg", data))
+	if err != nil || duplicate.DuplicateOf != ticket.ResultKey() {
+		t.Fatalf("内容相同的直传应复用已有图片: %v %+v", err, duplicate)
+	}
+	images, err := service.UploadImage(admin, multipartFile(t, "photo.jpg", "image/jpeg", data), PurposeWishPhoto)
+	if err != nil || images[0].Key != ticket.ResultKey() {
+		t.Fatalf("内容相同的表单上传应复用直传的图片: %v %+v", err, images)
+	}
+}
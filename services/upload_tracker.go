@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wishes/config"
+	"wishes/models"
+	"wishes/storage"
+)
+
+// 引用上传文件的数据类型
+const (
+	RefTypeWish   = "wish"
+	RefTypeRecord = "record"
+	RefTypeUser   = "user"
+	RefTypeAdmin  = "admin"
+)
+
+// UploadTracker 记录上传的文件以及哪些数据引用了它们，定期删除不再被引用且超过用途保留期的文件。
+// 没有登记过的文件（例如启用跟踪前上传的）不会被删除。nil 表示不跟踪，所有方法都不做任何事。
+type UploadTracker struct {
+	db          *gorm.DB
+	storage     storage.Storage
+	gracePeriod time.Duration
+	now         func() time.Time
+}
+
+func NewUploadTracker(db *gorm.DB, store storage.Storage, cfg *config.Config) *UploadTracker {
+	return &UploadTracker{
+		db:          db,
+		storage:     store,
+		gracePeriod: cfg.UploadGCGracePeriod,
+		now:         time.Now,
+	}
+}
+
+// SetClock 替换当前时间的来源，便于模拟保留期结束
+func (t *UploadTracker) SetClock(now func() time.Time) {
+	t.now = now
+}
+
+// Register 登记新上传的文件，此时还没有数据引用它们
+func (t *UploadTracker) Register(uploads []models.Upload) error {
+	if t == nil || len(uploads) == 0 {
+		return nil
+	}
+	now := t.now().Unix()
+	for i := range uploads {
+		uploads[i].OrphanedAt = now
+	}
+	return t.db.Create(&uploads).Error
+}
+
+// KeyFromURL 从文件访问地址中取出 key，不是本存储的地址时返回 false
+func (t *UploadTracker) KeyFromURL(fileURL string) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	return storage.KeyFromURL(t.storage, fileURL)
+}
+
+// SyncReferences 用数据当前引用的文件地址替换它之前的引用，需在修改数据的事务中调用。
+// fields 为字段名到文件地址的映射，数据被删除时传入 nil。
+// 新引用的私有文件必须由 actor 上传，管理员除外，否则返回 ErrUploadNotOwned，
+// 避免用户把别人的私有文件地址填进自己的数据后拿到签名地址
+func (t *UploadTracker) SyncReferences(tx *gorm.DB, refType string, refID uint, actor UploadOwner, fields map[string][]string) error {
+	if t == nil {
+		return nil
+	}
+
+	var previous []string
+	if err := tx.Model(&models.UploadReference{}).
+		Where("ref_type = ? AND ref_id = ?", refType, refID).
+		Distinct().Pluck("group_key", &previous).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("ref_type = ? AND ref_id = ?", refType, refID).
+		Delete(&models.UploadReference{}).Error; err != nil {
+		return err
+	}
+
+	var current []string
+	for field, urls := range fields {
+		for _, fileURL := range urls {
+			key, ok := t.KeyFromURL(fileURL)
+			if !ok {
+				continue
+			}
+			groupKey, err := t.groupKey(tx, key)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(previous, groupKey) && !actor.canReference(key) {
+				return ErrUploadNotOwned
+			}
+			reference := models.UploadReference{GroupKey: groupKey, RefType: refType, RefID: refID, Field: field}
+			if err := tx.Where(reference).FirstOrCreate(&reference).Error; err != nil {
+				return err
+			}
+			current = append(current, groupKey)
+		}
+	}
+
+	affected := append(previous, current...)
+	if len(affected) == 0 {
+		return nil
+	}
+	// 引用计数按引用记录重新统计，不会因为重复调用而偏差
+	if err := tx.Model(&models.Upload{}).Where("group_key IN ?", affected).
+		Update("ref_count", tx.Model(&models.UploadReference{}).Select("COUNT(*)").
+			Where("upload_references.group_key = uploads.group_key")).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Upload{}).Where("group_key IN ? AND ref_count > 0", affected).
+		Update("orphaned_at", 0).Error; err != nil {
+		return err
+	}
+	// 最后一个引用被移除的文件从现在开始计算保留期
+	return tx.Model(&models.Upload{}).Where("group_key IN ? AND ref_count = 0 AND orphaned_at = 0", affected).
+		Update("orphaned_at", t.now().Unix()).Error
+}
+
+// FindDuplicate 查找同一用途下内容相同的已上传图片，返回同组的所有文件。私有用途只查找同一上传者的文件，
+// 否则上传者可以通过相同内容拿到别人私有文件的签名地址，或确认别人上传过某张图片。
+// 找到的文件如果没有被引用，保留期重新开始计算，避免刚返回给客户端就被清理
+func (t *UploadTracker) FindDuplicate(purpose UploadPurpose, owner UploadOwner, sha256 string) ([]models.Upload, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	query := t.db.Where("purpose = ? AND sha256 = ? AND key = group_key", purpose.Name, sha256)
+	if purpose.Private {
+		query = query.Where("uploader_id = ? AND uploader_type = ?", owner.UserID, owner.UserType)
+	}
+	var original models.Upload
+	err := query.Order("id").Limit(1).Find(&original).Error
+	if err != nil || original.ID == 0 {
+		return nil, err
+	}
+	var group []models.Upload
+	if err := t.db.Where("group_key = ?", original.GroupKey).Order("id").Find(&group).Error; err != nil {
+		return nil, err
+	}
+
+	// 与清理任务的条件删除互斥：文件已被清理时更新不到全部记录，按新文件上传
+	result := t.db.Model(&models.Upload{}).Where("group_key = ?", original.GroupKey).
+		Update("orphaned_at", gorm.Expr("CASE WHEN ref_count = 0 THEN ? ELSE 0 END", t.now().Unix()))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected != int64(len(group)) {
+		return nil, nil
+	}
+	return group, nil
+}
+
+// groupKey 返回文件所属的组，未登记的文件自成一组
+func (t *UploadTracker) groupKey(tx *gorm.DB, key string) (string, error) {
+	var upload models.Upload
+	err := tx.Select("group_key").Where("key = ?", key).Limit(1).Find(&upload).Error
+	if err != nil {
+		return "", err
+	}
+	if upload.GroupKey == "" {
+		return key, nil
+	}
+	return upload.GroupKey, nil
+}
+
+// GCReport 一次清理的结果
+type GCReport struct {
+	DryRun  bool            `json:"dryRun"`
+	Removed []models.Upload `json:"removed"`
+	Bytes   int64           `json:"bytes"`  // 删除的文件总大小
+	Failed  []string        `json:"failed"` // 从存储删除失败的 key，下次清理时重试
+}
+
+// CollectGarbage 删除不再被引用且超过保留期的文件。dryRun 为 true 时只返回将要删除的文件
+func (t *UploadTracker) CollectGarbage(ctx context.Context, dryRun bool) (*GCReport, error) {
+	report := &GCReport{DryRun: dryRun, Removed: []models.Upload{}, Failed: []string{}}
+	if t == nil {
+		return report, nil
+	}
+
+	// 先按最短的保留期筛选，再逐个按用途的保留期判断
+	now := t.now()
+	minRetention := t.gracePeriod
+	for _, purpose := range uploadPurposes {
+		minRetention = min(minRetention, purpose.Retention)
+	}
+	var candidates []models.Upload
+	if err := t.db.Where("ref_count = 0 AND orphaned_at != 0 AND orphaned_at <= ?", now.Add(-minRetention).Unix()).
+		Order("id").Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	// 同组的文件一起删除
+	var groupKeys []string
+	groups := make(map[string][]models.Upload)
+	for _, upload := range candidates {
+		if _, ok := groups[upload.GroupKey]; !ok {
+			groupKeys = append(groupKeys, upload.GroupKey)
+		}
+		groups[upload.GroupKey] = append(groups[upload.GroupKey], upload)
+	}
+
+	for _, groupKey := range groupKeys {
+		group := groups[groupKey]
+		cutoff := now.Add(-t.retention(group[0].Purpose)).Unix()
+		if group[0].OrphanedAt > cutoff {
+			continue
+		}
+		if !dryRun {
+			removed, failed, err := t.removeGroup(ctx, groupKey, group, "ref_count = 0 AND orphaned_at != 0 AND orphaned_at <= ?", cutoff)
+			if err != nil {
+				return nil, err
+			}
+			if len(failed) > 0 {
+				report.Failed = append(report.Failed, failed...)
+				continue
+			}
+			if !removed {
+				continue
+			}
+		}
+		for _, upload := range group {
+			report.Removed = append(report.Removed, upload)
+			report.Bytes += upload.Size
+		}
+	}
+	return report, nil
+}
+
+// removeGroup 先按条件删除登记记录，再删除存储中的文件。条件不再满足（例如刚被引用或命中去重）时
+// 不删除并返回 false。删除失败的文件会恢复登记记录，下次清理时重试
+func (t *UploadTracker) removeGroup(ctx context.Context, groupKey string, group []models.Upload, condition string, args ...any) (bool, []string, error) {
+	result := t.db.Where("group_key = ?", groupKey).Where(condition, args...).Delete(&models.Upload{})
+	if result.Error != nil {
+		return false, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil, nil
+	}
+
+	var failed []models.Upload
+	var failedKeys []string
+	for _, upload := range group {
+		if err := t.storage.Delete(ctx, upload.Key); err != nil {
+			slog.ErrorContext(ctx, "删除上传文件失败", slog.String("key", upload.Key), slog.String("error", err.Error()))
+			failed = append(failed, upload)
+			failedKeys = append(failedKeys, upload.Key)
+		}
+	}
+	if len(failed) > 0 {
+		if err := t.db.Create(&failed).Error; err != nil {
+			return false, nil, err
+		}
+	}
+	return true, failedKeys, nil
+}
+
+// retention 返回用途的保留期，按目录上传的旧文件使用 UPLOAD_GC_GRACE_HOURS
+func (t *UploadTracker) retention(purposeName string) time.Duration {
+	if purpose, ok := uploadPurposes[purposeName]; ok {
+		return purpose.Retention
+	}
+	return t.gracePeriod
+}
+
+// Start 启动后台任务，定期清理不再被引用的文件，直到 ctx 被取消。返回的通道在任务退出后关闭
+func (t *UploadTracker) Start(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	if t == nil {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			report, err := t.CollectGarbage(ctx, false)
+			if err != nil {
+				slog.ErrorContext(ctx, "清理上传文件失败", slog.String("error", err.Error()))
+			} else if len(report.Removed) > 0 || len(report.Failed) > 0 {
+				slog.InfoContext(ctx, "清理上传文件",
+					slog.Int("removed", len(report.Removed)),
+					slog.Int64("bytes", report.Bytes),
+					slog.Int("failed", len(report.Failed)),
+				)
+				for _, upload := range report.Removed {
+					slog.InfoContext(ctx, "已删除上传文件",
+						slog.String("key", upload.Key),
+						slog.String("purpose", upload.Purpose),
+						slog.String("uploader_type", upload.UploaderType),
+						slog.Uint64("uploader_id", uint64(upload.UploaderID)),
+					)
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// photoURLs 解析照片字段，兼容 JSON 数组和逗号分隔两种格式
+func photoURLs(value *string) []string {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil
+	}
+	text := strings.TrimSpace(*value)
+	if strings.HasPrefix(text, "[") {
+		var urls []string
+		if err := json.Unmarshal([]byte(text), &urls); err == nil {
+			return urls
+		}
+	}
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"wishes/models"
+	"wishes/storage"
+)
+
+func TestSyncReferencesRequiresOwnerForPrivateFiles(t *testing.T) {
+	store := storage.NewMemoryStorage("")
+	service, db := newTestStorageService(t, store)
+	tracker := service.tracker
+
+	alice := UploadOwner{UserID: 1, UserType: "user", Role: models.RoleDonor}
+	bob := UploadOwner{UserID: 2, UserType: "user", Role: models.RoleDonor}
+	admin := UploadOwner{UserID: 1, UserType: "admin", Role: models.RoleAdmin}
+
+	upload := func(owner UploadOwner, purpose string, seed uint8) string {
+		t.Helper()
+		images, err := service.UploadImage(owner, multipartFile(t, "photo.png", "image/png", testPNG(t, 32, 32, seed)), purpose)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return images[0].URL
+	}
+	proof := upload(alice, PurposeShippingProof, 1)
+	avatar := upload(alice, PurposeAvatar, 2)
+
+	sync := func(refID uint, actor UploadOwner, urls ...string) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			return tracker.SyncReferences(tx, RefTypeRecord, refID, actor, map[string][]string{"confirmationPhotos": urls})
+		})
+	}
+
+	if err := sync(1, bob, proof); !errors.Is(err, ErrUploadNotOwned) {
+		t.Fatalf("引用别人的私有文件应返回 ErrUploadNotOwned，实际 %v", err)
+	}
+	if err := sync(2, bob, avatar); err != nil {
+		t.Fatalf("公开文件任何人都可以引用: %v", err)
+	}
+	if err := sync(3, alice, proof); err != nil {
+		t.Fatalf("上传者可以引用自己的文件: %v", err)
+	}
+	// 已经引用的文件保持不变时，其他人修改数据不受影响
+	if err := sync(3, bob, proof, avatar); err != nil {
+		t.Fatalf("保留已有引用不应被拒绝: %v", err)
+	}
+	if err := sync(4, admin, proof); err != nil {
+		t.Fatalf("管理员可以引用所有文件: %v", err)
+	}
+}
@@ -8,12 +8,14 @@ import (
 )
 
 type UserService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	tracker *UploadTracker
 }
 
-func NewUserService(db *gorm.DB) *UserService {
+func NewUserService(db *gorm.DB, tracker *UploadTracker) *UserService {
 	return &UserService{
-		db: db,
+		db:      db,
+		tracker: tracker,
 	}
 }
 
@@ -25,6 +27,28 @@ func (s *UserService) GetUserByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+// UpdateProfile 更新小程序用户的昵称和头像，nil 表示不修改
+func (s *UserService) UpdateProfile(userID uint, nickname, avatarURL *string) (*models.User, error) {
+	updates, err := ProfileUpdate{Nickname: nickname, AvatarURL: avatarURL}.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(updates) > 0 {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+				return err
+			}
+			return syncAvatar(tx, s.tracker, RefTypeUser, userID, UploadOwner{UserID: userID, UserType: "user"}, updates)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetUserByID(userID)
+}
+
 func (s *UserService) GetUsers(pageIndex, pageSize int, isAdmin bool) ([]models.User, int64, error) {
 	query := s.db.Model(&models.User{})
 
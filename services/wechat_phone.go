@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"wishes/models"
+	"wishes/utils"
+	"wishes/wechat"
+)
+
+var (
+	ErrWechatSessionExpired = errors.New("微信会话已失效，请重新登录后再授权手机号")
+	ErrWechatPhoneInvalid   = errors.New("手机号授权数据无效")
+)
+
+// phoneNumber 返回保存到用户上的手机号，国内号码不带区号
+func phoneNumber(p *wechat.PhoneInfo) string {
+	if p.CountryCode == "" || p.CountryCode == "86" {
+		return p.PurePhoneNumber
+	}
+	return "+" + p.CountryCode + " " + p.PurePhoneNumber
+}
+
+// BindPhoneByEncryptedData 使用登录时保存的 session_key 解密 getPhoneNumber 返回的数据，
+// 并保存为用户已验证的手机号
+func (s *WechatService) BindPhoneByEncryptedData(userID uint, encryptedData, iv string) (*models.User, error) {
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.WechatSessionKey == "" {
+		return nil, ErrWechatSessionExpired
+	}
+
+	plain, err := utils.DecryptWechatData(user.WechatSessionKey, encryptedData, iv)
+	if err != nil {
+		// session_key 会在用户重新登录后更新，解密失败多数是前端使用了旧的会话
+		return nil, ErrWechatSessionExpired
+	}
+
+	var info wechat.PhoneInfo
+	if err := json.Unmarshal(plain, &info); err != nil {
+		return nil, ErrWechatPhoneInvalid
+	}
+	if info.Watermark.AppID != s.Client.AppID() {
+		return nil, ErrWechatPhoneInvalid
+	}
+
+	return s.savePhone(&user, &info)
+}
+
+// BindPhoneByCode 使用 getPhoneNumber 返回的动态令牌 code 换取手机号，
+// 并保存为用户已验证的手机号
+func (s *WechatService) BindPhoneByCode(ctx context.Context, userID uint, code string) (*models.User, error) {
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	info, err := s.GetPhoneNumber(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.savePhone(&user, info)
+}
+
+func (s *WechatService) savePhone(user *models.User, info *wechat.PhoneInfo) (*models.User, error) {
+	phone := phoneNumber(info)
+	if phone == "" {
+		return nil, ErrWechatPhoneInvalid
+	}
+
+	now := time.Now().Unix()
+	if err := s.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]any{
+		"phone":             phone,
+		"phone_verified_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	user.Phone = phone
+	user.PhoneVerifiedAt = now
+	return user, nil
+}
+
+// GetPhoneNumber 调用 getuserphonenumber 接口，用 code 换取用户手机号
+func (s *WechatService) GetPhoneNumber(ctx context.Context, code string) (*wechat.PhoneInfo, error) {
+	var info *wechat.PhoneInfo
+	err := s.Tokens.Do(ctx, func(accessToken string) error {
+		var err error
+		info, err = s.Client.GetPhoneNumber(ctx, accessToken, code)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, wechat.ErrInvalidCode) || errors.Is(err, wechat.ErrCodeUsed) {
+			return nil, fmt.Errorf("%w: %v", ErrWechatPhoneInvalid, err)
+		}
+		return nil, err
+	}
+	return info, nil
+}
@@ -1,61 +1,53 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 
 	"wishes/middleware"
 	"wishes/models"
+	"wishes/wechat"
 
 	"gorm.io/gorm"
 )
 
+var ErrWechatCodeInvalid = errors.New("微信登录凭证无效或已使用，请重新登录")
+
 type WechatService struct {
-	DB        *gorm.DB
-	AppID     string
-	AppSecret string
-	JWTSecret []byte
+	DB     *gorm.DB
+	Client wechat.Client
+	// Tokens 管理服务端接口使用的 access_token，调用需要 access_token 的接口时使用 Tokens.Do
+	Tokens *wechat.TokenManager
 }
 
-func NewWechatService(db *gorm.DB, appId string, appSecret string, jwtSecret []byte) *WechatService {
+func NewWechatService(db *gorm.DB, client wechat.Client) *WechatService {
 	return &WechatService{
-		DB:        db,
-		AppID:     appId,
-		AppSecret: appSecret,
-		JWTSecret: jwtSecret,
+		DB:     db,
+		Client: client,
+		Tokens: wechat.NewTokenManager(client, &wechatTokenStore{db: db}),
 	}
 }
 
-type WechatLoginResponse struct {
-	OpenID     string `json:"openid"`
-	SessionKey string `json:"session_key"`
-	UnionID    string `json:"unionid,omitempty"`
-	ErrCode    int    `json:"errcode"`
-	ErrMsg     string `json:"errmsg"`
-}
-
-func (s *WechatService) Login(code string) (string, *models.User, error) {
-	loginResp, err := s.Code2Session(code)
+func (s *WechatService) Login(ctx context.Context, code string) (string, *models.User, error) {
+	session, err := s.Client.Code2Session(ctx, code)
 	if err != nil {
-		return "", nil, err
-	}
-
-	if loginResp.ErrCode != 0 {
-		return "", nil, fmt.Errorf("微信登录失败: %s", loginResp.ErrMsg)
+		if errors.Is(err, wechat.ErrInvalidCode) || errors.Is(err, wechat.ErrCodeUsed) {
+			return "", nil, ErrWechatCodeInvalid
+		}
+		return "", nil, fmt.Errorf("微信登录失败: %w", err)
 	}
 
 	var user models.User
-	result := s.DB.Where("wechat_openid = ?", loginResp.OpenID).First(&user)
+	result := s.DB.Where("wechat_openid = ?", session.OpenID).First(&user)
 
 	if result.Error == gorm.ErrRecordNotFound {
 		user = models.User{
-			WechatOpenID: loginResp.OpenID,
+			WechatOpenID:     session.OpenID,
+			WechatSessionKey: session.SessionKey,
 		}
-		if loginResp.UnionID != "" {
-			user.WechatUnionID = loginResp.UnionID
+		if session.UnionID != "" {
+			user.WechatUnionID = session.UnionID
 		}
 
 		if err := s.DB.Create(&user).Error; err != nil {
@@ -65,6 +57,13 @@ func (s *WechatService) Login(code string) (string, *models.User, error) {
 		return "", nil, result.Error
 	}
 
+	// 保存最新的 session_key，后续解密手机号等开放数据时使用
+	if session.SessionKey != "" && session.SessionKey != user.WechatSessionKey {
+		if err := s.DB.Model(&user).Update("wechat_session_key", session.SessionKey).Error; err != nil {
+			return "", nil, err
+		}
+	}
+
 	token, err := middleware.GenerateUserToken(user)
 	if err != nil {
 		return "", nil, err
@@ -72,53 +71,3 @@ func (s *WechatService) Login(code string) (string, *models.User, error) {
 
 	return token, &user, nil
 }
-
-func (s *WechatService) Code2Session(code string) (*WechatLoginResponse, error) {
-	url := fmt.Sprintf(
-		"https://api.weixin.qq.com/sns/jscode2session?appid=%s&secret=%s&js_code=%s&grant_type=authorization_code",
-		s.AppID,
-		s.AppSecret,
-		code,
-	)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var loginResp WechatLoginResponse
-	if err := json.Unmarshal(body, &loginResp); err != nil {
-		return nil, err
-	}
-
-	return &loginResp, nil
-}
-
-func (s *WechatService) UpdateUserInfo(userID uint, nickname, avatarURL string) error {
-	if userID == 0 {
-		return errors.New("用户ID不能为空")
-	}
-
-	return s.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-		"nickname":   nickname,
-		"avatar_url": avatarURL,
-	}).Error
-}
-
-func (s *WechatService) UpdateAdminInfo(adminID uint, nickname, avatarURL string, department string) error {
-	if adminID == 0 {
-		return errors.New("管理员ID不能为空")
-	}
-
-	return s.DB.Model(&models.Admin{}).Where("id = ?", adminID).Updates(map[string]interface{}{
-		"nickname":   nickname,
-		"avatar_url": avatarURL,
-		"department": department,
-	}).Error
-}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"wishes/models"
+)
+
+// wechatTokenStore 将 access_token 保存在数据库中，实现 wechat.TokenStore
+type wechatTokenStore struct {
+	db *gorm.DB
+}
+
+func (s *wechatTokenStore) LoadAccessToken(ctx context.Context, appID string) (string, time.Time, error) {
+	var record models.WechatAccessToken
+	if err := s.db.WithContext(ctx).Where("app_id = ?", appID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", time.Time{}, nil
+		}
+		return "", time.Time{}, err
+	}
+	return record.Token, time.Unix(record.ExpiresAt, 0), nil
+}
+
+func (s *wechatTokenStore) SaveAccessToken(ctx context.Context, appID, token string, expiresAt time.Time) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "app_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token", "expires_at", "updated_at"}),
+	}).Create(&models.WechatAccessToken{
+		AppID:     appID,
+		Token:     token,
+		ExpiresAt: expiresAt.Unix(),
+	}).Error
+}
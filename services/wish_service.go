@@ -9,12 +9,14 @@ import (
 )
 
 type WishService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	tracker *UploadTracker
 }
 
-func NewWishService(db *gorm.DB) *WishService {
+func NewWishService(db *gorm.DB, tracker *UploadTracker) *WishService {
 	return &WishService{
-		db: db,
+		db:      db,
+		tracker: tracker,
 	}
 }
 
@@ -77,8 +79,13 @@ func (s *WishService) GetWishes(filters map[string]any) ([]WishResponse, int64,
 	return wishResponses, total, nil
 }
 
-func (s *WishService) CreateWish(wish *models.Wish) error {
-	return s.db.Create(wish).Error
+func (s *WishService) CreateWish(wish *models.Wish, actor UploadOwner) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(wish).Error; err != nil {
+			return err
+		}
+		return s.syncPhotos(tx, wish, actor)
+	})
 }
 
 func (s *WishService) GetWishByID(id uint) (*models.Wish, error) {
@@ -89,12 +96,29 @@ func (s *WishService) GetWishByID(id uint) (*models.Wish, error) {
 	return &wish, nil
 }
 
-func (s *WishService) UpdateWish(wish *models.Wish) error {
-	return s.db.Save(wish).Error
+func (s *WishService) UpdateWish(wish *models.Wish, actor UploadOwner) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(wish).Error; err != nil {
+			return err
+		}
+		return s.syncPhotos(tx, wish, actor)
+	})
 }
 
 func (s *WishService) DeleteWish(id uint) error {
-	return s.db.Delete(&models.Wish{}, id).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Wish{}, id).Error; err != nil {
+			return err
+		}
+		return s.tracker.SyncReferences(tx, RefTypeWish, id, UploadOwner{}, nil)
+	})
+}
+
+// syncPhotos 记录心愿引用的照片，被替换的照片由清理任务删除
+func (s *WishService) syncPhotos(tx *gorm.DB, wish *models.Wish, actor UploadOwner) error {
+	return s.tracker.SyncReferences(tx, RefTypeWish, wish.ID, actor, map[string][]string{
+		"photoUrl": photoURLs(wish.PhotoURL),
+	})
 }
 
 func (s *WishService) GetWishesByDonorID(donorID uint, pageIndex, pageSize int) ([]models.Wish, int64, error) {
@@ -115,12 +139,15 @@ func (s *WishService) GetWishesByDonorID(donorID uint, pageIndex, pageSize int)
 	return wishes, total, nil
 }
 
-func (s *WishService) BatchCreateWishes(wishes []*models.Wish) error {
+func (s *WishService) BatchCreateWishes(wishes []*models.Wish, actor UploadOwner) error {
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		for _, wish := range wishes {
 			if err := tx.Create(wish).Error; err != nil {
 				return err
 			}
+			if err := s.syncPhotos(tx, wish, actor); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
@@ -0,0 +1,8 @@
+package storage
+
+import "context"
+
+// Checker 由能够自检的存储后端实现，用于就绪检查。返回 nil 表示后端可以读写
+type Checker interface {
+	Check(ctx context.Context) error
+}
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// COSOptions 腾讯云对象存储配置
+type COSOptions struct {
+	SecretID  string
+	SecretKey string
+	Region    string
+	Bucket    string
+	// BaseURL 自定义访问域名，为空时使用存储桶的默认域名
+	BaseURL string
+	// Timeout 单次请求超时时间，默认30秒
+	Timeout time.Duration
+}
+
+// COSStorage 使用腾讯云对象存储保存文件
+type COSStorage struct {
+	client    *cos.Client
+	baseURL   string
+	bucketURL string // 私有文件的签名地址使用存储桶域名，CDN 不能回源读取私有文件
+	timeout   time.Duration
+}
+
+// NewCOSStorage 创建腾讯云存储后端，缺少存储桶或密钥时返回错误
+func NewCOSStorage(opts COSOptions) (*COSStorage, error) {
+	if opts.Bucket == "" || opts.Region == "" || opts.SecretID == "" || opts.SecretKey == "" {
+		return nil, fmt.Errorf("COS 配置不完整，请设置 COS_BUCKET_NAME、COS_REGION、COS_SECRET_ID 和 COS_SECRET_KEY")
+	}
+
+	bucketURL := fmt.Sprintf("https://%s.cos.%s.myqcloud.com", opts.Bucket, opts.Region)
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, err
+	}
+	client := cos.NewClient(&cos.BaseURL{BucketURL: u}, &http.Client{
+		Transport: &cos.AuthorizationTransport{
+			SecretID:  opts.SecretID,
+			SecretKey: opts.SecretKey,
+		},
+	})
+
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = bucketURL
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &COSStorage{
+		client:    client,
+		baseURL:   baseURL,
+		bucketURL: bucketURL,
+		timeout:   timeout,
+	}, nil
+}
+
+// Check 查询存储桶，确认 COS 可以访问且密钥有效
+func (s *COSStorage) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.client.Bucket.Head(ctx); err != nil {
+		return fmt.Errorf("访问COS存储桶失败: %w", err)
+	}
+	return nil
+}
+
+func (s *COSStorage) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err = s.client.Object.Put(ctx, key, r, &cos.ObjectPutOptions{
+		ACLHeaderOptions: aclHeaderOptions(key),
+		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
+			ContentType:   opts.ContentType,
+			ContentLength: opts.Size,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("上传文件到COS失败: %w", err)
+	}
+	return nil
+}
+
+func (s *COSStorage) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.client.Object.Delete(ctx, key); err != nil && !cos.IsNotFoundError(err) {
+		return fmt.Errorf("从COS删除文件失败: %w", err)
+	}
+	return nil
+}
+
+func (s *COSStorage) URL(key string) string {
+	if IsPrivateKey(key) {
+		return joinURL(s.bucketURL, key)
+	}
+	return joinURL(s.baseURL, key)
+}
+
+func (s *COSStorage) urlPrefixes() []string {
+	return []string{s.baseURL, s.bucketURL}
+}
+
+// aclHeaderOptions 私有文件单独设置为私有读，其余文件继承存储桶的权限
+func aclHeaderOptions(key string) *cos.ACLHeaderOptions {
+	if IsPrivateKey(key) {
+		return &cos.ACLHeaderOptions{XCosACL: "private"}
+	}
+	return nil
+}
+
+// SignURL 签发私有文件的下载地址
+func (s *COSStorage) SignURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	u, err := s.client.Object.GetPresignedURL2(ctx, http.MethodGet, key, expires, nil)
+	if err != nil {
+		return "", fmt.Errorf("生成COS下载地址失败: %w", err)
+	}
+	return u.String(), nil
+}
+
+func (s *COSStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	resp, err := s.client.Object.Head(ctx, key, nil)
+	if err != nil {
+		if cos.IsNotFoundError(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("查询COS文件失败: %w", err)
+	}
+
+	info := &ObjectInfo{
+		Key:         key,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModifiedAt = modified
+	}
+	return info, nil
+}
+
+// Open 下载文件内容，调用方需关闭返回的 Body。超时时间从调用时开始计算，包括读取内容
+func (s *COSStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	resp, err := s.client.Object.Get(ctx, key, nil)
+	if err != nil {
+		cancel()
+		if cos.IsNotFoundError(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("从COS下载文件失败: %w", err)
+	}
+	return &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// cancelOnClose 关闭 Body 时释放请求的 context
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// PresignPut 签发直传 COS 的 PUT 地址，Content-Type 和 Content-Length 都参与签名，客户端不能更改。
+// 私有文件的 x-cos-acl 也参与签名
+func (s *COSStorage) PresignPut(ctx context.Context, key string, opts PresignOptions) (*PresignedRequest, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"Content-Type":   opts.ContentType,
+		"Content-Length": strconv.FormatInt(opts.Size, 10),
+	}
+	if IsPrivateKey(key) {
+		headers["x-cos-acl"] = "private"
+	}
+	signed := http.Header{}
+	for name, value := range headers {
+		signed.Set(name, value)
+	}
+
+	u, err := s.client.Object.GetPresignedURL2(ctx, http.MethodPut, key, opts.Expires, &cos.PresignedURLOptions{
+		Header: &signed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成COS预签名地址失败: %w", err)
+	}
+
+	return &PresignedRequest{
+		Method:    http.MethodPut,
+		URL:       u.String(),
+		Headers:   headers,
+		ExpiresAt: time.Now().Add(opts.Expires).Unix(),
+	}, nil
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStorage 将文件保存在本地目录，并由服务自身在 URLPrefix 下提供访问，适合本地开发和单机部署。
+// 私有文件只能通过 SignURL 签发的地址访问
+type LocalStorage struct {
+	dir       string
+	urlPrefix string
+	signer    urlSigner
+}
+
+// NewLocalStorage 创建本地存储后端。urlPrefix 可以是路径（例如 /uploads），
+// 也可以是包含域名的完整地址（例如 https://api.example.com/uploads）
+func NewLocalStorage(dir, urlPrefix string) (*LocalStorage, error) {
+	if dir == "" {
+		return nil, errors.New("未设置本地存储目录")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建本地存储目录失败: %w", err)
+	}
+	if urlPrefix == "" {
+		urlPrefix = "/uploads"
+	}
+	return &LocalStorage{dir: dir, urlPrefix: urlPrefix}, nil
+}
+
+// SetSigningKey 设置签发私有文件访问地址的密钥，未设置时无法访问私有文件
+func (s *LocalStorage) SetSigningKey(secret []byte) {
+	s.signer = urlSigner{secret: secret}
+}
+
+// Dir 文件保存的目录
+func (s *LocalStorage) Dir() string {
+	return s.dir
+}
+
+// URLPath 静态文件服务挂载的路径
+func (s *LocalStorage) URLPath() string {
+	return urlPath(s.urlPrefix)
+}
+
+// Check 确认存储目录存在且可以写入
+func (s *LocalStorage) Check(ctx context.Context) error {
+	fi, err := os.Stat(s.dir)
+	if err != nil {
+		return fmt.Errorf("本地存储目录不可用: %w", err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("本地存储路径 %s 不是目录", s.dir)
+	}
+	f, err := os.CreateTemp(s.dir, ".check-*")
+	if err != nil {
+		return fmt.Errorf("本地存储目录不可写: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func (s *LocalStorage) path(key string) (string, string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return key, filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error {
+	_, name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	_, name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return joinURL(s.urlPrefix, key)
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	key, name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		ModifiedAt:  fi.ModTime(),
+	}, nil
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	_, name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// SignURL 签发私有文件的访问地址，由 ServeHTTP 校验
+func (s *LocalStorage) SignURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.signer.signURL(s.URL(key), key, expires)
+}
+
+// ServeHTTP 提供文件访问，请求路径为去掉 URLPath 后的文件路径。私有文件需要有效的签名
+func (s *LocalStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, name, err := s.path(strings.TrimPrefix(r.URL.Path, "/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if IsPrivateKey(key) {
+		if !s.signer.verify(key, r.URL.Query(), time.Now()) {
+			http.Error(w, "访问地址无效或已过期", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
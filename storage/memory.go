@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStorage 将文件保存在内存中，用于测试和本地调试，重启后文件丢失
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+	baseURL string
+	signer  urlSigner
+}
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+	modifiedAt  time.Time
+}
+
+// NewMemoryStorage 创建内存存储后端，baseURL 用于生成访问地址
+func NewMemoryStorage(baseURL string) *MemoryStorage {
+	if baseURL == "" {
+		baseURL = "memory://"
+	}
+	// 签名密钥只在本进程内有效
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return &MemoryStorage{
+		objects: make(map[string]memoryObject),
+		baseURL: baseURL,
+		signer:  urlSigner{secret: secret},
+	}
+}
+
+// Check 内存存储始终可用
+func (s *MemoryStorage) Check(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStorage) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memoryObject{data: data, contentType: opts.ContentType, modifiedAt: time.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *MemoryStorage) URL(key string) string {
+	return joinURL(s.baseURL, key)
+}
+
+// SignURL 签发带过期时间的地址，内存存储不提供文件访问，只用于测试
+func (s *MemoryStorage) SignURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.signer.signURL(s.URL(key), key, expires)
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{
+		Key:         key,
+		Size:        int64(len(obj.data)),
+		ContentType: obj.contentType,
+		ModifiedAt:  obj.modifiedAt,
+	}, nil
+}
+
+func (s *MemoryStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := s.Get(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Get 读取文件内容，便于测试检查写入的数据
+func (s *MemoryStorage) Get(key string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(obj.data), true
+}
+
+// Keys 返回全部文件路径，按字典序排列
+func (s *MemoryStorage) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrPresignUnsupported = errors.New("存储后端不支持预签名上传")
+
+// Presigner 由支持客户端直传的存储后端实现，签发的地址只能上传到指定的 key，
+// 且请求必须携带签名时约定的 Content-Type 和 Content-Length
+type Presigner interface {
+	PresignPut(ctx context.Context, key string, opts PresignOptions) (*PresignedRequest, error)
+}
+
+// PresignOptions 预签名上传的约束
+type PresignOptions struct {
+	ContentType string
+	Size        int64
+	Expires     time.Duration
+}
+
+// PresignedRequest 客户端直传时需要发送的请求
+type PresignedRequest struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"` // 必须原样携带的请求头
+	ExpiresAt int64             `json:"expiresAt"`
+}
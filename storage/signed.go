@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrivatePrefix 私有文件的路径前缀。这些文件不能通过公开地址访问，只能使用有时效的签名地址读取
+const PrivatePrefix = "private/"
+
+var ErrSigningUnavailable = errors.New("存储后端未配置签名密钥，无法生成私有文件的访问地址")
+
+// IsPrivateKey 判断文件是否为私有文件
+func IsPrivateKey(key string) bool {
+	return strings.HasPrefix(key, PrivatePrefix)
+}
+
+// ReadSigner 由支持私有文件的存储后端实现，签发的地址在 expires 后失效
+type ReadSigner interface {
+	SignURL(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// urlPrefixer 由访问地址不止一种前缀的存储后端实现，例如 COS 的自定义域名和存储桶域名
+type urlPrefixer interface {
+	urlPrefixes() []string
+}
+
+// KeyFromURL 从访问地址（包括签名地址）中取出文件路径，不是该存储的地址时返回 false。
+// 访问地址前缀只有路径时只比较地址中的路径，客户端保存的可能是带域名的完整地址
+func KeyFromURL(s Storage, rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	u.RawQuery, u.Fragment = "", ""
+
+	prefixes := []string{s.URL("")}
+	if p, ok := s.(urlPrefixer); ok {
+		prefixes = p.urlPrefixes()
+	}
+	for _, prefix := range prefixes {
+		candidate := u.String()
+		if strings.HasPrefix(prefix, "/") {
+			candidate = u.Path
+		}
+		rest, ok := strings.CutPrefix(candidate, strings.TrimSuffix(prefix, "/")+"/")
+		if !ok {
+			continue
+		}
+		if key, err := CleanKey(rest); err == nil {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// urlSigner 使用 HMAC-SHA256 签发和校验访问地址，供由服务自身提供文件访问的存储后端使用
+type urlSigner struct {
+	secret []byte
+}
+
+func (s urlSigner) signature(key string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expiresAt, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signURL 在访问地址后附加过期时间和签名
+func (s urlSigner) signURL(fileURL, key string, expires time.Duration) (string, error) {
+	if len(s.secret) == 0 {
+		return "", ErrSigningUnavailable
+	}
+	expiresAt := time.Now().Add(expires).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt, 10))
+	query.Set("signature", s.signature(key, expiresAt))
+	return fileURL + "?" + query.Encode(), nil
+}
+
+// verify 校验签名地址的查询参数，签名错误或已过期时返回 false
+func (s urlSigner) verify(key string, query url.Values, now time.Time) bool {
+	if len(s.secret) == 0 {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || now.Unix() >= expiresAt {
+		return false
+	}
+	return hmac.Equal([]byte(query.Get("signature")), []byte(s.signature(key, expiresAt)))
+}
@@ -0,0 +1,73 @@
+// Package storage 定义上传文件的存储后端，包括腾讯云 COS、本地磁盘和用于测试的内存实现
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("文件不存在")
+	ErrInvalidKey = errors.New("无效的文件路径")
+)
+
+// Storage 文件存储后端。key 是以 / 分隔的相对路径，例如 images/avatar/xxx.png
+type Storage interface {
+	// Put 写入文件，已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// URL 返回文件的访问地址
+	URL(key string) string
+	// Stat 返回文件信息，文件不存在时返回 ErrNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Open 读取文件内容，文件不存在时返回 ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// PutOptions 写入文件的可选参数
+type PutOptions struct {
+	ContentType string
+	// Size 文件大小，未知时为 0。COS 需要知道长度才能直接上传
+	Size int64
+}
+
+// ObjectInfo 已存储文件的信息
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModifiedAt  time.Time
+}
+
+// CleanKey 规范化文件路径，拒绝绝对路径和跳出根目录的路径
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || strings.ContainsRune(key, 0) {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// joinURL 拼接访问地址前缀和文件路径
+func joinURL(baseURL, key string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + key
+}
+
+// urlPath 返回访问地址前缀中的路径部分，前缀可以是路径或完整地址
+func urlPath(prefix string) string {
+	p := prefix
+	if u, err := url.Parse(prefix); err == nil && u.Host != "" {
+		p = u.Path
+	}
+	p = "/" + strings.Trim(p, "/")
+	return p
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// RFC 6238 附录 B 的 SHA1 测试向量，取后 6 位
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Errorf("T=%d: 应为 %s，实际 %s", unix, want, code)
+		}
+	}
+}
+
+func TestVerifyTOTPReturnsMatchedStep(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_800_000_000, 0)
+	for offset := int64(-1); offset <= 1; offset++ {
+		code, err := TOTPCode(secret, TOTPStep(now)+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step, ok := VerifyTOTP(secret, code, now, 1); !ok || step != TOTPStep(now)+offset {
+			t.Errorf("偏移 %d: 应返回时间步 %d，实际 %d %v", offset, TOTPStep(now)+offset, step, ok)
+		}
+	}
+	if _, ok := VerifyTOTP(secret, "12345", now, 1); ok {
+		t.Error("位数不对的验证码应被拒绝")
+	}
+}
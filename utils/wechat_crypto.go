@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+)
+
+var ErrInvalidWechatData = errors.New("无效的微信加密数据")
+
+// DecryptWechatData 解密小程序开放数据（如 getPhoneNumber 返回的 encryptedData）。
+// 算法为 AES-128-CBC、PKCS#7 填充，密钥为 session_key，三个参数均为 Base64 编码。
+func DecryptWechatData(sessionKey, encryptedData, iv string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil || len(key) != 16 {
+		return nil, ErrInvalidWechatData
+	}
+	ivBytes, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil || len(ivBytes) != aes.BlockSize {
+		return nil, ErrInvalidWechatData
+	}
+	data, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, ErrInvalidWechatData
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrInvalidWechatData
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, ivBytes).CryptBlocks(plain, data)
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plain[len(plain)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, ErrInvalidWechatData
+	}
+	return plain[:len(plain)-padding], nil
+}
@@ -0,0 +1,260 @@
+// Package wechat 封装小程序服务端调用的微信开放接口。
+//
+// 业务代码依赖 Client 接口，生产环境使用 NewClient 创建的 HTTP 实现，
+// 测试时可以把 BaseURL 指向 wechattest 包提供的模拟服务。
+//
+// 只有获取 access_token 这样的幂等调用会自动重试。code 只能使用一次、消息重复发送会打扰用户，
+// Code2Session、GetPhoneNumber 和 SendSubscribeMessage 失败时直接返回，由调用方决定是否重试。
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL    = "https://api.weixin.qq.com"
+	DefaultTimeout    = 5 * time.Second
+	DefaultMaxRetries = 2
+	retryBackoff      = 200 * time.Millisecond
+)
+
+// Client 微信开放接口
+type Client interface {
+	// AppID 返回小程序的 AppID，用于校验解密数据中的水印
+	AppID() string
+	// Code2Session 用 wx.login 返回的 code 换取 openid 和 session_key，不自动重试
+	Code2Session(ctx context.Context, code string) (*Session, error)
+	// GetAccessToken 获取接口调用凭证，每次调用都会请求微信，应通过 TokenManager 使用
+	GetAccessToken(ctx context.Context) (*AccessToken, error)
+	// GetPhoneNumber 用 getPhoneNumber 返回的 code 换取用户手机号，不自动重试
+	GetPhoneNumber(ctx context.Context, accessToken, code string) (*PhoneInfo, error)
+	// SendSubscribeMessage 发送订阅消息，不自动重试
+	SendSubscribeMessage(ctx context.Context, accessToken string, msg *SubscribeMessage) error
+}
+
+// Session code2Session 的返回结果
+type Session struct {
+	OpenID     string `json:"openid"`
+	SessionKey string `json:"session_key"`
+	UnionID    string `json:"unionid,omitempty"`
+}
+
+// AccessToken 接口调用凭证
+type AccessToken struct {
+	Token     string `json:"access_token"`
+	ExpiresIn int    `json:"expires_in"` // 有效期，单位秒
+}
+
+// PhoneInfo 用户手机号信息，getuserphonenumber 接口与 encryptedData 解密结果格式一致
+type PhoneInfo struct {
+	PhoneNumber     string    `json:"phoneNumber"`     // 带区号的手机号，境外手机号会有区号
+	PurePhoneNumber string    `json:"purePhoneNumber"` // 不带区号的手机号
+	CountryCode     string    `json:"countryCode"`
+	Watermark       Watermark `json:"watermark"`
+}
+
+// Watermark 数据水印，用于校验数据属于当前小程序
+type Watermark struct {
+	AppID     string `json:"appid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Options HTTP 客户端配置，零值字段使用默认值
+type Options struct {
+	AppID      string
+	AppSecret  string
+	BaseURL    string        // 默认 https://api.weixin.qq.com
+	Timeout    time.Duration // 单次请求超时，默认 5 秒
+	MaxRetries int           // 幂等接口在网络错误、5xx 和系统繁忙时的重试次数，默认 2，小于 0 表示不重试
+	HTTPClient *http.Client  // 自定义 HTTP 客户端，设置后忽略 Timeout
+}
+
+type httpClient struct {
+	appID      string
+	appSecret  string
+	baseURL    string
+	maxRetries int
+	http       *http.Client
+}
+
+// NewClient 创建基于 HTTP 的微信接口客户端
+func NewClient(opts Options) Client {
+	baseURL := strings.TrimRight(opts.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+
+	return &httpClient{
+		appID:      opts.AppID,
+		appSecret:  opts.AppSecret,
+		baseURL:    baseURL,
+		maxRetries: maxRetries,
+		http:       client,
+	}
+}
+
+func (c *httpClient) AppID() string {
+	return c.appID
+}
+
+func (c *httpClient) Code2Session(ctx context.Context, code string) (*Session, error) {
+	query := url.Values{}
+	query.Set("appid", c.appID)
+	query.Set("secret", c.appSecret)
+	query.Set("js_code", code)
+	query.Set("grant_type", "authorization_code")
+
+	var resp struct {
+		baseResponse
+		Session
+	}
+	if err := c.do(ctx, http.MethodGet, "/sns/jscode2session", query, nil, &resp, 0); err != nil {
+		return nil, err
+	}
+	return &resp.Session, nil
+}
+
+func (c *httpClient) GetAccessToken(ctx context.Context) (*AccessToken, error) {
+	query := url.Values{}
+	query.Set("grant_type", "client_credential")
+	query.Set("appid", c.appID)
+	query.Set("secret", c.appSecret)
+
+	var resp struct {
+		baseResponse
+		AccessToken
+	}
+	if err := c.do(ctx, http.MethodGet, "/cgi-bin/token", query, nil, &resp, c.maxRetries); err != nil {
+		return nil, err
+	}
+	return &resp.AccessToken, nil
+}
+
+func (c *httpClient) GetPhoneNumber(ctx context.Context, accessToken, code string) (*PhoneInfo, error) {
+	query := url.Values{}
+	query.Set("access_token", accessToken)
+
+	var resp struct {
+		baseResponse
+		PhoneInfo PhoneInfo `json:"phone_info"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/wxa/business/getuserphonenumber", query, map[string]string{"code": code}, &resp, 0); err != nil {
+		return nil, err
+	}
+	return &resp.PhoneInfo, nil
+}
+
+// baseResponse 所有接口共有的错误字段
+type baseResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (r *baseResponse) base() *baseResponse {
+	return r
+}
+
+type response interface {
+	base() *baseResponse
+}
+
+// do 发送请求并解析响应，临时错误按指数退避最多重试 retries 次。
+// 非幂等的调用传入 0，请求可能已被微信处理，重试会重复消耗 code 或重复发送消息
+func (c *httpClient) do(ctx context.Context, method, path string, query url.Values, body any, out response, retries int) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryBackoff << (attempt - 1)):
+			}
+		}
+
+		retry, err := c.doOnce(ctx, method, path, query, payload, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	return lastErr
+}
+
+func (c *httpClient) doOnce(ctx context.Context, method, path string, query url.Values, payload []byte, out response) (retry bool, err error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path+"?"+query.Encode(), body)
+	if err != nil {
+		return false, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// url.Error 中包含完整的请求地址（带 secret 和 access_token），不能原样返回
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, fmt.Errorf("请求微信接口 %s 失败: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return true, fmt.Errorf("读取微信接口 %s 响应失败: %w", path, err)
+	}
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return true, fmt.Errorf("微信接口 %s 返回HTTP状态 %d", path, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("微信接口 %s 返回HTTP状态 %d", path, resp.StatusCode)
+	}
+
+	*out.base() = baseResponse{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("解析微信接口 %s 响应失败: %w", path, err)
+	}
+	if base := out.base(); base.ErrCode != 0 {
+		return retryable(base.ErrCode), &Error{API: path, Code: base.ErrCode, Msg: base.ErrMsg}
+	}
+	return false, nil
+}
@@ -0,0 +1,167 @@
+package wechat_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wishes/wechat"
+	"wishes/wechat/wechattest"
+)
+
+const (
+	pathCode2Session = "/sns/jscode2session"
+	pathToken        = "/cgi-bin/token"
+	pathPhone        = "/wxa/business/getuserphonenumber"
+	pathSend         = "/cgi-bin/message/subscribe/send"
+)
+
+func newFake(t *testing.T) (*wechattest.Server, string) {
+	t.Helper()
+	fake := wechattest.NewServer("", "")
+	server := fake.Start()
+	t.Cleanup(server.Close)
+	return fake, server.URL
+}
+
+func newClient(fake *wechattest.Server, baseURL string, maxRetries int) wechat.Client {
+	return wechat.NewClient(wechat.Options{
+		AppID:      fake.AppID,
+		AppSecret:  fake.AppSecret,
+		BaseURL:    baseURL,
+		MaxRetries: maxRetries,
+	})
+}
+
+func TestErrorCodeMapping(t *testing.T) {
+	fake, baseURL := newFake(t)
+	client := newClient(fake, baseURL, -1)
+	ctx := context.Background()
+
+	if _, err := client.Code2Session(ctx, "invalid"); !errors.Is(err, wechat.ErrInvalidCode) {
+		t.Errorf("无效的 code 应返回 ErrInvalidCode，实际 %v", err)
+	}
+	if _, err := client.Code2Session(ctx, "once"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Code2Session(ctx, "once")
+	if !errors.Is(err, wechat.ErrCodeUsed) || wechat.ErrorCode(err) != wechat.ErrCodeUsed.Code {
+		t.Errorf("重复使用的 code 应返回 ErrCodeUsed，实际 %v", err)
+	}
+
+	wrongSecret := wechat.NewClient(wechat.Options{AppID: fake.AppID, AppSecret: "wrong", BaseURL: baseURL})
+	if _, err := wrongSecret.GetAccessToken(ctx); !errors.Is(err, wechat.ErrInvalidAppSecret) {
+		t.Errorf("错误的 AppSecret 应返回 ErrInvalidAppSecret，实际 %v", err)
+	}
+
+	if err := client.SendSubscribeMessage(ctx, "not-issued", &wechat.SubscribeMessage{}); !errors.Is(err, wechat.ErrInvalidCredential) {
+		t.Errorf("无效的 access_token 应返回 ErrInvalidCredential，实际 %v", err)
+	}
+	fake.FailNext(pathSend, wechat.ErrSubscribeRefused.Code)
+	err = client.SendSubscribeMessage(ctx, "any", &wechat.SubscribeMessage{})
+	if !errors.Is(err, wechat.ErrSubscribeRefused) || errors.Is(err, wechat.ErrRateLimited) {
+		t.Errorf("应只匹配 ErrSubscribeRefused，实际 %v", err)
+	}
+	if wechat.ErrorCode(errors.New("other")) != 0 {
+		t.Error("非微信错误的错误码应为 0")
+	}
+}
+
+func TestRetriesOnlyIdempotentCalls(t *testing.T) {
+	fake, baseURL := newFake(t)
+	client := newClient(fake, baseURL, 2)
+	ctx := context.Background()
+
+	// 获取 access_token 在 5xx 和系统繁忙时重试
+	fake.FailNext(pathToken, http.StatusBadGateway, wechat.ErrSystemBusy.Code)
+	token, err := client.GetAccessToken(ctx)
+	if err != nil {
+		t.Fatalf("重试后应成功: %v", err)
+	}
+	if got := fake.Requests(pathToken); got != 3 {
+		t.Errorf("应请求 3 次，实际 %d 次", got)
+	}
+
+	// 重试次数用完后返回最后一次的错误
+	fake.FailNext(pathToken, wechat.ErrSystemBusy.Code, wechat.ErrSystemBusy.Code, wechat.ErrSystemBusy.Code)
+	if _, err := client.GetAccessToken(ctx); !errors.Is(err, wechat.ErrSystemBusy) {
+		t.Errorf("应返回 ErrSystemBusy，实际 %v", err)
+	}
+	if got := fake.Requests(pathToken); got != 6 {
+		t.Errorf("应共请求 6 次，实际 %d 次", got)
+	}
+
+	// 业务错误不重试
+	fake.FailNext(pathToken, wechat.ErrInvalidAppID.Code)
+	if _, err := client.GetAccessToken(ctx); !errors.Is(err, wechat.ErrInvalidAppID) {
+		t.Errorf("应返回 ErrInvalidAppID，实际 %v", err)
+	}
+	if got := fake.Requests(pathToken); got != 7 {
+		t.Errorf("业务错误不应重试，共请求 %d 次", got)
+	}
+
+	// 非幂等的调用即使遇到临时错误也只请求一次
+	fake.FailNext(pathCode2Session, http.StatusBadGateway)
+	if _, err := client.Code2Session(ctx, "code"); err == nil {
+		t.Error("Code2Session 应返回错误")
+	}
+	fake.FailNext(pathPhone, wechat.ErrSystemBusy.Code)
+	if _, err := client.GetPhoneNumber(ctx, token.Token, "code"); !errors.Is(err, wechat.ErrSystemBusy) {
+		t.Errorf("GetPhoneNumber 应返回 ErrSystemBusy，实际 %v", err)
+	}
+	fake.FailNext(pathSend, http.StatusServiceUnavailable)
+	msg := &wechat.SubscribeMessage{ToUser: "openid", TemplateID: "tpl", Data: map[string]wechat.MessageValue{"thing1": {Value: "x"}}}
+	if err := client.SendSubscribeMessage(ctx, token.Token, msg); err == nil {
+		t.Error("SendSubscribeMessage 应返回错误")
+	}
+	for _, path := range []string{pathCode2Session, pathPhone, pathSend} {
+		if got := fake.Requests(path); got != 1 {
+			t.Errorf("%s 不应自动重试，实际请求 %d 次", path, got)
+		}
+	}
+	if len(fake.Messages()) != 0 {
+		t.Error("失败的消息不应被发送")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	client := wechat.NewClient(wechat.Options{
+		AppID:      "wxtest",
+		AppSecret:  "very-secret",
+		BaseURL:    server.URL,
+		Timeout:    50 * time.Millisecond,
+		MaxRetries: -1,
+	})
+
+	start := time.Now()
+	_, err := client.GetAccessToken(context.Background())
+	if err == nil {
+		t.Fatal("超时应返回错误")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("超时未生效，耗时 %s", elapsed)
+	}
+	// 错误信息中不能带有请求地址中的 secret
+	if strings.Contains(err.Error(), "very-secret") {
+		t.Errorf("错误信息泄露了 AppSecret: %v", err)
+	}
+
+	// 调用方的 context 取消时同样立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	slow := wechat.NewClient(wechat.Options{AppID: "wxtest", AppSecret: "very-secret", BaseURL: server.URL, Timeout: time.Minute})
+	if _, err := slow.Code2Session(ctx, "code"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("应返回 context.DeadlineExceeded，实际 %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package wechat
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error 微信接口返回的业务错误（errcode 不为 0）
+type Error struct {
+	API  string // 出错的接口路径，哨兵错误为空
+	Code int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.API == "" {
+		return fmt.Sprintf("微信接口错误 %d: %s", e.Code, e.Msg)
+	}
+	return fmt.Sprintf("微信接口 %s 返回错误 %d: %s", e.API, e.Code, e.Msg)
+}
+
+// Is 按错误码匹配，使 errors.Is(err, wechat.ErrInvalidCode) 等判断可用
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.API == "" && t.Code == e.Code
+}
+
+// 常见错误码，完整列表见微信官方文档
+var (
+	ErrSystemBusy         = &Error{Code: -1, Msg: "系统繁忙，请稍后再试"}
+	ErrInvalidCredential  = &Error{Code: 40001, Msg: "AppSecret错误或access_token无效"}
+	ErrInvalidOpenID      = &Error{Code: 40003, Msg: "不合法的openid"}
+	ErrInvalidAppID       = &Error{Code: 40013, Msg: "不合法的AppID"}
+	ErrInvalidCode        = &Error{Code: 40029, Msg: "code无效"}
+	ErrInvalidTemplateID  = &Error{Code: 40037, Msg: "订阅模板ID无效"}
+	ErrInvalidAppSecret   = &Error{Code: 40125, Msg: "不合法的AppSecret"}
+	ErrCodeUsed           = &Error{Code: 40163, Msg: "code已被使用"}
+	ErrHighRiskUser       = &Error{Code: 40226, Msg: "高风险等级用户，登录已被拦截"}
+	ErrAccessTokenExpired = &Error{Code: 42001, Msg: "access_token已过期"}
+	ErrSubscribeRefused   = &Error{Code: 43101, Msg: "用户拒绝接受消息或授权次数已用完"}
+	ErrRateLimited        = &Error{Code: 45011, Msg: "接口调用频率超过限制"}
+	ErrInvalidMessageData = &Error{Code: 47003, Msg: "模板参数不准确"}
+)
+
+// ErrorCode 返回 err 中的微信错误码，不是微信业务错误时返回 0
+func ErrorCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return 0
+}
+
+// retryable 判断错误码是否为临时错误，可以原样重试
+func retryable(code int) bool {
+	return code == ErrSystemBusy.Code
+}
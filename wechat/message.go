@@ -0,0 +1,38 @@
+package wechat
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// 跳转小程序的版本
+const (
+	MiniprogramStateFormal    = "formal"
+	MiniprogramStateTrial     = "trial"
+	MiniprogramStateDeveloper = "developer"
+)
+
+// SubscribeMessage 一次性订阅消息，用户每授权一次模板可以发送一条
+type SubscribeMessage struct {
+	ToUser           string                  `json:"touser"` // 接收者的 openid
+	TemplateID       string                  `json:"template_id"`
+	Page             string                  `json:"page,omitempty"` // 点击消息后跳转的小程序页面，可带参数
+	MiniprogramState string                  `json:"miniprogram_state,omitempty"`
+	Lang             string                  `json:"lang,omitempty"`
+	Data             map[string]MessageValue `json:"data"`
+}
+
+// MessageValue 模板字段的值，不同类型的字段有各自的长度和格式限制，
+// 例如 thing 类型不超过 20 个字符、phrase 类型不超过 5 个汉字
+type MessageValue struct {
+	Value string `json:"value"`
+}
+
+func (c *httpClient) SendSubscribeMessage(ctx context.Context, accessToken string, msg *SubscribeMessage) error {
+	query := url.Values{}
+	query.Set("access_token", accessToken)
+
+	var resp baseResponse
+	return c.do(ctx, http.MethodPost, "/cgi-bin/message/subscribe/send", query, msg, &resp, 0)
+}
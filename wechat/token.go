@@ -0,0 +1,189 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshBefore 在 access_token 过期前多久提前刷新
+const DefaultRefreshBefore = 5 * time.Minute
+
+// TokenStore 持久化 access_token，使服务重启后不必重新获取。
+// 微信限制了 access_token 的每日获取次数，频繁重启时尤其需要持久化。
+type TokenStore interface {
+	// LoadAccessToken 读取已保存的 access_token，没有记录时返回空字符串
+	LoadAccessToken(ctx context.Context, appID string) (token string, expiresAt time.Time, err error)
+	SaveAccessToken(ctx context.Context, appID, token string, expiresAt time.Time) error
+}
+
+// TokenManager 缓存并自动刷新 access_token，可在多个 goroutine 中并发使用。
+// 同一时间只会有一个刷新请求，其余调用方等待它的结果。
+type TokenManager struct {
+	client        Client
+	store         TokenStore
+	refreshBefore time.Duration
+	now           func() time.Time
+
+	mu        sync.Mutex
+	loaded    bool // 是否已从 store 读取过
+	token     string
+	expiresAt time.Time
+	revoked   string // 最近一次被判定失效的 token，不再从 store 中读取它
+	inflight  *tokenRefresh
+}
+
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// NewTokenManager 创建 access_token 管理器，store 为 nil 时只在内存中缓存
+func NewTokenManager(client Client, store TokenStore) *TokenManager {
+	return &TokenManager{
+		client:        client,
+		store:         store,
+		refreshBefore: DefaultRefreshBefore,
+		now:           time.Now,
+	}
+}
+
+// SetClock 替换当前时间的来源，便于模拟过期
+func (m *TokenManager) SetClock(now func() time.Time) {
+	m.now = now
+}
+
+// Token 返回有效的 access_token，即将过期时自动刷新
+func (m *TokenManager) Token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	if !m.loaded {
+		m.loaded = true
+		m.loadLocked(ctx)
+	}
+	if m.validLocked() {
+		token := m.token
+		m.mu.Unlock()
+		return token, nil
+	}
+	call := m.refreshLocked(ctx)
+	m.mu.Unlock()
+
+	return call.wait(ctx)
+}
+
+// Invalidate 标记 token 已失效，下次调用 Token 时重新获取。
+// 只有 token 仍是当前缓存的值时才生效，避免并发请求重复刷新。
+func (m *TokenManager) Invalidate(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token == token {
+		m.token = ""
+		m.expiresAt = time.Time{}
+		m.revoked = token
+	}
+}
+
+// Do 使用 access_token 调用 fn，若微信返回 40001 或 42001，
+// 说明 token 已被其他途径刷新或提前失效，刷新后重试一次
+func (m *TokenManager) Do(ctx context.Context, fn func(token string) error) error {
+	token, err := m.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(token)
+	if !errors.Is(err, ErrInvalidCredential) && !errors.Is(err, ErrAccessTokenExpired) {
+		return err
+	}
+
+	m.Invalidate(token)
+	if token, err = m.Token(ctx); err != nil {
+		return err
+	}
+	return fn(token)
+}
+
+func (m *TokenManager) validLocked() bool {
+	return m.token != "" && m.now().Add(m.refreshBefore).Before(m.expiresAt)
+}
+
+// loadLocked 从 store 读取 access_token，读取失败时忽略，稍后重新获取即可
+func (m *TokenManager) loadLocked(ctx context.Context) {
+	if m.store == nil {
+		return
+	}
+	token, expiresAt, err := m.store.LoadAccessToken(ctx, m.client.AppID())
+	if err != nil {
+		slog.ErrorContext(ctx, "读取微信access_token失败", slog.String("error", err.Error()))
+		return
+	}
+	m.token, m.expiresAt = token, expiresAt
+}
+
+// refreshLocked 返回正在进行的刷新，没有时发起新的刷新
+func (m *TokenManager) refreshLocked(ctx context.Context) *tokenRefresh {
+	if m.inflight != nil {
+		return m.inflight
+	}
+
+	call := &tokenRefresh{done: make(chan struct{})}
+	m.inflight = call
+
+	// 刷新结果由所有等待者共享，不能因为发起者的请求被取消而中断
+	go m.refresh(context.WithoutCancel(ctx), call)
+	return call
+}
+
+func (m *TokenManager) refresh(ctx context.Context, call *tokenRefresh) {
+	defer close(call.done)
+
+	token, expiresAt, err := m.fetch(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inflight = nil
+	if err != nil {
+		call.err = err
+		return
+	}
+	m.token, m.expiresAt = token, expiresAt
+	call.token = token
+}
+
+func (m *TokenManager) fetch(ctx context.Context) (string, time.Time, error) {
+	// 多实例部署时其他实例可能已经刷新并保存了新的 token
+	if m.store != nil {
+		token, expiresAt, err := m.store.LoadAccessToken(ctx, m.client.AppID())
+		m.mu.Lock()
+		revoked := m.revoked
+		m.mu.Unlock()
+		if err == nil && token != "" && token != revoked && m.now().Add(m.refreshBefore).Before(expiresAt) {
+			return token, expiresAt, nil
+		}
+	}
+
+	accessToken, err := m.client.GetAccessToken(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := m.now().Add(time.Duration(accessToken.ExpiresIn) * time.Second)
+
+	if m.store != nil {
+		if err := m.store.SaveAccessToken(ctx, m.client.AppID(), accessToken.Token, expiresAt); err != nil {
+			slog.ErrorContext(ctx, "保存微信access_token失败", slog.String("error", err.Error()))
+		}
+	}
+	return accessToken.Token, expiresAt, nil
+}
+
+func (c *tokenRefresh) wait(ctx context.Context) (string, error) {
+	select {
+	case <-c.done:
+		return c.token, c.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,208 @@
+package wechat_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"wishes/wechat"
+	"wishes/wechat/wechattest"
+)
+
+// memoryTokenStore 在内存中保存 access_token，模拟多个实例共享的存储
+type memoryTokenStore struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	saves     int
+}
+
+func (s *memoryTokenStore) LoadAccessToken(ctx context.Context, appID string) (string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token, s.expiresAt, nil
+}
+
+func (s *memoryTokenStore) SaveAccessToken(ctx context.Context, appID, token string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token, s.expiresAt = token, expiresAt
+	s.saves++
+	return nil
+}
+
+// fixedClock 可手动推进的时钟
+type fixedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFixedClock() *fixedClock {
+	return &fixedClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fixedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTokenManager(client wechat.Client, store wechat.TokenStore, clock *fixedClock) *wechat.TokenManager {
+	manager := wechat.NewTokenManager(client, store)
+	manager.SetClock(clock.Now)
+	return manager
+}
+
+func TestTokenSingleFlight(t *testing.T) {
+	fake := wechattest.NewServer("", "")
+	// 放慢获取 access_token 的响应，让所有调用方都在刷新进行中时到达
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == pathToken {
+			time.Sleep(50 * time.Millisecond)
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	manager := newTokenManager(fake.Client(server.URL), nil, newFixedClock())
+
+	const callers = 20
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], errs[i] = manager.Token(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("第 %d 个调用失败: %v", i, errs[i])
+		}
+		if tokens[i] == "" || tokens[i] != tokens[0] {
+			t.Fatalf("所有调用方应拿到同一个 token: %q, %q", tokens[0], tokens[i])
+		}
+	}
+	if got := fake.Requests(pathToken); got != 1 {
+		t.Errorf("并发获取 token 应只请求 1 次，实际 %d 次", got)
+	}
+}
+
+func TestTokenReusesStoredToken(t *testing.T) {
+	fake, baseURL := newFake(t)
+	store := &memoryTokenStore{}
+	clock := newFixedClock()
+	ctx := context.Background()
+
+	first, err := newTokenManager(fake.Client(baseURL), store, clock).Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.token != first || store.saves != 1 {
+		t.Fatalf("获取的 token 应保存到 store: %+v", store)
+	}
+
+	// 模拟服务重启：新的管理器直接使用保存的 token
+	clock.Advance(time.Hour)
+	second, err := newTokenManager(fake.Client(baseURL), store, clock).Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first {
+		t.Errorf("应复用保存的 token %q，实际 %q", first, second)
+	}
+	if got := fake.Requests(pathToken); got != 1 {
+		t.Errorf("重启后不应重新获取 token，共请求 %d 次", got)
+	}
+}
+
+func TestTokenDoRetriesOnce(t *testing.T) {
+	msg := &wechat.SubscribeMessage{ToUser: "openid", TemplateID: "tpl", Data: map[string]wechat.MessageValue{"thing1": {Value: "x"}}}
+	for _, code := range []*wechat.Error{wechat.ErrInvalidCredential, wechat.ErrAccessTokenExpired} {
+		t.Run(code.Msg, func(t *testing.T) {
+			fake, baseURL := newFake(t)
+			client := fake.Client(baseURL)
+			manager := newTokenManager(client, &memoryTokenStore{}, newFixedClock())
+			ctx := context.Background()
+			send := func(token string) error {
+				return client.SendSubscribeMessage(ctx, token, msg)
+			}
+
+			if err := manager.Do(ctx, send); err != nil {
+				t.Fatal(err)
+			}
+
+			// token 失效后刷新并重试一次
+			fake.FailNext(pathSend, code.Code)
+			if err := manager.Do(ctx, send); err != nil {
+				t.Fatalf("刷新 token 后重试应成功: %v", err)
+			}
+			if got := fake.Requests(pathToken); got != 2 {
+				t.Errorf("应重新获取 1 次 token，共请求 %d 次", got)
+			}
+			if got := fake.Requests(pathSend); got != 3 {
+				t.Errorf("应只重试 1 次，共发送 %d 次", got)
+			}
+
+			// 重试仍然失败时返回错误，不再继续重试
+			fake.FailNext(pathSend, code.Code, code.Code)
+			if err := manager.Do(ctx, send); !errors.Is(err, code) {
+				t.Errorf("应返回 %v，实际 %v", code, err)
+			}
+			if got := fake.Requests(pathSend); got != 5 {
+				t.Errorf("应只重试 1 次，共发送 %d 次", got)
+			}
+			if got := len(fake.Messages()); got != 2 {
+				t.Errorf("应成功发送 2 条消息，实际 %d 条", got)
+			}
+		})
+	}
+}
+
+func TestTokenRefreshesBeforeExpiry(t *testing.T) {
+	fake, baseURL := newFake(t)
+	clock := newFixedClock()
+	manager := newTokenManager(fake.Client(baseURL), &memoryTokenStore{}, clock)
+	ctx := context.Background()
+
+	first, err := manager.Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟服务签发的 token 有效期为 7200 秒，距离过期超过 DefaultRefreshBefore 时继续使用
+	lifetime := 7200 * time.Second
+	clock.Advance(lifetime - wechat.DefaultRefreshBefore - time.Second)
+	if token, err := manager.Token(ctx); err != nil || token != first {
+		t.Fatalf("未进入提前刷新的时间，应继续使用原 token: %q, %v", token, err)
+	}
+	if got := fake.Requests(pathToken); got != 1 {
+		t.Fatalf("不应刷新 token，共请求 %d 次", got)
+	}
+
+	// 进入提前刷新的时间，token 尚未过期也重新获取
+	clock.Advance(2 * time.Second)
+	second, err := manager.Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Error("即将过期的 token 应被刷新")
+	}
+	if got := fake.Requests(pathToken); got != 2 {
+		t.Errorf("应刷新 1 次 token，共请求 %d 次", got)
+	}
+}
@@ -0,0 +1,356 @@
+// Package wechattest 提供一个模拟的微信开放接口服务，
+// 用于在测试中覆盖登录、手机号和消息等流程，只应在 _test.go 文件中使用。
+//
+// 模拟服务的行为：
+//   - jscode2session: 任意 code 都能登录，同一个 code 对应固定的 openid，每个 code 只能使用一次；
+//     code 为 "invalid" 时返回 40029
+//   - cgi-bin/token: 校验 appid 和 secret，每次调用签发新的 access_token，旧的仍然有效直到过期
+//   - getuserphonenumber: 通过 AddPhoneCode 注册的 code 返回对应手机号，其余 code 返回 DefaultPhone
+//   - subscribe/send: 校验 access_token 和必填字段后记录消息，可通过 Messages 查看
+//
+// 可以通过 FailNext 注入错误，通过 ExpireAccessTokens 模拟 access_token 过期。
+package wechattest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"wishes/wechat"
+)
+
+const (
+	DefaultAppID     = "wxfake0000000000"
+	DefaultAppSecret = "fake-app-secret"
+	DefaultPhone     = "13800138000"
+
+	accessTokenTTL = 7200
+)
+
+// Server 模拟的微信开放接口服务，实现了 http.Handler
+type Server struct {
+	AppID     string
+	AppSecret string
+
+	mu           sync.Mutex
+	mux          *http.ServeMux
+	usedCodes    map[string]bool
+	sessionKeys  map[string]string // openid -> 最近一次登录的 session_key
+	accessTokens map[string]time.Time
+	phoneCodes   map[string]string
+	failures     map[string][]int
+	requests     map[string]int
+	messages     []wechat.SubscribeMessage
+	tokenSeq     int
+}
+
+// NewServer 创建模拟服务，appID 和 appSecret 为空时使用默认值
+func NewServer(appID, appSecret string) *Server {
+	if appID == "" {
+		appID = DefaultAppID
+	}
+	if appSecret == "" {
+		appSecret = DefaultAppSecret
+	}
+
+	s := &Server{
+		AppID:        appID,
+		AppSecret:    appSecret,
+		mux:          http.NewServeMux(),
+		usedCodes:    make(map[string]bool),
+		sessionKeys:  make(map[string]string),
+		accessTokens: make(map[string]time.Time),
+		phoneCodes:   make(map[string]string),
+		failures:     make(map[string][]int),
+		requests:     make(map[string]int),
+	}
+	s.mux.HandleFunc("GET /sns/jscode2session", s.handleCode2Session)
+	s.mux.HandleFunc("GET /cgi-bin/token", s.handleAccessToken)
+	s.mux.HandleFunc("POST /wxa/business/getuserphonenumber", s.handleGetPhoneNumber)
+	s.mux.HandleFunc("POST /cgi-bin/message/subscribe/send", s.handleSendSubscribeMessage)
+	return s
+}
+
+// Start 在随机端口上启动模拟服务，返回的 URL 可直接作为客户端的 BaseURL
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+// Client 返回指向 baseURL 上模拟服务的客户端，幂等接口也不重试，以便观察注入的错误
+func (s *Server) Client(baseURL string) wechat.Client {
+	return wechat.NewClient(wechat.Options{
+		AppID:      s.AppID,
+		AppSecret:  s.AppSecret,
+		BaseURL:    baseURL,
+		MaxRetries: -1,
+	})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	var code int
+	if queue := s.failures[r.URL.Path]; len(queue) > 0 {
+		code, s.failures[r.URL.Path] = queue[0], queue[1:]
+	}
+	s.mu.Unlock()
+
+	if code != 0 {
+		if code >= 100 && code < 600 {
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		writeError(w, code, "injected error")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// FailNext 使接下来对 path 的请求依次返回指定的错误：
+// 100-599 之间的值作为 HTTP 状态码返回，其余值作为 errcode 返回
+func (s *Server) FailNext(path string, codes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], codes...)
+}
+
+// Requests 返回 path 收到的请求次数（包括注入错误的请求）
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// AddPhoneCode 注册 getPhoneNumber 的 code 及其对应的手机号
+func (s *Server) AddPhoneCode(code, phone string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phoneCodes[code] = phone
+}
+
+// Messages 返回已成功发送的订阅消息
+func (s *Server) Messages() []wechat.SubscribeMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]wechat.SubscribeMessage(nil), s.messages...)
+}
+
+// ExpireAccessTokens 使所有已签发的 access_token 立即过期
+func (s *Server) ExpireAccessTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token := range s.accessTokens {
+		s.accessTokens[token] = time.Time{}
+	}
+}
+
+// OpenID 返回 code 登录后对应的 openid
+func OpenID(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return "o_fake_" + hex.EncodeToString(sum[:12])
+}
+
+// EncryptPhone 使用 openid 最近一次登录的 session_key 加密手机号，
+// 返回与小程序 getPhoneNumber 相同格式的 encryptedData 和 iv
+func (s *Server) EncryptPhone(openID, phone string) (encryptedData, iv string, err error) {
+	s.mu.Lock()
+	sessionKey, ok := s.sessionKeys[openID]
+	s.mu.Unlock()
+	if !ok {
+		return "", "", fmt.Errorf("用户 %s 尚未登录", openID)
+	}
+
+	plain, err := json.Marshal(s.phoneInfo(phone))
+	if err != nil {
+		return "", "", err
+	}
+
+	key, _ := base64.StdEncoding.DecodeString(sessionKey)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", "", err
+	}
+	ivBytes := randomBytes(aes.BlockSize)
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	plain = append(plain, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, ivBytes).CryptBlocks(plain, plain)
+
+	return base64.StdEncoding.EncodeToString(plain), base64.StdEncoding.EncodeToString(ivBytes), nil
+}
+
+func (s *Server) handleCode2Session(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if !s.checkApp(w, query.Get("appid"), query.Get("secret")) {
+		return
+	}
+
+	code := query.Get("js_code")
+	if code == "" || code == "invalid" {
+		writeError(w, wechat.ErrInvalidCode.Code, "invalid code")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.usedCodes[code] {
+		writeError(w, wechat.ErrCodeUsed.Code, "code been used")
+		return
+	}
+	s.usedCodes[code] = true
+
+	openID := OpenID(code)
+	sessionKey := base64.StdEncoding.EncodeToString(randomBytes(16))
+	s.sessionKeys[openID] = sessionKey
+
+	writeJSON(w, wechat.Session{OpenID: openID, SessionKey: sessionKey})
+}
+
+func (s *Server) handleAccessToken(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("grant_type") != "client_credential" {
+		writeError(w, 40002, "invalid grant_type")
+		return
+	}
+	if !s.checkApp(w, query.Get("appid"), query.Get("secret")) {
+		return
+	}
+
+	s.mu.Lock()
+	s.tokenSeq++
+	token := fmt.Sprintf("fake-access-token-%d-%s", s.tokenSeq, hex.EncodeToString(randomBytes(8)))
+	s.accessTokens[token] = time.Now().Add(accessTokenTTL * time.Second)
+	s.mu.Unlock()
+
+	writeJSON(w, wechat.AccessToken{Token: token, ExpiresIn: accessTokenTTL})
+}
+
+func (s *Server) handleGetPhoneNumber(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccessToken(w, r) {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" || req.Code == "invalid" {
+		writeError(w, wechat.ErrInvalidCode.Code, "invalid code")
+		return
+	}
+
+	s.mu.Lock()
+	used := s.usedCodes["phone:"+req.Code]
+	s.usedCodes["phone:"+req.Code] = true
+	phone, ok := s.phoneCodes[req.Code]
+	s.mu.Unlock()
+	if used {
+		writeError(w, wechat.ErrCodeUsed.Code, "code been used")
+		return
+	}
+	if !ok {
+		phone = DefaultPhone
+	}
+
+	writeJSON(w, map[string]any{
+		"errcode":    0,
+		"errmsg":     "ok",
+		"phone_info": s.phoneInfo(phone),
+	})
+}
+
+func (s *Server) handleSendSubscribeMessage(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccessToken(w, r) {
+		return
+	}
+
+	var msg wechat.SubscribeMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		writeError(w, 47001, "data format error")
+		return
+	}
+	if msg.ToUser == "" {
+		writeError(w, wechat.ErrInvalidOpenID.Code, "invalid openid")
+		return
+	}
+	if msg.TemplateID == "" {
+		writeError(w, wechat.ErrInvalidTemplateID.Code, "invalid template_id")
+		return
+	}
+	if len(msg.Data) == 0 {
+		writeError(w, wechat.ErrInvalidMessageData.Code, "argument invalid! data is empty")
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	writeError(w, 0, "ok")
+}
+
+// checkAccessToken 校验请求中的 access_token，无效返回 40001，过期返回 42001
+func (s *Server) checkAccessToken(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	expiresAt, ok := s.accessTokens[r.URL.Query().Get("access_token")]
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		writeError(w, wechat.ErrInvalidCredential.Code, "invalid credential, access_token is invalid or not latest")
+		return false
+	case time.Now().After(expiresAt):
+		writeError(w, wechat.ErrAccessTokenExpired.Code, "access_token expired")
+		return false
+	}
+	return true
+}
+
+func (s *Server) checkApp(w http.ResponseWriter, appID, secret string) bool {
+	if appID != s.AppID {
+		writeError(w, wechat.ErrInvalidAppID.Code, "invalid appid")
+		return false
+	}
+	if secret != s.AppSecret {
+		writeError(w, wechat.ErrInvalidAppSecret.Code, "invalid appsecret")
+		return false
+	}
+	return true
+}
+
+func (s *Server) phoneInfo(phone string) wechat.PhoneInfo {
+	return wechat.PhoneInfo{
+		PhoneNumber:     phone,
+		PurePhoneNumber: phone,
+		CountryCode:     "86",
+		Watermark: wechat.Watermark{
+			AppID:     s.AppID,
+			Timestamp: time.Now().Unix(),
+		},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, map[string]any{"errcode": code, "errmsg": msg})
+}
+
+func randomBytes(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return buf
+}